@@ -9,15 +9,20 @@ import (
 	"os"
 	"time"
 
+	"github.com/juice-shop/multi-juicer/internal/application"
 	"github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/cleaner"
+	"github.com/juice-shop/multi-juicer/internal/eventlog"
+	"github.com/juice-shop/multi-juicer/internal/instances"
 	"github.com/juice-shop/multi-juicer/internal/leader"
 	"github.com/juice-shop/multi-juicer/internal/notification"
+	"github.com/juice-shop/multi-juicer/internal/notifier"
 	"github.com/juice-shop/multi-juicer/internal/progresswatchdog"
 	private_routes "github.com/juice-shop/multi-juicer/internal/routes/private"
 	public_routes "github.com/juice-shop/multi-juicer/internal/routes/public"
 	"github.com/juice-shop/multi-juicer/internal/scoring"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 )
 
@@ -25,6 +30,10 @@ const leaderReacquireBackoff = 5 * time.Second
 
 func main() {
 	b := bundle.New()
+	b.Application = application.NewJuiceShop(b)
+	if err := b.LoadChallenges(); err != nil {
+		panic(err)
+	}
 
 	// Route client-go's klog output (used by the leaderelection package) through our slog logger so
 	// every line shares the same format.
@@ -32,28 +41,42 @@ func main() {
 
 	scoringService := scoring.NewScoringService(b)
 	notificationService := notification.NewNotificationService(b)
+	eventLog := eventlog.NewEventLog(b)
 
 	b.ScoringService = scoringService
 	b.NotificationService = notificationService
+	b.EventLog = eventLog
+
+	eventNotifier, err := notifier.New(b)
+	if err != nil {
+		panic(err)
+	}
 
 	ctx := context.Background()
 
 	go StartMetricsServer(b.Log)
+
+	go b.JuiceShopInformer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), b.JuiceShopInformer.HasSynced) {
+		panic(errors.New("failed to sync the JuiceShop deployment cache"))
+	}
+
 	scoringService.CalculateAndCacheScoreBoard(ctx)
 	go scoringService.StartingScoringWorker(ctx)
 	go notificationService.StartNotificationWatcher(ctx)
+	go eventLog.StartEventLogWatcher(ctx)
 
 	internalMux := http.NewServeMux()
 	private_routes.AddRoutes(ctx, internalMux, b)
 
 	go StartInternalServer(internalMux, b.Log)
 
-	go runLeaderLoop(ctx, b)
+	go runLeaderLoop(ctx, b, eventNotifier)
 
 	StartPublicServer(b)
 }
 
-func runLeaderLoop(ctx context.Context, b *bundle.Bundle) {
+func runLeaderLoop(ctx context.Context, b *bundle.Bundle, eventNotifier *notifier.Notifier) {
 	identity := os.Getenv("POD_NAME")
 	if identity == "" {
 		// Fall back to hostname-ish so leader election still works in non-k8s test setups; in-cluster the
@@ -68,6 +91,9 @@ func runLeaderLoop(ctx context.Context, b *bundle.Bundle) {
 	onStartedLeading := func(leaderCtx context.Context) {
 		go progresswatchdog.StartBackgroundSync(leaderCtx, b)
 		go cleaner.StartPeriodicCleanup(leaderCtx, b)
+		go instances.StartController(leaderCtx, b)
+		go instances.StartWarmPool(leaderCtx, b)
+		go eventNotifier.Start(leaderCtx)
 	}
 
 	// leader.Run returns when leadership is lost; re-enter the election so a transient renewal failure
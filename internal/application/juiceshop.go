@@ -0,0 +1,236 @@
+package application
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/speps/go-hashids/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	juiceShopPort = 3000
+	// juiceShopConfigVolume is the configmap with the JuiceShop config.yaml rendered by the helm chart
+	juiceShopConfigVolume = "juice-shop-config"
+)
+
+// JuiceShop is the built-in Application hosting an OWASP Juice Shop for every team
+type JuiceShop struct {
+	bundle *bundle.Bundle
+	// challengesFile is the challenges.json of the JuiceShop version configured for the event, copied into the MultiJuicer image
+	challengesFile string
+}
+
+// NewJuiceShop returns the JuiceShop application. The environment of the containers is taken from the JuiceShop config of the bundle.
+func NewJuiceShop(b *bundle.Bundle) *JuiceShop {
+	return &JuiceShop{
+		bundle:         b,
+		challengesFile: "/challenges.json",
+	}
+}
+
+type challengeResponse struct {
+	Status string      `json:"status"`
+	Data   []challenge `json:"data"`
+}
+
+type challenge struct {
+	Id        int    `json:"id"`
+	Key       string `json:"key"`
+	Solved    bool   `json:"solved"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func (j *JuiceShop) Port() int32 {
+	return juiceShopPort
+}
+
+func (j *JuiceShop) Container() corev1.Container {
+	probeHandler := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path: "/rest/admin/application-version",
+			Port: intstr.FromInt(juiceShopPort),
+		},
+	}
+	return corev1.Container{
+		Ports: []corev1.ContainerPort{
+			{
+				ContainerPort: juiceShopPort,
+			},
+		},
+		StartupProbe: &corev1.Probe{
+			ProbeHandler:     probeHandler,
+			PeriodSeconds:    2,
+			FailureThreshold: 150,
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler:     probeHandler,
+			PeriodSeconds:    5,
+			FailureThreshold: 3,
+		},
+		LivenessProbe: &corev1.Probe{
+			ProbeHandler:        probeHandler,
+			InitialDelaySeconds: 30,
+			PeriodSeconds:       15,
+		},
+		Env: []corev1.EnvVar{
+			{
+				Name:  "NODE_ENV",
+				Value: j.bundle.Config.JuiceShopConfig.NodeEnv,
+			},
+			{
+				Name:  "CTF_KEY",
+				Value: j.bundle.Config.JuiceShopConfig.CtfKey,
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      juiceShopConfigVolume,
+				MountPath: "/juice-shop/config/multi-juicer.yaml",
+				ReadOnly:  true,
+				SubPath:   "multi-juicer.yaml",
+			},
+		},
+	}
+}
+
+func (j *JuiceShop) Volumes() []corev1.Volume {
+	return []corev1.Volume{
+		{
+			Name: juiceShopConfigVolume,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: juiceShopConfigVolume,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (j *JuiceShop) Challenges() ([]bundle.JuiceShopChallenge, error) {
+	challengesBytes, err := os.ReadFile(j.challengesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var challenges []bundle.JuiceShopChallenge
+	if err := json.Unmarshal(challengesBytes, &challenges); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", j.challengesFile, err)
+	}
+	return challenges, nil
+}
+
+// CtfFlag returns the flag of the challenge, the HMAC-SHA1 of its name keyed with the CTF key (see https://pwning.owasp-juice.shop/companion-guide/latest/part4/ctf.html).
+// The JuiceShop falls back to a key of its own without CTF key, so the flag can't be computed then.
+func (j *JuiceShop) CtfFlag(challenge bundle.JuiceShopChallenge) string {
+	ctfKey := j.bundle.Config.JuiceShopConfig.CtfKey
+	if ctfKey == "" {
+		return ""
+	}
+	mac := hmac.New(sha1.New, []byte(ctfKey))
+	mac.Write([]byte(challenge.Name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (j *JuiceShop) FetchProgress(ctx context.Context, baseUrl string) ([]bundle.ChallengeStatus, error) {
+	challenges, err := fetchChallenges(ctx, baseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	solved := []bundle.ChallengeStatus{}
+	for _, challenge := range challenges {
+		if challenge.Solved {
+			solved = append(solved, bundle.ChallengeStatus{
+				Key:      challenge.Key,
+				SolvedAt: challenge.UpdatedAt,
+			})
+		}
+	}
+	return solved, nil
+}
+
+// RestoreProgress applies a ContinueCode containing the solved challenges to the JuiceShop.
+// The challenge ids in the ContinueCode depend on the JuiceShop version, so they're looked up from the JuiceShop itself.
+func (j *JuiceShop) RestoreProgress(ctx context.Context, baseUrl string, solved []bundle.ChallengeStatus) error {
+	challenges, err := fetchChallenges(ctx, baseUrl)
+	if err != nil {
+		return err
+	}
+	challengeIds := make(map[string]int, len(challenges))
+	for _, challenge := range challenges {
+		challengeIds[challenge.Key] = challenge.Id
+	}
+
+	continueCode, err := generateContinueCode(solved, challengeIds)
+	if err != nil {
+		return fmt.Errorf("failed to encode challenge progress into continue code: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/rest/continue-code/apply/%s", baseUrl, continueCode), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create http request to set the current ContinueCode: %w", err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to set the current ContinueCode to juice shop: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status code '%d' from Juice Shop when applying the ContinueCode", res.StatusCode)
+	}
+	return nil
+}
+
+func fetchChallenges(ctx context.Context, baseUrl string) ([]challenge, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseUrl+"/api/challenges", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request to fetch the challenge status: %w", err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Challenge Status: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status code '%d' from Juice Shop", res.StatusCode)
+	}
+
+	response := challengeResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON from Juice Shop Challenge Status response: %w", err)
+	}
+	return response.Data, nil
+}
+
+// generateContinueCode encodes the solved challenges into a ContinueCode using the challenge ids of the JuiceShop it gets applied to.
+// Challenges the JuiceShop doesn't know, e.g. because it runs an older version, are left out.
+func generateContinueCode(challenges []bundle.ChallengeStatus, challengeIds map[string]int) (string, error) {
+	hd := hashids.NewData()
+	hd.Salt = "this is my salt"
+	hd.MinLength = 60
+	hd.Alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
+
+	hashIDClient, _ := hashids.NewWithData(hd)
+
+	ids := []int{}
+	for _, challenge := range challenges {
+		if id, ok := challengeIds[challenge.Key]; ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return hashIDClient.Encode(ids)
+}
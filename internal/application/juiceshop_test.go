@@ -0,0 +1,109 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const challengesResponse = `{
+	"status": "success",
+	"data": [
+		{"id": 1, "key": "scoreBoardChallenge", "solved": true, "updatedAt": "2024-11-01T19:55:48.211Z"},
+		{"id": 2, "key": "nullByteChallenge", "solved": false, "updatedAt": "2024-11-01T19:00:00.000Z"},
+		{"id": 7, "key": "newChallenge", "solved": true, "updatedAt": "2024-11-01T20:01:02.003Z"}
+	]
+}`
+
+func newJuiceShopServer(t *testing.T, appliedContinueCodes *[]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/challenges":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(challengesResponse))
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/rest/continue-code/apply/"):
+			*appliedContinueCodes = append(*appliedContinueCodes, strings.TrimPrefix(r.URL.Path, "/rest/continue-code/apply/"))
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestJuiceShop(t *testing.T) {
+	t.Run("fetches the solved challenges", func(t *testing.T) {
+		server := newJuiceShopServer(t, &[]string{})
+		juiceShop := NewJuiceShop(&bundle.Bundle{Config: &bundle.Config{}})
+
+		solved, err := juiceShop.FetchProgress(context.Background(), server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, []bundle.ChallengeStatus{
+			{Key: "scoreBoardChallenge", SolvedAt: "2024-11-01T19:55:48.211Z"},
+			{Key: "newChallenge", SolvedAt: "2024-11-01T20:01:02.003Z"},
+		}, solved)
+	})
+
+	t.Run("restores progress using the challenge ids of the juice shop", func(t *testing.T) {
+		appliedContinueCodes := []string{}
+		server := newJuiceShopServer(t, &appliedContinueCodes)
+		juiceShop := NewJuiceShop(&bundle.Bundle{Config: &bundle.Config{}})
+
+		err := juiceShop.RestoreProgress(context.Background(), server.URL, []bundle.ChallengeStatus{
+			{Key: "newChallenge", SolvedAt: "2024-11-01T20:01:02.003Z"},
+			{Key: "challengeOfAnotherVersion", SolvedAt: "2024-11-01T20:01:02.003Z"},
+		})
+		require.NoError(t, err)
+
+		expected, err := generateContinueCode([]bundle.ChallengeStatus{{Key: "newChallenge"}}, map[string]int{"newChallenge": 7})
+		require.NoError(t, err)
+		assert.Equal(t, []string{expected}, appliedContinueCodes, "challenges unknown to the juice shop are left out")
+	})
+
+	t.Run("returns an error if the juice shop isn't reachable", func(t *testing.T) {
+		server := newJuiceShopServer(t, &[]string{})
+		server.Close()
+		juiceShop := NewJuiceShop(&bundle.Bundle{Config: &bundle.Config{}})
+
+		_, err := juiceShop.FetchProgress(context.Background(), server.URL)
+		assert.Error(t, err)
+	})
+
+	t.Run("reads the challenges from the challenges file", func(t *testing.T) {
+		challengesFile := filepath.Join(t.TempDir(), "challenges.json")
+		require.NoError(t, os.WriteFile(challengesFile, []byte(`[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1}]`), 0o600))
+		juiceShop := NewJuiceShop(&bundle.Bundle{Config: &bundle.Config{}})
+		juiceShop.challengesFile = challengesFile
+
+		challenges, err := juiceShop.Challenges()
+		require.NoError(t, err)
+		assert.Equal(t, []bundle.JuiceShopChallenge{{Key: "scoreBoardChallenge", Name: "Score Board", Difficulty: 1}}, challenges)
+	})
+}
+
+func TestCtfFlag(t *testing.T) {
+	juiceShop := NewJuiceShop(&bundle.Bundle{Config: &bundle.Config{JuiceShopConfig: bundle.JuiceShopConfig{CtfKey: "test-ctf-key"}}})
+	assert.Equal(t, "41c543fd95e1d050a977a55ecd3dde5672685ff6", juiceShop.CtfFlag(bundle.JuiceShopChallenge{Key: "scoreBoardChallenge", Name: "Score Board"}))
+
+	juiceShop = NewJuiceShop(&bundle.Bundle{Config: &bundle.Config{}})
+	assert.Equal(t, "", juiceShop.CtfFlag(bundle.JuiceShopChallenge{Key: "scoreBoardChallenge", Name: "Score Board"}), "without ctf key the juice shop uses a key of its own")
+}
+
+func TestGenerateContinueCode(t *testing.T) {
+	continueCode, err := generateContinueCode([]bundle.ChallengeStatus{{Key: "scoreBoardChallenge"}, {Key: "nullByteChallenge"}}, map[string]int{"scoreBoardChallenge": 1, "nullByteChallenge": 2})
+	require.NoError(t, err)
+	assert.Len(t, continueCode, 60)
+
+	other, err := generateContinueCode([]bundle.ChallengeStatus{{Key: "scoreBoardChallenge"}, {Key: "nullByteChallenge"}}, map[string]int{"scoreBoardChallenge": 3, "nullByteChallenge": 2})
+	require.NoError(t, err)
+	assert.NotEqual(t, continueCode, other, "the continue code depends on the challenge ids of the juice shop")
+}
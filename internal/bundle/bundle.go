@@ -7,14 +7,25 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/juice-shop/multi-juicer/internal/longpoll"
 	"github.com/juice-shop/multi-juicer/internal/passcode"
 	"golang.org/x/crypto/bcrypt"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 )
 
 // Bundle holds all the dependencies and configuration that are used by the routes
@@ -22,6 +33,12 @@ import (
 type Bundle struct {
 	RuntimeEnvironment RuntimeEnvironment
 	ClientSet          kubernetes.Interface
+	// JuiceShopInformer caches the JuiceShop deployments of all teams. Started in main, everything reading JuiceShop deployments should use the JuiceShopLister instead of querying the Kubernetes API.
+	// Writes (create, patch, update, delete) still go to the Kubernetes API directly and show up in the cache shortly after.
+	JuiceShopInformer cache.SharedIndexInformer
+	JuiceShopLister   appslisters.DeploymentLister
+	// DynamicClient is used to access the JuiceShopInstance custom resources, see JuiceShopInstanceResource
+	DynamicClient dynamic.Interface
 	// generates a random passcode. On the bundle to have a static passcode in tests for easier assertions
 	GeneratePasscode func() string
 	// returns the (cluster internal) url for a team used by the proxy to forward the request to. On the bundle to allow the tests to proxy requests to a local testing server
@@ -33,12 +50,19 @@ type Bundle struct {
 
 	// LongPollDefaultWaitTimeout amount of time that HTTP Long Polling Endpoints wait for new data to arrive before returning a empty no changes response
 	LongPollDefaultWaitTimeout time.Duration
+	// Broker notifies server-sent event connections about changes to the score-board, team status, activity feed and notifications
+	Broker *longpoll.Broker
 
+	// JuiceShopChallenges contains the challenges used for the event, challenges excluded by Config.ChallengeFilter are not included
 	JuiceShopChallenges []JuiceShopChallenge
+	// ExcludedChallengeKeys contains the keys of the challenges removed by Config.ChallengeFilter
+	ExcludedChallengeKeys map[string]bool
 
 	// Services - set after Bundle creation to avoid cyclic dependencies
+	Application         Application
 	ScoringService      ScoringService
 	NotificationService NotificationService
+	EventLog            EventLog
 }
 
 type RuntimeEnvironment struct {
@@ -46,16 +70,168 @@ type RuntimeEnvironment struct {
 }
 
 type Config struct {
-	JuiceShopConfig       JuiceShopConfig `json:"juiceShop"`
-	MaxInstances          int             `json:"maxInstances"`
-	TeamPasscodeLength    int             `json:"teamPasscodeLength"`
-	CookieConfig          CookieConfig    `json:"cookie"`
-	ThemeConfig           ThemeConfig     `json:"theme"`
+	JuiceShopConfig       JuiceShopConfig  `json:"juiceShop"`
+	MaxInstances          int              `json:"maxInstances"`
+	TeamPasscodeLength    int              `json:"teamPasscodeLength"`
+	CookieConfig          CookieConfig     `json:"cookie"`
+	ThemeConfig           ThemeConfig      `json:"theme"`
+	ScoringConfig         ScoringConfig    `json:"scoring"`
+	ChallengeFilter       ChallengeFilter  `json:"challengeFilter"`
+	Notifiers             []NotifierConfig `json:"notifiers"`
 	AdminConfig           *AdminConfig
 	ContentSecurityPolicy string
 	Cleanup               CleanupConfig
 }
 
+const (
+	// ScoringStrategyStatic awards difficulty × 10 points per solved challenge. This is the default.
+	ScoringStrategyStatic = "static"
+	// ScoringStrategyDynamic starts challenges at a maximum value which decays towards a minimum the more teams solve it.
+	ScoringStrategyDynamic = "dynamic"
+	// ScoringStrategyFlat awards the same amount of points for every solved challenge.
+	ScoringStrategyFlat = "flat"
+)
+
+const (
+	NotifierTypeSlack   = "slack"
+	NotifierTypeDiscord = "discord"
+	NotifierTypeTeams   = "teams"
+	// NotifierTypeGeneric posts the event as JSON, e.g. to a custom bot
+	NotifierTypeGeneric = "generic"
+)
+
+// NotifierConfig is a chat or HTTP endpoint solves, first bloods, team registrations and instance outages get posted to
+type NotifierConfig struct {
+	Name string `json:"name"`
+	// Type is one of "slack", "discord", "teams" or "generic"
+	Type string `json:"type"`
+	// Url of the incoming webhook. Webhook urls usually contain credentials, so it's read from the NOTIFIER_<index>_URL environment variable if empty.
+	Url string `json:"url"`
+	// Events posted to the endpoint, e.g. ["first_blood", "instance_down"]. Defaults to all events.
+	Events []string `json:"events"`
+	// Templates replace the default message of an event, keyed by event. Uses the go text/template syntax, e.g. "{{ .Team }} solved {{ .Challenge }}"
+	Templates map[string]string `json:"templates"`
+}
+
+// ScoringConfig selects how many points a solved challenge is worth
+type ScoringConfig struct {
+	// Strategy is one of "static" (default), "dynamic" or "flat".
+	Strategy string               `json:"strategy"`
+	Dynamic  DynamicScoringConfig `json:"dynamic"`
+	Flat     FlatScoringConfig    `json:"flat"`
+	// EarlySolveBonusPercentages awards the first N solvers of every challenge a bonus on top of the challenge points, as percentage of the points.
+	// e.g. [50, 25, 10] gives the first solver ("first blood") +50%, the second +25% and the third +10%.
+	EarlySolveBonusPercentages []int `json:"earlySolveBonusPercentages"`
+	// HintCost is the amount of points subtracted from a team's score when it unlocks a challenge hint.
+	HintCost int `json:"hintCost"`
+	// PointOverrides sets a fixed amount of points for individual challenges, keyed by challenge key. Replaces the value of the scoring strategy and isn't affected by CategoryMultipliers.
+	PointOverrides map[string]int `json:"pointOverrides"`
+	// CategoryMultipliers multiplies the points of all challenges in a category, keyed by category name (case-insensitive).
+	// e.g. {"Broken Access Control": 2} makes access control challenges worth double. Results are rounded to the nearest point.
+	CategoryMultipliers map[string]float64 `json:"categoryMultipliers"`
+	// CheatScore configures what happens to teams with a high cheat score
+	CheatScore CheatScoreConfig `json:"cheatScore"`
+}
+
+const (
+	CheatDecisionSuspicious   = "suspicious"
+	CheatDecisionDisqualified = "disqualified"
+)
+
+// CheatScoreConfig flags teams based on the latest total cheat score reported by their JuiceShop, which ranges from 0 to 1.
+// Thresholds set to 0 are disabled.
+type CheatScoreConfig struct {
+	// SuspiciousThreshold marks teams as suspicious for the admins, it doesn't affect their score
+	SuspiciousThreshold float64 `json:"suspiciousThreshold"`
+	// DisqualifyThreshold hides teams from the score board. Their progress is kept, so they show up again if the threshold is raised
+	DisqualifyThreshold float64 `json:"disqualifyThreshold"`
+}
+
+// Decide returns the decision for a team with the given cheat score: CheatDecisionDisqualified, CheatDecisionSuspicious or "" if the team isn't flagged
+func (c CheatScoreConfig) Decide(cheatScore float64) string {
+	switch {
+	case c.DisqualifyThreshold > 0 && cheatScore >= c.DisqualifyThreshold:
+		return CheatDecisionDisqualified
+	case c.SuspiciousThreshold > 0 && cheatScore >= c.SuspiciousThreshold:
+		return CheatDecisionSuspicious
+	default:
+		return ""
+	}
+}
+
+// ChallengeFilter restricts the set of challenges used for an event, e.g. to only Injection and XSS challenges up to difficulty 3 for a focused training.
+// Excluded challenges are hidden from the challenge list, don't score and don't show up in the activity feed.
+// All set fields must match for a challenge to be included. Empty fields don't filter.
+type ChallengeFilter struct {
+	// Include is an allowlist of challenge keys
+	Include []string `json:"include"`
+	// Exclude is a denylist of challenge keys, excluded even if they match all other filters
+	Exclude []string `json:"exclude"`
+	// Categories only includes challenges of one of the categories
+	Categories []string `json:"categories"`
+	// Tags only includes challenges which have at least one of the tags
+	Tags          []string `json:"tags"`
+	MinDifficulty int      `json:"minDifficulty"`
+	MaxDifficulty int      `json:"maxDifficulty"`
+}
+
+// Matches returns true if the challenge should be used for the event
+func (f ChallengeFilter) Matches(challenge JuiceShopChallenge) bool {
+	if len(f.Include) > 0 && !slices.Contains(f.Include, challenge.Key) {
+		return false
+	}
+	if slices.Contains(f.Exclude, challenge.Key) {
+		return false
+	}
+	if len(f.Categories) > 0 && !slices.ContainsFunc(f.Categories, func(category string) bool {
+		return strings.EqualFold(category, challenge.Category)
+	}) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(f.Tags, func(tag string) bool {
+		return slices.ContainsFunc(challenge.Tags, func(challengeTag string) bool {
+			return strings.EqualFold(tag, challengeTag)
+		})
+	}) {
+		return false
+	}
+	if f.MinDifficulty > 0 && challenge.Difficulty < f.MinDifficulty {
+		return false
+	}
+	if f.MaxDifficulty > 0 && challenge.Difficulty > f.MaxDifficulty {
+		return false
+	}
+	return true
+}
+
+// FilterChallenges splits the challenges into the ones matching the filter and the keys of the excluded ones
+func FilterChallenges(challenges []JuiceShopChallenge, filter ChallengeFilter) ([]JuiceShopChallenge, map[string]bool) {
+	included := make([]JuiceShopChallenge, 0, len(challenges))
+	excluded := make(map[string]bool)
+	for _, challenge := range challenges {
+		if filter.Matches(challenge) {
+			included = append(included, challenge)
+		} else {
+			excluded[challenge.Key] = true
+		}
+	}
+	return included, excluded
+}
+
+// DynamicScoringConfig configures the decaying challenge value used by the "dynamic" scoring strategy.
+// A challenge is worth Max points until it is solved, then drops quadratically and reaches Min after Decay solves.
+type DynamicScoringConfig struct {
+	Max   int `json:"max"`
+	Min   int `json:"min"`
+	Decay int `json:"decay"`
+}
+
+// FlatScoringConfig configures the "flat" scoring strategy.
+type FlatScoringConfig struct {
+	// Points awarded for each solved challenge, regardless of its difficulty.
+	Points int `json:"points"`
+}
+
 // ThemeConfig customizes the look of the MultiJuicer balancer UI itself
 type ThemeConfig struct {
 	// LogoURL overrides the MultiJuicer logo shown in the balancer UI.
@@ -66,8 +242,11 @@ type ThemeConfig struct {
 
 type CleanupConfig struct {
 	// MaxInactive is the duration of inactivity after which a JuiceShop deployment is deleted.
-	// When zero the cleanup loop is disabled.
+	// When zero inactive deployments aren't deleted.
 	MaxInactive time.Duration
+	// HibernateAfter is the duration of inactivity after which a JuiceShop deployment is scaled down to zero replicas. The team keeps its passcode and progress and the instance wakes up on the next request.
+	// Should be shorter than MaxInactive. When zero instances aren't hibernated. The cleanup loop is disabled when both are zero.
+	HibernateAfter time.Duration
 }
 
 type AdminConfig struct {
@@ -93,6 +272,25 @@ type LLMConfig struct {
 	ApiKey string `json:"-"`
 }
 
+// WarmPoolConfig keeps a number of JuiceShop instances started and ready before any team joins.
+// Joining teams claim one of them instead of waiting for a new JuiceShop to start, e.g. when all teams join at the start of an event.
+type WarmPoolConfig struct {
+	// Size is the number of unclaimed instances the leader keeps ready. Disabled when 0.
+	// Pool instances are not counted towards MaxInstances.
+	Size int `json:"size"`
+}
+
+// NetworkPolicyConfig isolates the JuiceShop of every team with a NetworkPolicy, so that a compromised JuiceShop can't reach the instances of other teams or forge solves on the private MultiJuicer port.
+type NetworkPolicyConfig struct {
+	Enabled bool `json:"enabled"`
+	// Ingress rules added to the policy, by default only the MultiJuicer pods can reach the JuiceShop
+	Ingress []networkingv1.NetworkPolicyIngressRule `json:"ingress"`
+	// Egress rules added to the policy, by default the JuiceShop can only reach the webhook / LLM gateway of MultiJuicer and DNS
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress"`
+	// DNS are the peers the JuiceShop can reach on port 53, the kube-dns pods in the kube-system namespace when empty
+	DNS []networkingv1.NetworkPolicyPeer `json:"dns"`
+}
+
 type JuiceShopConfig struct {
 	Image            string                        `json:"image"`
 	Tag              string                        `json:"tag"`
@@ -114,6 +312,10 @@ type JuiceShopConfig struct {
 
 	LLM LLMConfig `json:"llm"`
 
+	WarmPool WarmPoolConfig `json:"warmPool"`
+
+	NetworkPolicy NetworkPolicyConfig `json:"networkPolicy"`
+
 	JuiceShopPodConfig JuiceShopPodConfig `json:"pod"`
 }
 
@@ -144,6 +346,12 @@ type TeamScore struct {
 	Challenges        []ChallengeProgress `json:"challenges"`
 	LastUpdate        time.Time           `json:"lastUpdate"`
 	InstanceReadiness bool                `json:"readiness"`
+	// Hints unlocked by the team. Their cost is already subtracted from the Score
+	Hints []HintUnlock `json:"hints,omitempty"`
+	// Adjustments awarded to or imposed on the team by admins. Their points are already included in the Score
+	Adjustments []ScoreAdjustment `json:"adjustments,omitempty"`
+	// CheatDecision is the decision for the latest cheat score of the team, see CheatScoreConfig. Disqualified teams are left out of the top scores.
+	CheatDecision string `json:"cheatDecision,omitempty"`
 }
 
 func (t *TeamScore) EqualsIgnoringLastUpdate(other *TeamScore) bool {
@@ -164,6 +372,15 @@ func (t *TeamScore) EqualsIgnoringLastUpdate(other *TeamScore) bool {
 			return false
 		}
 	}
+	if !slices.Equal(t.Hints, other.Hints) {
+		return false
+	}
+	if !slices.Equal(t.Adjustments, other.Adjustments) {
+		return false
+	}
+	if t.CheatDecision != other.CheatDecision {
+		return false
+	}
 	return t.InstanceReadiness == other.InstanceReadiness
 }
 
@@ -171,6 +388,35 @@ func (t *TeamScore) EqualsIgnoringLastUpdate(other *TeamScore) bool {
 type ChallengeProgress struct {
 	Key      string    `json:"key"`
 	SolvedAt time.Time `json:"solvedAt"`
+	// Bonus points awarded on top of the challenge points for being one of the first teams to solve the challenge
+	Bonus int `json:"bonus,omitempty"`
+}
+
+// HintUnlock represents a challenge hint a team paid points to reveal
+type HintUnlock struct {
+	Key        string    `json:"key"`
+	UnlockedAt time.Time `json:"unlockedAt"`
+	// Cost is the amount of points subtracted from the team score. Stored with the unlock so later config changes don't retroactively change scores
+	Cost int `json:"cost"`
+}
+
+// ScoreAdjustment represents points an admin added to or removed from the score of a team, e.g. a bonus for a write-up or a penalty for attacking the infrastructure
+type ScoreAdjustment struct {
+	// Points added to the score, negative for penalties
+	Points    int       `json:"points"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SolveEvidence is what the instance of a team reported about a solved challenge in the solutions webhook, kept for training debriefs.
+// Persisted in the solves annotation of the deployment, the webhook handler limits the length of the fields.
+type SolveEvidence struct {
+	Key      string `json:"key"`
+	SolvedAt string `json:"solvedAt"`
+	Evidence string `json:"evidence,omitempty"`
+	// IssuerVersion and IssuerHostName identify the instance that reported the solve, e.g. to spot solves reported after a restart or a version change
+	IssuerVersion  string `json:"issuerVersion,omitempty"`
+	IssuerHostName string `json:"issuerHostName,omitempty"`
 }
 
 // Notification represents a system-wide notification
@@ -194,6 +440,8 @@ type ScoringService interface {
 	WaitForUpdatesNewerThan(ctx context.Context, lastSeenUpdate time.Time) []*TeamScore
 	WaitForUpdatesNewerThanWithTimestamp(ctx context.Context, lastSeenUpdate time.Time) ([]*TeamScore, time.Time)
 	WaitForTeamUpdatesNewerThan(ctx context.Context, team string, lastSeenUpdate time.Time) *TeamScore
+	// GetChallengePoints returns the points a solve of the challenge is currently worth under the configured scoring strategy.
+	GetChallengePoints(challengeKey string) int
 	CalculateAndCacheScoreBoard(ctx context.Context) error
 	StartingScoringWorker(ctx context.Context)
 }
@@ -210,6 +458,84 @@ type NotificationService interface {
 	IsScoreboardFrozen() bool
 }
 
+// Application is the vulnerable application hosted for every team. The application package contains the built-in JuiceShop implementation.
+type Application interface {
+	// Port the application listens on
+	Port() int32
+	// Container returns the application specific parts of the container of a team: ports, probes, environment and config mounts.
+	// MultiJuicer adds the image, resources and security context as well as the globally configured environment and mounts.
+	Container() corev1.Container
+	// Volumes returns the volumes mounted by the Container
+	Volumes() []corev1.Volume
+	// Challenges returns all challenges of the application
+	Challenges() ([]JuiceShopChallenge, error)
+	// FetchProgress returns the challenges solved in the instance reachable under baseUrl
+	FetchProgress(ctx context.Context, baseUrl string) ([]ChallengeStatus, error)
+	// RestoreProgress marks the challenges as solved in the instance reachable under baseUrl, e.g. after the instance restarted
+	RestoreProgress(ctx context.Context, baseUrl string, solved []ChallengeStatus) error
+	// CtfFlag returns the flag the instances report in the solutions webhook of the challenge. Returns "" if the flag can't be computed, the webhook isn't verified then.
+	CtfFlag(challenge JuiceShopChallenge) string
+}
+
+// ChallengeStatus is a challenge solved in the instance of a team, as persisted in the challenges annotation of the deployment
+type ChallengeStatus struct {
+	Key      string `json:"key"`
+	SolvedAt string `json:"solvedAt"`
+}
+
+// EventType identifies what kind of event was recorded in the EventLog
+type EventType string
+
+const (
+	EventTypeTeamCreated       EventType = "team_created"
+	EventTypeChallengeSolved   EventType = "challenge_solved"
+	EventTypeTeamDeleted       EventType = "team_deleted"
+	EventTypePasscodeReset     EventType = "passcode_reset"
+	EventTypeInstanceRestarted EventType = "instance_restarted"
+	// EventTypeInstanceHibernated is recorded when an inactive instance got scaled down, EventTypeInstanceWokenUp when the next request of the team woke it up again
+	EventTypeInstanceHibernated EventType = "instance_hibernated"
+	EventTypeInstanceWokenUp    EventType = "instance_woken_up"
+	// EventTypeInstanceOverridesSet is recorded when an admin changed the image, tag, env or resources of the instance of a team
+	EventTypeInstanceOverridesSet EventType = "instance_overrides_set"
+	EventTypeNotificationSet      EventType = "notification_set"
+	EventTypeClockSet             EventType = "clock_set"
+	// EventTypeSolveRejected is recorded when a solutions webhook of the instance of the team was rejected, e.g. because of an invalid CTF flag
+	EventTypeSolveRejected EventType = "solve_rejected"
+	// EventTypeInstanceDown is recorded when the last ready pod of a running instance went away, e.g. because the JuiceShop crashed
+	EventTypeInstanceDown EventType = "instance_down"
+	// EventTypeTeamSuspicious and EventTypeTeamDisqualified are recorded when a new cheat score of the team crossed a threshold of the CheatScoreConfig
+	EventTypeTeamSuspicious   EventType = "team_suspicious"
+	EventTypeTeamDisqualified EventType = "team_disqualified"
+	// EventTypeSolveGranted and EventTypeSolveRevoked are recorded when an admin marked a challenge as solved or unsolved for a team
+	EventTypeSolveGranted EventType = "solve_granted"
+	EventTypeSolveRevoked EventType = "solve_revoked"
+	// EventTypeScoreAdjusted is recorded when an admin awarded points to or took points from a team
+	EventTypeScoreAdjusted EventType = "score_adjusted"
+)
+
+// Event is a single entry of the EventLog
+type Event struct {
+	Type      EventType `json:"type"`
+	Team      string    `json:"team,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Actor is who triggered the event, e.g. "admin" for admin actions. Empty for events triggered by the team itself or by the system
+	Actor        string `json:"actor,omitempty"`
+	ChallengeKey string `json:"challengeKey,omitempty"`
+	// Details contains additional free-form information about the event, e.g. the reason for a deletion
+	Details string `json:"details,omitempty"`
+}
+
+// EventLog is an append-only log of everything that happened during the event.
+// Unlike the team deployment annotations it survives the deletion of team instances.
+type EventLog interface {
+	Append(ctx context.Context, event Event) error
+	// GetEvents returns all events, sorted by timestamp, oldest first.
+	GetEvents() []Event
+	// GetEventsSince returns the events appended after the given number of events per chunk and the number of events per chunk now, to pass to the next call.
+	GetEventsSince(offsets map[int]int) ([]Event, map[int]int)
+	StartEventLogWatcher(ctx context.Context)
+}
+
 // ParseLogLevel converts a log level string to a slog.Level.
 // Valid values: "debug", "info", "warn"/"warning", "error". Defaults to info.
 func ParseLogLevel(level string) slog.Level {
@@ -226,7 +552,7 @@ func ParseLogLevel(level string) slog.Level {
 }
 
 func getJuiceShopUrlForTeam(team string, bundle *Bundle) string {
-	return fmt.Sprintf("http://juiceshop-%s.%s.svc.cluster.local:3000", team, bundle.RuntimeEnvironment.Namespace)
+	return fmt.Sprintf("http://juiceshop-%s.%s.svc.cluster.local:%d", team, bundle.RuntimeEnvironment.Namespace, bundle.Application.Port())
 }
 
 func New() *Bundle {
@@ -238,6 +564,10 @@ func New() *Bundle {
 	if err != nil {
 		panic(err.Error())
 	}
+	dynamicClient, err := dynamic.NewForConfig(kubeClientConfig)
+	if err != nil {
+		panic(err.Error())
+	}
 
 	namespace := os.Getenv("NAMESPACE")
 	if namespace == "" {
@@ -268,6 +598,13 @@ func New() *Bundle {
 		panic(errors.New("teamPasscodeLength must be a multiple of 4. e.g. 8, 12, 16"))
 	}
 
+	if err := applyScoringConfigDefaults(&config.ScoringConfig); err != nil {
+		panic(err)
+	}
+	if err := applyNotifierConfigDefaults(config.Notifiers, os.Getenv); err != nil {
+		panic(err)
+	}
+
 	config.CookieConfig.SigningKey = cookieSigningKey
 	config.AdminConfig = &AdminConfig{Password: adminPasswordKey}
 	config.ContentSecurityPolicy = os.Getenv("MULTI_JUICER_CONTENT_SECURITY_POLICY")
@@ -292,21 +629,21 @@ func New() *Bundle {
 		}
 		config.Cleanup.MaxInactive = maxInactive
 	}
-
-	// read /challenges.json file
-	challengesBytes, err := os.ReadFile("/challenges.json")
-	if err != nil {
-		panic(err)
+	if hibernateAfterString := os.Getenv("HIBERNATE_INACTIVE_DURATION"); hibernateAfterString != "" {
+		hibernateAfter, err := time.ParseDuration(hibernateAfterString)
+		if err != nil {
+			panic(fmt.Errorf("could not parse HIBERNATE_INACTIVE_DURATION %q: %w", hibernateAfterString, err))
+		}
+		config.Cleanup.HibernateAfter = hibernateAfter
 	}
 
-	var challenges []JuiceShopChallenge
-	err = json.Unmarshal(challengesBytes, &challenges)
-	if err != nil {
-		panic(err)
-	}
+	juiceShopInformer, juiceShopLister := NewJuiceShopInformer(clientset, namespace)
 
 	return &Bundle{
 		ClientSet:             clientset,
+		JuiceShopInformer:     juiceShopInformer,
+		JuiceShopLister:       juiceShopLister,
+		DynamicClient:         dynamicClient,
 		StaticAssetsDirectory: "/public/",
 		RuntimeEnvironment: RuntimeEnvironment{
 			Namespace: namespace,
@@ -316,11 +653,193 @@ func New() *Bundle {
 		BcryptRounds:               bcrypt.DefaultCost,
 		Log:                        slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: ParseLogLevel(os.Getenv("LOG_LEVEL"))})),
 		LongPollDefaultWaitTimeout: 25 * time.Second,
+		Broker:                     longpoll.NewBroker(),
 		Config:                     config,
-		JuiceShopChallenges:        challenges,
 	}
 }
 
+// LoadChallenges loads the challenges of the Application and applies the challengeFilter config
+func (b *Bundle) LoadChallenges() error {
+	challenges, err := b.Application.Challenges()
+	if err != nil {
+		return fmt.Errorf("failed to load the challenges of the application: %w", err)
+	}
+
+	challenges, excludedChallengeKeys := FilterChallenges(challenges, b.Config.ChallengeFilter)
+	if len(challenges) == 0 {
+		return errors.New("challengeFilter excludes all challenges")
+	}
+	b.JuiceShopChallenges = challenges
+	b.ExcludedChallengeKeys = excludedChallengeKeys
+	return nil
+}
+
+// JuiceShopInstanceResource is the custom resource describing the JuiceShop instance of a team, see the instances package
+var JuiceShopInstanceResource = schema.GroupVersionResource{Group: "multi-juicer.owasp-juice.shop", Version: "v1alpha1", Resource: "juiceshopinstances"}
+
+// WarmPoolLabel marks the unclaimed deployments of the warm pool. The label is removed when a team claims the deployment.
+const WarmPoolLabel = "multi-juicer.owasp-juice.shop/pool"
+
+// JuiceShopLabelSelector selects the JuiceShop deployments of all teams. Unclaimed deployments of the warm pool don't belong to a team and are excluded.
+const JuiceShopLabelSelector = "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer,!" + WarmPoolLabel
+
+// teamIndex indexes the cached JuiceShop deployments by their team label
+const teamIndex = "team"
+
+// InstanceIdentityPrefix marks signed identities of JuiceShop deployments, as opposed to signed team names.
+// Deployments of the warm pool are started before they belong to a team, so their LLM token contains the name of the deployment and gets resolved to the team on every request.
+// Team names can't contain a colon so the two can't be confused.
+const InstanceIdentityPrefix = "instance:"
+
+// WebhookIdentityPrefix marks signed identities that are only valid as token of the solutions webhook. The token is handed to the JuiceShop,
+// without the prefix it would be identical to the team cookie and everyone able to read it could take over the team.
+const WebhookIdentityPrefix = "webhook:"
+
+// NewJuiceShopInformer creates an informer and lister caching the JuiceShop deployments in the namespace. The informer still has to be started.
+func NewJuiceShopInformer(clientset kubernetes.Interface, namespace string) (cache.SharedIndexInformer, appslisters.DeploymentLister) {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		clientset,
+		0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = JuiceShopLabelSelector
+		}),
+	)
+	deployments := factory.Apps().V1().Deployments()
+	informer := deployments.Informer()
+	err := informer.AddIndexers(cache.Indexers{
+		teamIndex: func(obj any) ([]string, error) {
+			deployment, ok := obj.(*appsv1.Deployment)
+			if !ok || deployment.Labels["team"] == "" {
+				return nil, nil
+			}
+			return []string{deployment.Labels["team"]}, nil
+		},
+	})
+	if err != nil {
+		// only fails when the informer was already started
+		panic(err)
+	}
+	return informer, deployments.Lister()
+}
+
+// GetJuiceShopDeployment returns the deployment of the team from the informer cache.
+// Deployments claimed from the warm pool keep the name they got in the pool, these are found via their team label.
+func (bundle *Bundle) GetJuiceShopDeployment(team string) (*appsv1.Deployment, error) {
+	deployment, err := bundle.JuiceShopLister.Deployments(bundle.RuntimeEnvironment.Namespace).Get(fmt.Sprintf("juiceshop-%s", team))
+	if !apierrors.IsNotFound(err) {
+		return deployment, err
+	}
+	objects, indexErr := bundle.JuiceShopInformer.GetIndexer().ByIndex(teamIndex, team)
+	if indexErr != nil {
+		return nil, indexErr
+	}
+	for _, object := range objects {
+		if deployment, ok := object.(*appsv1.Deployment); ok && deployment.Namespace == bundle.RuntimeEnvironment.Namespace {
+			return deployment, nil
+		}
+	}
+	return nil, err
+}
+
+// JuiceShopDeploymentName returns the name of the deployment of the team, to address it in calls to the Kubernetes API.
+// Falls back to the default name juiceshop-<team> if the team isn't in the informer cache (yet).
+func (bundle *Bundle) JuiceShopDeploymentName(team string) string {
+	if deployment, err := bundle.GetJuiceShopDeployment(team); err == nil {
+		return deployment.Name
+	}
+	return fmt.Sprintf("juiceshop-%s", team)
+}
+
+// VerifiesCtfFlags returns true if solves reported by the instances are verified with the CTF flag of the challenge, i.e. if the application can compute the flags.
+// Progress that can't be verified, e.g. fetched from the instance itself, must not be recorded as new solves then.
+func (bundle *Bundle) VerifiesCtfFlags() bool {
+	return len(bundle.JuiceShopChallenges) > 0 && bundle.Application.CtfFlag(bundle.JuiceShopChallenges[0]) != ""
+}
+
+// GetTeamOfJuiceShopDeployment returns the team the JuiceShop deployment with the name belongs to. Returns false if the deployment doesn't exist or wasn't claimed by a team yet.
+func (bundle *Bundle) GetTeamOfJuiceShopDeployment(name string) (string, bool) {
+	deployment, err := bundle.JuiceShopLister.Deployments(bundle.RuntimeEnvironment.Namespace).Get(name)
+	if err != nil || deployment.Labels["team"] == "" {
+		return "", false
+	}
+	return deployment.Labels["team"], true
+}
+
+// applyNotifierConfigDefaults reads the urls of the notifiers configured without one from the environment and validates the notifiers
+func applyNotifierConfigDefaults(notifiers []NotifierConfig, getenv func(string) string) error {
+	for i := range notifiers {
+		notifier := &notifiers[i]
+		if notifier.Name == "" {
+			notifier.Name = fmt.Sprintf("notifier-%d", i)
+		}
+		switch notifier.Type {
+		case NotifierTypeSlack, NotifierTypeDiscord, NotifierTypeTeams, NotifierTypeGeneric:
+		default:
+			return fmt.Errorf("unknown type %q of notifier %q. must be one of: slack, discord, teams, generic", notifier.Type, notifier.Name)
+		}
+		if notifier.Url == "" {
+			notifier.Url = getenv(fmt.Sprintf("NOTIFIER_%d_URL", i))
+		}
+		if notifier.Url == "" {
+			return fmt.Errorf("notifier %q has no url. set its url or environment variable 'NOTIFIER_%d_URL'", notifier.Name, i)
+		}
+	}
+	return nil
+}
+
+func applyScoringConfigDefaults(scoring *ScoringConfig) error {
+	switch scoring.Strategy {
+	case "":
+		scoring.Strategy = ScoringStrategyStatic
+	case ScoringStrategyStatic:
+	case ScoringStrategyDynamic:
+		if scoring.Dynamic.Max == 0 {
+			scoring.Dynamic.Max = 500
+		}
+		if scoring.Dynamic.Min == 0 {
+			scoring.Dynamic.Min = 100
+		}
+		if scoring.Dynamic.Decay == 0 {
+			scoring.Dynamic.Decay = 20
+		}
+		if scoring.Dynamic.Min > scoring.Dynamic.Max {
+			return errors.New("scoring.dynamic.min must not be greater than scoring.dynamic.max")
+		}
+		if scoring.Dynamic.Decay < 0 {
+			return errors.New("scoring.dynamic.decay must be positive")
+		}
+	case ScoringStrategyFlat:
+		if scoring.Flat.Points == 0 {
+			scoring.Flat.Points = 10
+		}
+	default:
+		return fmt.Errorf("unknown scoring strategy %q. must be one of: static, dynamic, flat", scoring.Strategy)
+	}
+	if scoring.HintCost < 0 {
+		return errors.New("scoring.hintCost must not be negative")
+	}
+	if scoring.CheatScore.SuspiciousThreshold < 0 || scoring.CheatScore.DisqualifyThreshold < 0 {
+		return errors.New("scoring.cheatScore thresholds must not be negative")
+	}
+	for _, percentage := range scoring.EarlySolveBonusPercentages {
+		if percentage < 0 {
+			return errors.New("scoring.earlySolveBonusPercentages must not contain negative values")
+		}
+	}
+	for key, points := range scoring.PointOverrides {
+		if points < 0 {
+			return fmt.Errorf("scoring.pointOverrides.%s must not be negative", key)
+		}
+	}
+	for category, multiplier := range scoring.CategoryMultipliers {
+		if multiplier < 0 {
+			return fmt.Errorf("scoring.categoryMultipliers.%s must not be negative", category)
+		}
+	}
+	return nil
+}
+
 func readConfigFromFile(filePath string) (*Config, error) {
 	var config Config
 
@@ -2,16 +2,145 @@ package bundle
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// stubApplication implements the parts of the Application used by the bundle
+type stubApplication struct {
+	Application
+	port       int32
+	challenges []JuiceShopChallenge
+}
+
+func (a *stubApplication) Port() int32 { return a.port }
+
+func (a *stubApplication) Challenges() ([]JuiceShopChallenge, error) { return a.challenges, nil }
+
 func TestGetJuiceShopUrlForTeam(t *testing.T) {
-	t.Run("should include team and namespace in the url", func(t *testing.T) {
+	t.Run("should include team, namespace and the port of the application in the url", func(t *testing.T) {
 		assert.Equal(t, "http://juiceshop-foobar.test-namespace.svc.cluster.local:3000", getJuiceShopUrlForTeam("foobar", &Bundle{
 			RuntimeEnvironment: RuntimeEnvironment{
 				Namespace: "test-namespace",
 			},
+			Application: &stubApplication{port: 3000},
 		}))
 	})
 }
+
+func TestLoadChallenges(t *testing.T) {
+	application := &stubApplication{challenges: []JuiceShopChallenge{
+		{Key: "scoreBoardChallenge", Difficulty: 1},
+		{Key: "nullByteChallenge", Difficulty: 4},
+	}}
+
+	t.Run("loads the challenges of the application and applies the challenge filter", func(t *testing.T) {
+		b := &Bundle{Application: application, Config: &Config{ChallengeFilter: ChallengeFilter{MaxDifficulty: 3}}}
+
+		assert.NoError(t, b.LoadChallenges())
+		assert.Equal(t, []JuiceShopChallenge{{Key: "scoreBoardChallenge", Difficulty: 1}}, b.JuiceShopChallenges)
+		assert.Equal(t, map[string]bool{"nullByteChallenge": true}, b.ExcludedChallengeKeys)
+	})
+
+	t.Run("fails if the filter excludes all challenges", func(t *testing.T) {
+		b := &Bundle{Application: application, Config: &Config{ChallengeFilter: ChallengeFilter{Include: []string{"unknownChallenge"}}}}
+
+		assert.Error(t, b.LoadChallenges())
+	})
+}
+
+func TestFilterChallenges(t *testing.T) {
+	challenges := []JuiceShopChallenge{
+		{Key: "scoreBoardChallenge", Category: "Miscellaneous", Difficulty: 1, Tags: []string{"Tutorial", "Code Analysis"}},
+		{Key: "localXssChallenge", Category: "XSS", Difficulty: 1, Tags: []string{"Tutorial", "Danger Zone"}},
+		{Key: "loginAdminChallenge", Category: "Injection", Difficulty: 2, Tags: []string{"Tutorial", "Good for Demos"}},
+		{Key: "nullByteChallenge", Category: "Improper Input Validation", Difficulty: 4},
+		{Key: "unionSqlInjectionChallenge", Category: "Injection", Difficulty: 4},
+	}
+	keys := func(challenges []JuiceShopChallenge) []string {
+		keys := []string{}
+		for _, challenge := range challenges {
+			keys = append(keys, challenge.Key)
+		}
+		return keys
+	}
+
+	t.Run("includes all challenges with an empty filter", func(t *testing.T) {
+		included, excluded := FilterChallenges(challenges, ChallengeFilter{})
+		assert.Len(t, included, 5)
+		assert.Empty(t, excluded)
+	})
+
+	t.Run("filters by category and difficulty", func(t *testing.T) {
+		included, excluded := FilterChallenges(challenges, ChallengeFilter{Categories: []string{"injection", "XSS"}, MaxDifficulty: 3})
+		assert.Equal(t, []string{"localXssChallenge", "loginAdminChallenge"}, keys(included))
+		assert.Equal(t, map[string]bool{"scoreBoardChallenge": true, "nullByteChallenge": true, "unionSqlInjectionChallenge": true}, excluded)
+	})
+
+	t.Run("filters by tag and denylist", func(t *testing.T) {
+		included, _ := FilterChallenges(challenges, ChallengeFilter{Tags: []string{"tutorial"}, Exclude: []string{"localXssChallenge"}})
+		assert.Equal(t, []string{"scoreBoardChallenge", "loginAdminChallenge"}, keys(included))
+	})
+
+	t.Run("filters by allowlist and minimum difficulty", func(t *testing.T) {
+		included, _ := FilterChallenges(challenges, ChallengeFilter{Include: []string{"scoreBoardChallenge", "nullByteChallenge"}, MinDifficulty: 2})
+		assert.Equal(t, []string{"nullByteChallenge"}, keys(included))
+	})
+}
+
+func TestApplyNotifierConfigDefaults(t *testing.T) {
+	getenv := func(key string) string {
+		return map[string]string{"NOTIFIER_1_URL": "https://hooks.slack.com/services/secret"}[key]
+	}
+
+	t.Run("reads missing urls from the environment", func(t *testing.T) {
+		notifiers := []NotifierConfig{
+			{Name: "bot", Type: NotifierTypeGeneric, Url: "http://bot.example.com/events"},
+			{Type: NotifierTypeSlack},
+		}
+
+		assert.NoError(t, applyNotifierConfigDefaults(notifiers, getenv))
+		assert.Equal(t, "http://bot.example.com/events", notifiers[0].Url)
+		assert.Equal(t, "https://hooks.slack.com/services/secret", notifiers[1].Url)
+		assert.Equal(t, "notifier-1", notifiers[1].Name)
+	})
+
+	t.Run("fails for unknown types", func(t *testing.T) {
+		assert.Error(t, applyNotifierConfigDefaults([]NotifierConfig{{Type: "irc", Url: "http://irc.example.com"}}, getenv))
+	})
+
+	t.Run("fails if the url is neither configured nor set in the environment", func(t *testing.T) {
+		assert.Error(t, applyNotifierConfigDefaults([]NotifierConfig{{Type: NotifierTypeDiscord}}, getenv))
+	})
+}
+
+func TestCheatScoreConfigDecide(t *testing.T) {
+	thresholds := CheatScoreConfig{SuspiciousThreshold: 0.5, DisqualifyThreshold: 0.8}
+
+	assert.Equal(t, "", thresholds.Decide(0.2))
+	assert.Equal(t, CheatDecisionSuspicious, thresholds.Decide(0.5))
+	assert.Equal(t, CheatDecisionDisqualified, thresholds.Decide(0.95))
+	assert.Equal(t, "", CheatScoreConfig{}.Decide(1), "thresholds of 0 are disabled")
+	assert.Equal(t, CheatDecisionDisqualified, CheatScoreConfig{DisqualifyThreshold: 0.8}.Decide(0.9))
+}
+
+func TestTeamScoreEqualsIgnoringLastUpdate(t *testing.T) {
+	createdAt := time.Date(2024, 11, 1, 20, 0, 0, 0, time.UTC)
+	score := TeamScore{Name: "foobar", Score: 20, LastUpdate: createdAt, Adjustments: []ScoreAdjustment{{Points: 20, Reason: "write-up", CreatedAt: createdAt}}}
+
+	same := score
+	same.LastUpdate = createdAt.Add(time.Minute)
+	assert.True(t, score.EqualsIgnoringLastUpdate(&same))
+
+	// an adjustment replaced by one with the same points keeps score and length
+	replaced := score
+	replaced.Adjustments = []ScoreAdjustment{{Points: 20, Reason: "bug report", CreatedAt: createdAt}}
+	assert.False(t, score.EqualsIgnoringLastUpdate(&replaced))
+
+	unlockedOtherHint := score
+	unlockedOtherHint.Hints = []HintUnlock{{Key: "scoreBoardChallenge", UnlockedAt: createdAt}}
+	otherHint := score
+	otherHint.Hints = []HintUnlock{{Key: "nullByteChallenge", UnlockedAt: createdAt}}
+	assert.False(t, unlockedOtherHint.EqualsIgnoringLastUpdate(&otherHint))
+}
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/instances"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const cleanupInterval = 1 * time.Minute
@@ -15,19 +17,24 @@ const cleanupInterval = 1 * time.Minute
 type Summary struct {
 	SuccessfulDeletions int
 	FailedDeletions     int
+	Hibernations        int
 }
 
 // StartPeriodicCleanup runs RunCleanup on a fixed interval until ctx is cancelled.
 // Must run on at most one multi-juicer replica at a time (gated via leader election).
-// When b.Config.Cleanup.MaxInactive is zero the cleanup loop is disabled.
+// When b.Config.Cleanup.MaxInactive and b.Config.Cleanup.HibernateAfter are zero the cleanup loop is disabled.
 func StartPeriodicCleanup(ctx context.Context, b *bundle.Bundle) {
 	maxInactive := b.Config.Cleanup.MaxInactive
-	if maxInactive == 0 {
-		b.Log.Info("MAX_INACTIVE_DURATION not set; cleanup loop will not run")
+	hibernateAfter := b.Config.Cleanup.HibernateAfter
+	if maxInactive == 0 && hibernateAfter == 0 {
+		b.Log.Info("MAX_INACTIVE_DURATION and HIBERNATE_INACTIVE_DURATION not set; cleanup loop will not run")
 		return
 	}
+	if maxInactive != 0 && hibernateAfter >= maxInactive {
+		b.Log.Warn("HIBERNATE_INACTIVE_DURATION isn't shorter than MAX_INACTIVE_DURATION; inactive instances get deleted before they could be hibernated", "maxInactive", maxInactive, "hibernateAfter", hibernateAfter)
+	}
 
-	b.Log.Info("Starting periodic cleanup of inactive JuiceShop deployments", "interval", cleanupInterval, "maxInactive", maxInactive)
+	b.Log.Info("Starting periodic cleanup of inactive JuiceShop deployments", "interval", cleanupInterval, "maxInactive", maxInactive, "hibernateAfter", hibernateAfter)
 
 	ticker := time.NewTicker(cleanupInterval)
 	defer ticker.Stop()
@@ -39,8 +46,8 @@ func StartPeriodicCleanup(ctx context.Context, b *bundle.Bundle) {
 				return
 			}
 			b.Log.Error("Failed to list deployments during cleanup", "error", err)
-		} else if summary.SuccessfulDeletions > 0 || summary.FailedDeletions > 0 {
-			b.Log.Info("Cleanup pass finished", "deleted", summary.SuccessfulDeletions, "failed", summary.FailedDeletions)
+		} else if summary.SuccessfulDeletions > 0 || summary.FailedDeletions > 0 || summary.Hibernations > 0 {
+			b.Log.Info("Cleanup pass finished", "deleted", summary.SuccessfulDeletions, "failed", summary.FailedDeletions, "hibernated", summary.Hibernations)
 		}
 
 		select {
@@ -51,22 +58,22 @@ func StartPeriodicCleanup(ctx context.Context, b *bundle.Bundle) {
 	}
 }
 
+// RunCleanup deletes the JuiceShop deployments inactive for longer than MaxInactive and hibernates the ones inactive for longer than HibernateAfter
 func RunCleanup(ctx context.Context, b *bundle.Bundle, currentTime time.Time) (Summary, error) {
 	maxInactive := b.Config.Cleanup.MaxInactive
-	deployments, err := b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-	})
+	hibernateAfter := b.Config.Cleanup.HibernateAfter
+	deployments, err := b.JuiceShopLister.List(labels.Everything())
 	if err != nil {
 		return Summary{}, err
 	}
 
-	if len(deployments.Items) == 0 {
+	if len(deployments) == 0 {
 		b.Log.Info("No JuiceShop deployments found. Nothing to do.")
 	}
 
 	summary := Summary{}
 
-	for _, deployment := range deployments.Items {
+	for _, deployment := range deployments {
 		lastConnectedTimestampString, hasAnnotation := deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"]
 		if !hasAnnotation || lastConnectedTimestampString == "" {
 			b.Log.Warn("Skipping deployment as it has no lastRequest annotation", "deployment", deployment.Name)
@@ -79,10 +86,11 @@ func RunCleanup(ctx context.Context, b *bundle.Bundle, currentTime time.Time) (S
 		}
 
 		name := deployment.Name
-		if currentTime.Sub(time.UnixMilli(lastConnectedTimestamp)) > maxInactive {
+		inactive := currentTime.Sub(time.UnixMilli(lastConnectedTimestamp))
+		switch {
+		case maxInactive != 0 && inactive > maxInactive:
 			b.Log.Info("Deleting instance as it has been inactive for too long", "instance", name, "maxInactive", maxInactive.String())
-			// The service and secret are owned by the deployment via OwnerReferences and will be garbage collected by Kubernetes.
-			err = b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+			err = instances.Delete(ctx, b, deployment.Labels["team"])
 			if err != nil && !errors.IsNotFound(err) {
 				b.Log.Error("Failed to delete deployment", "deployment", name, "error", err)
 				summary.FailedDeletions++
@@ -90,10 +98,35 @@ func RunCleanup(ctx context.Context, b *bundle.Bundle, currentTime time.Time) (S
 			}
 			summary.SuccessfulDeletions++
 			b.Log.Info("Successfully deleted instance", "instance", name)
-		} else {
+
+			if err := b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeTeamDeleted, Team: deployment.Labels["team"], Details: "inactive"}); err != nil {
+				b.Log.Error("Failed to record team deletion in event log", "instance", name, "error", err)
+			}
+		case hibernateAfter != 0 && inactive > hibernateAfter && !isScaledDown(deployment):
+			team := deployment.Labels["team"]
+			hibernated, err := instances.SetHibernated(ctx, b, team, true)
+			if err != nil {
+				b.Log.Error("Failed to hibernate instance", "instance", name, "error", err)
+				continue
+			}
+			if !hibernated {
+				continue
+			}
+			summary.Hibernations++
+			b.Log.Info("Hibernated instance as it has been inactive for a while", "instance", name, "hibernateAfter", hibernateAfter.String())
+
+			if err := b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeInstanceHibernated, Team: team}); err != nil {
+				b.Log.Error("Failed to record instance hibernation in event log", "instance", name, "error", err)
+			}
+		default:
 			b.Log.Debug("Skipping deployment as it has been active recently", "deployment", name)
 		}
 	}
 
 	return summary, nil
 }
+
+// isScaledDown returns true for deployments already hibernated or paused
+func isScaledDown(deployment *appsv1.Deployment) bool {
+	return deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0
+}
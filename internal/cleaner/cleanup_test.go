@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/instances"
 	"github.com/juice-shop/multi-juicer/internal/testutil"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -146,4 +147,58 @@ func TestRunCleanup(t *testing.T) {
 			t.Errorf("Expected 1 failed deletion, got: %v", summary)
 		}
 	})
+
+	t.Run("Idle Deployment - Should Be Hibernated", func(t *testing.T) {
+		clientset := fake.NewClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "multi-juicer",
+				Namespace: testNamespace,
+				UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+			},
+		})
+		b := newTestBundle(clientset, 24*time.Hour)
+		b.Config.Cleanup.HibernateAfter = 30 * time.Minute
+
+		deployment, err := instances.Provision(ctx, b, "team1", "passcode-hash")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lastRequest := strconv.FormatInt(time.Now().Add(-60*time.Minute).UnixMilli(), 10)
+		deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"] = lastRequest
+		if _, err := clientset.AppsV1().Deployments(testNamespace).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		testutil.WaitForJuiceShopDeployment(b, "team1", func(deployment *appsv1.Deployment) bool {
+			return deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"] == lastRequest
+		})
+
+		summary, err := RunCleanup(ctx, b, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Hibernations != 1 || summary.SuccessfulDeletions != 0 {
+			t.Errorf("Expected 1 hibernation and no deletions, got: %v", summary)
+		}
+
+		instance, err := instances.Get(ctx, b, "team1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !instance.Spec.Hibernated {
+			t.Errorf("Expected the instance to be hibernated")
+		}
+
+		events := b.EventLog.GetEvents()
+		if len(events) != 1 || events[0].Type != bundle.EventTypeInstanceHibernated || events[0].Team != "team1" {
+			t.Errorf("Expected an instance_hibernated event, got: %v", events)
+		}
+
+		summary, err = RunCleanup(ctx, b, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Hibernations != 0 {
+			t.Errorf("Expected an already hibernated instance to be skipped, got: %v", summary)
+		}
+	})
 }
@@ -0,0 +1,356 @@
+package eventlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	chunkLabelSelector = "app.kubernetes.io/part-of=multi-juicer,app.kubernetes.io/component=event-log"
+	chunkIndexLabel    = "multi-juicer.owasp-juice.shop/event-log-chunk"
+	chunkDataKey       = "events.json"
+	// ConfigMaps are limited to 1MiB in total, this leaves headroom for the metadata of the ConfigMap
+	maxChunkBytes = 900 * 1024
+	maxRetries    = 5
+)
+
+// EventLog persists events as JSON arrays in a series of ConfigMaps ("chunks") named multi-juicer-events-<index>.
+// New events are always appended to the chunk with the highest index. Once it is full, a new chunk is started.
+// Every replica keeps an in-memory copy of all chunks which is kept up to date by watching the ConfigMaps.
+type EventLog struct {
+	bundle *bundle.Bundle
+	mutex  *sync.RWMutex
+	// events of each chunk, keyed by chunk index
+	chunks map[int][]bundle.Event
+	sorted []bundle.Event
+	// latest is the chunk with the highest index as last seen by the watcher or written by this replica, nil if it isn't known yet
+	latest *corev1.ConfigMap
+}
+
+func NewEventLog(b *bundle.Bundle) *EventLog {
+	return &EventLog{
+		bundle: b,
+		mutex:  &sync.RWMutex{},
+		chunks: map[int][]bundle.Event{},
+		sorted: []bundle.Event{},
+	}
+}
+
+// GetEvents returns all events, sorted by timestamp, oldest first. The returned slice must not be modified.
+func (l *EventLog) GetEvents() []bundle.Event {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.sorted
+}
+
+// GetEventsSince returns the events appended to the chunks after the given number of events per chunk index, sorted by timestamp, and the number of events per chunk now.
+// Chunks are only ever appended to, so passing the returned counts to the next call returns only the events appended in between, including late events of other replicas.
+func (l *EventLog) GetEventsSince(offsets map[int]int) ([]bundle.Event, map[int]int) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	events := []bundle.Event{}
+	counts := make(map[int]int, len(l.chunks))
+	for index, chunk := range l.chunks {
+		events = append(events, chunk[min(offsets[index], len(chunk)):]...)
+		counts[index] = len(chunk)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, counts
+}
+
+// Append writes the event to the latest chunk. Uses optimistic concurrency (read resourceVersion, retry on conflict) so multiple replicas can append concurrently.
+func (l *EventLog) Append(ctx context.Context, event bundle.Event) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	for attempt := range maxRetries {
+		index, configMap, err := l.getLatestChunk(ctx)
+		if err != nil {
+			return err
+		}
+
+		events := []bundle.Event{}
+		if configMap != nil {
+			events, err = decodeChunk(configMap)
+			if err != nil {
+				return err
+			}
+		}
+		encoded, err := json.Marshal(append(events, event))
+		if err != nil {
+			return fmt.Errorf("failed to encode event log chunk: %w", err)
+		}
+		if configMap != nil && len(encoded) > maxChunkBytes {
+			index++
+			configMap = nil
+			encoded, err = json.Marshal([]bundle.Event{event})
+			if err != nil {
+				return fmt.Errorf("failed to encode event log chunk: %w", err)
+			}
+		}
+
+		var written *corev1.ConfigMap
+		if configMap == nil {
+			written, err = l.bundle.ClientSet.CoreV1().ConfigMaps(l.bundle.RuntimeEnvironment.Namespace).Create(ctx, newChunk(index, string(encoded)), metav1.CreateOptions{})
+		} else {
+			configMap = configMap.DeepCopy()
+			if configMap.Data == nil {
+				configMap.Data = map[string]string{}
+			}
+			configMap.Data[chunkDataKey] = string(encoded)
+			written, err = l.bundle.ClientSet.CoreV1().ConfigMaps(l.bundle.RuntimeEnvironment.Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+		}
+
+		if err == nil {
+			l.updateChunkFromConfigMap(written)
+			return nil
+		}
+		if errors.IsNotFound(err) {
+			// the cached chunk was deleted, look up the latest chunk again
+			l.deleteChunk(index)
+			continue
+		}
+		if !errors.IsConflict(err) && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to write event log chunk %d: %w", index, err)
+		}
+		l.bundle.Log.Debug("Event log append conflict, retrying", "attempt", attempt+1, "maxRetries", maxRetries)
+		// another replica wrote to the chunk first, fetch its current version instead of waiting for the watcher to catch up
+		current, err := l.bundle.ClientSet.CoreV1().ConfigMaps(l.bundle.RuntimeEnvironment.Namespace).Get(ctx, chunkName(index), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get event log chunk %d: %w", index, err)
+		}
+		l.updateChunkFromConfigMap(current)
+	}
+	return fmt.Errorf("failed to append to event log after %d retries due to conflicts", maxRetries)
+}
+
+// getLatestChunk returns the chunk with the highest index. Returns a nil ConfigMap and index 0 if there is no chunk yet.
+// Only lists the chunks if neither the watcher nor a previous append has seen one yet.
+func (l *EventLog) getLatestChunk(ctx context.Context) (int, *corev1.ConfigMap, error) {
+	l.mutex.RLock()
+	latest := l.latest
+	l.mutex.RUnlock()
+	if latest != nil {
+		index, _ := chunkIndex(latest)
+		return index, latest, nil
+	}
+
+	configMaps, err := l.bundle.ClientSet.CoreV1().ConfigMaps(l.bundle.RuntimeEnvironment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: chunkLabelSelector,
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to list event log chunks: %w", err)
+	}
+
+	latestIndex := 0
+	for i := range configMaps.Items {
+		index, ok := chunkIndex(&configMaps.Items[i])
+		if !ok {
+			continue
+		}
+		if latest == nil || index > latestIndex {
+			latestIndex = index
+			latest = &configMaps.Items[i]
+		}
+	}
+	return latestIndex, latest, nil
+}
+
+// setChunk caches the events of the chunk. Chunks are only ever appended to, so updates with fewer events than cached are stale, e.g. watch events arriving after the
+// result of an append, and are ignored. Otherwise events already returned by GetEventsSince would be returned again.
+func (l *EventLog) setChunk(index int, configMap *corev1.ConfigMap, events []bundle.Event) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	previous, existed := l.chunks[index]
+	if existed && len(events) < len(previous) {
+		l.bundle.Log.Debug("Ignoring stale event log chunk", "configMap", configMap.Name, "events", len(events), "cachedEvents", len(previous))
+		return
+	}
+
+	isLatest := true
+	for existingIndex := range l.chunks {
+		if existingIndex > index {
+			isLatest = false
+			break
+		}
+	}
+	if isLatest {
+		l.latest = configMap
+	}
+
+	l.chunks[index] = events
+	// appends to the latest chunk are by far the most common update, add them to the sorted events instead of sorting everything again
+	if isLatest && existed && len(events) >= len(previous) && isSortedAfter(events[len(previous):], l.sorted) {
+		l.sorted = append(l.sorted, events[len(previous):]...)
+		return
+	}
+	l.sorted = sortChunks(l.chunks)
+}
+
+func (l *EventLog) deleteChunk(index int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.chunks, index)
+	if l.latest != nil {
+		if latestIndex, _ := chunkIndex(l.latest); latestIndex == index {
+			l.latest = nil
+		}
+	}
+	l.sorted = sortChunks(l.chunks)
+}
+
+// StartEventLogWatcher keeps the in-memory copy of the event log in sync with the chunk ConfigMaps, which might be written to by other replicas.
+func (l *EventLog) StartEventLogWatcher(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			l.bundle.Log.Info("MultiJuicer context canceled. Exiting event log watcher.")
+			return
+		default:
+			l.watchChunks(ctx)
+			// Wait before reconnecting
+			time.Sleep(5 * time.Second)
+		}
+	}
+}
+
+func (l *EventLog) watchChunks(ctx context.Context) {
+	configMaps, err := l.bundle.ClientSet.CoreV1().ConfigMaps(l.bundle.RuntimeEnvironment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: chunkLabelSelector,
+	})
+	if err != nil {
+		l.bundle.Log.Error("Failed to list event log chunks", "error", err)
+		return
+	}
+	for i := range configMaps.Items {
+		l.updateChunkFromConfigMap(&configMaps.Items[i])
+	}
+
+	watcher, err := l.bundle.ClientSet.CoreV1().ConfigMaps(l.bundle.RuntimeEnvironment.Namespace).Watch(ctx, metav1.ListOptions{
+		LabelSelector:   chunkLabelSelector,
+		ResourceVersion: configMaps.ResourceVersion,
+	})
+	if err != nil {
+		l.bundle.Log.Error("Failed to start watch for event log chunks", "error", err)
+		return
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				l.bundle.Log.Warn("Event log watcher closed. Reconnecting...")
+				return
+			}
+			configMap, ok := event.Object.(*corev1.ConfigMap)
+			if !ok {
+				continue
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				l.updateChunkFromConfigMap(configMap)
+			case watch.Deleted:
+				if index, ok := chunkIndex(configMap); ok {
+					l.deleteChunk(index)
+				}
+			}
+		case <-ctx.Done():
+			l.bundle.Log.Info("Context canceled. Exiting event log watcher.")
+			return
+		}
+	}
+}
+
+func (l *EventLog) updateChunkFromConfigMap(configMap *corev1.ConfigMap) {
+	index, ok := chunkIndex(configMap)
+	if !ok {
+		l.bundle.Log.Warn("Event log ConfigMap has an invalid chunk index label", "configMap", configMap.Name)
+		return
+	}
+	events, err := decodeChunk(configMap)
+	if err != nil {
+		l.bundle.Log.Error("Failed to decode event log chunk", "configMap", configMap.Name, "error", err)
+		return
+	}
+	l.setChunk(index, configMap, events)
+}
+
+func newChunk(index int, data string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: chunkName(index),
+			Labels: map[string]string{
+				"app.kubernetes.io/part-of":   "multi-juicer",
+				"app.kubernetes.io/component": "event-log",
+				chunkIndexLabel:               strconv.Itoa(index),
+			},
+		},
+		Data: map[string]string{
+			chunkDataKey: data,
+		},
+	}
+}
+
+func chunkName(index int) string {
+	return fmt.Sprintf("multi-juicer-events-%d", index)
+}
+
+func chunkIndex(configMap *corev1.ConfigMap) (int, bool) {
+	index, err := strconv.Atoi(configMap.Labels[chunkIndexLabel])
+	if err != nil {
+		return 0, false
+	}
+	return index, true
+}
+
+func decodeChunk(configMap *corev1.ConfigMap) ([]bundle.Event, error) {
+	events := []bundle.Event{}
+	data := configMap.Data[chunkDataKey]
+	if data == "" {
+		return events, nil
+	}
+	if err := json.Unmarshal([]byte(data), &events); err != nil {
+		return nil, fmt.Errorf("failed to decode event log chunk %s: %w", configMap.Name, err)
+	}
+	return events, nil
+}
+
+func sortChunks(chunks map[int][]bundle.Event) []bundle.Event {
+	sorted := []bundle.Event{}
+	for _, events := range chunks {
+		sorted = append(sorted, events...)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	return sorted
+}
+
+// isSortedAfter checks if the events are sorted by timestamp and none of them is older than the last of the already sorted events
+func isSortedAfter(events []bundle.Event, sorted []bundle.Event) bool {
+	for i, event := range events {
+		if i == 0 && len(sorted) > 0 && event.Timestamp.Before(sorted[len(sorted)-1].Timestamp) {
+			return false
+		}
+		if i > 0 && event.Timestamp.Before(events[i-1].Timestamp) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,226 @@
+package eventlog
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestBundle can't use testutil, as testutil depends on this package
+func newTestBundle(clientset *fake.Clientset) *b.Bundle {
+	return &b.Bundle{
+		ClientSet: clientset,
+		RuntimeEnvironment: b.RuntimeEnvironment{
+			Namespace: "test-namespace",
+		},
+		Log: slog.New(slog.NewTextHandler(os.Stdout, nil)),
+	}
+}
+
+func TestAppend(t *testing.T) {
+	t.Run("creates the first chunk and appends to it", func(t *testing.T) {
+		clientset := fake.NewClientset()
+		eventLog := NewEventLog(newTestBundle(clientset))
+
+		solvedAt := time.Date(2024, 11, 1, 19, 55, 48, 0, time.UTC)
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamCreated, Team: "foobar", Timestamp: solvedAt.Add(-time.Minute)}))
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeChallengeSolved, Team: "foobar", Timestamp: solvedAt, ChallengeKey: "scoreBoardChallenge"}))
+
+		configMap, err := clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), "multi-juicer-events-0", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "0", configMap.Labels["multi-juicer.owasp-juice.shop/event-log-chunk"])
+
+		var persisted []b.Event
+		require.NoError(t, json.Unmarshal([]byte(configMap.Data["events.json"]), &persisted))
+		assert.Equal(t, []b.Event{
+			{Type: b.EventTypeTeamCreated, Team: "foobar", Timestamp: solvedAt.Add(-time.Minute)},
+			{Type: b.EventTypeChallengeSolved, Team: "foobar", Timestamp: solvedAt, ChallengeKey: "scoreBoardChallenge"},
+		}, persisted)
+		assert.Equal(t, persisted, eventLog.GetEvents())
+	})
+
+	t.Run("sets the timestamp if it is missing", func(t *testing.T) {
+		eventLog := NewEventLog(newTestBundle(fake.NewClientset()))
+
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeClockSet, Actor: "admin"}))
+
+		events := eventLog.GetEvents()
+		require.Len(t, events, 1)
+		assert.WithinDuration(t, time.Now(), events[0].Timestamp, 5*time.Second)
+	})
+
+	t.Run("starts a new chunk once the latest one is full", func(t *testing.T) {
+		fullChunk := make([]b.Event, maxChunkBytes/1000)
+		for i := range fullChunk {
+			fullChunk[i] = b.Event{Type: b.EventTypeScoreAdjusted, Team: "foobar", Timestamp: time.Date(2024, 11, 1, 0, 0, i, 0, time.UTC), Details: strings.Repeat("a", 1000)}
+		}
+		encoded, err := json.Marshal(fullChunk)
+		require.NoError(t, err)
+
+		chunk := newChunk(3, string(encoded))
+		chunk.Namespace = "test-namespace"
+		clientset := fake.NewClientset(chunk)
+		eventLog := NewEventLog(newTestBundle(clientset))
+
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamDeleted, Team: "foobar", Actor: "admin"}))
+
+		configMap, err := clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), "multi-juicer-events-4", metav1.GetOptions{})
+		require.NoError(t, err)
+		var persisted []b.Event
+		require.NoError(t, json.Unmarshal([]byte(configMap.Data["events.json"]), &persisted))
+		require.Len(t, persisted, 1)
+		assert.Equal(t, b.EventTypeTeamDeleted, persisted[0].Type)
+
+		previous, err := clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), "multi-juicer-events-3", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, string(encoded), previous.Data["events.json"], "full chunk should not be modified")
+	})
+
+	t.Run("only lists the chunks once and appends to the known latest chunk afterwards", func(t *testing.T) {
+		clientset := fake.NewClientset()
+		eventLog := NewEventLog(newTestBundle(clientset))
+
+		for range 3 {
+			require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypePasscodeReset, Team: "foobar"}))
+		}
+
+		lists := 0
+		for _, action := range clientset.Actions() {
+			if action.GetVerb() == "list" {
+				lists++
+			}
+		}
+		assert.Equal(t, 1, lists)
+		assert.Len(t, eventLog.GetEvents(), 3)
+	})
+
+	t.Run("picks up chunks written by other replicas when the append conflicts", func(t *testing.T) {
+		clientset := fake.NewClientset()
+		// the fake clientset doesn't check resource versions, reject the stale update like the api server would
+		updates := 0
+		clientset.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updates++
+			if updates == 2 {
+				return true, nil, errors.NewConflict(corev1.Resource("configmaps"), "multi-juicer-events-0", nil)
+			}
+			return false, nil, nil
+		})
+		eventLog := NewEventLog(newTestBundle(clientset))
+		otherReplica := NewEventLog(newTestBundle(clientset))
+
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamCreated, Team: "foobar"}))
+		require.NoError(t, otherReplica.Append(context.Background(), b.Event{Type: b.EventTypeTeamCreated, Team: "barfoo"}))
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamDeleted, Team: "foobar"}))
+
+		// the conflicting chunk is re-read with a get, which the Role of the helm chart has to grant for the event log chunks
+		gets := []string{}
+		for _, action := range clientset.Actions() {
+			if getAction, ok := action.(k8stesting.GetAction); ok && action.GetVerb() == "get" && action.GetResource().Resource == "configmaps" {
+				gets = append(gets, getAction.GetName())
+			}
+		}
+		assert.Equal(t, []string{"multi-juicer-events-0"}, gets)
+
+		configMap, err := clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), "multi-juicer-events-0", metav1.GetOptions{})
+		require.NoError(t, err)
+		var persisted []b.Event
+		require.NoError(t, json.Unmarshal([]byte(configMap.Data["events.json"]), &persisted))
+		require.Len(t, persisted, 3)
+		assert.Equal(t, "barfoo", persisted[1].Team)
+		assert.Equal(t, b.EventTypeTeamDeleted, persisted[2].Type)
+	})
+}
+
+func TestStartEventLogWatcher(t *testing.T) {
+	t.Run("loads existing chunks and picks up events appended by other replicas", func(t *testing.T) {
+		first := time.Date(2024, 11, 1, 19, 0, 0, 0, time.UTC)
+		encoded, err := json.Marshal([]b.Event{{Type: b.EventTypeTeamCreated, Team: "foobar", Timestamp: first}})
+		require.NoError(t, err)
+		chunk := newChunk(0, string(encoded))
+		chunk.Namespace = "test-namespace"
+		clientset := fake.NewClientset(chunk)
+
+		eventLog := NewEventLog(newTestBundle(clientset))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go eventLog.StartEventLogWatcher(ctx)
+
+		assert.Eventually(t, func() bool {
+			return len(eventLog.GetEvents()) == 1
+		}, 2*time.Second, 10*time.Millisecond)
+
+		// another replica appends an event
+		otherReplica := NewEventLog(newTestBundle(clientset))
+		require.NoError(t, otherReplica.Append(context.Background(), b.Event{Type: b.EventTypeChallengeSolved, Team: "foobar", Timestamp: first.Add(time.Minute), ChallengeKey: "scoreBoardChallenge"}))
+
+		assert.Eventually(t, func() bool {
+			events := eventLog.GetEvents()
+			return len(events) == 2 && events[1].ChallengeKey == "scoreBoardChallenge"
+		}, 2*time.Second, 10*time.Millisecond)
+
+		// chunks removed by hand are dropped from the local copy
+		require.NoError(t, clientset.CoreV1().ConfigMaps("test-namespace").Delete(context.Background(), "multi-juicer-events-0", metav1.DeleteOptions{}))
+		assert.Eventually(t, func() bool {
+			return len(eventLog.GetEvents()) == 0
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestGetEventsSince(t *testing.T) {
+	t.Run("only returns the events appended since the last call", func(t *testing.T) {
+		eventLog := NewEventLog(newTestBundle(fake.NewClientset()))
+		first := time.Date(2024, 11, 1, 19, 0, 0, 0, time.UTC)
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamCreated, Team: "foobar", Timestamp: first}))
+
+		events, offsets := eventLog.GetEventsSince(nil)
+		assert.Equal(t, []b.Event{{Type: b.EventTypeTeamCreated, Team: "foobar", Timestamp: first}}, events)
+		assert.Equal(t, map[int]int{0: 1}, offsets)
+
+		// events of other replicas can show up late with an older timestamp
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamCreated, Team: "barfoo", Timestamp: first.Add(-time.Minute)}))
+
+		events, offsets = eventLog.GetEventsSince(offsets)
+		assert.Equal(t, []b.Event{{Type: b.EventTypeTeamCreated, Team: "barfoo", Timestamp: first.Add(-time.Minute)}}, events)
+		assert.Equal(t, map[int]int{0: 2}, offsets)
+
+		events, _ = eventLog.GetEventsSince(offsets)
+		assert.Empty(t, events)
+	})
+
+	t.Run("ignores stale chunks delivered after newer ones", func(t *testing.T) {
+		eventLog := NewEventLog(newTestBundle(fake.NewClientset()))
+		first := time.Date(2024, 11, 1, 19, 0, 0, 0, time.UTC)
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamCreated, Team: "foobar", Timestamp: first}))
+		require.NoError(t, eventLog.Append(context.Background(), b.Event{Type: b.EventTypeChallengeSolved, Team: "foobar", ChallengeKey: "scoreBoardChallenge", Timestamp: first.Add(time.Minute)}))
+		_, offsets := eventLog.GetEventsSince(nil)
+
+		// the watch event of the first append arrives after the second append
+		encoded, err := json.Marshal([]b.Event{{Type: b.EventTypeTeamCreated, Team: "foobar", Timestamp: first}})
+		require.NoError(t, err)
+		eventLog.updateChunkFromConfigMap(newChunk(0, string(encoded)))
+		assert.Len(t, eventLog.GetEvents(), 2)
+		events, offsets := eventLog.GetEventsSince(offsets)
+		assert.Empty(t, events)
+
+		// followed by the watch event of the second append
+		encoded, err = json.Marshal([]b.Event{{Type: b.EventTypeTeamCreated, Team: "foobar", Timestamp: first}, {Type: b.EventTypeChallengeSolved, Team: "foobar", ChallengeKey: "scoreBoardChallenge", Timestamp: first.Add(time.Minute)}})
+		require.NoError(t, err)
+		eventLog.updateChunkFromConfigMap(newChunk(0, string(encoded)))
+		events, _ = eventLog.GetEventsSince(offsets)
+		assert.Empty(t, events, "events already returned must not be returned again")
+	})
+}
@@ -0,0 +1,428 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// resyncPeriod in which all instances get reconciled again, e.g. to recreate deleted services
+	resyncPeriod = 10 * time.Minute
+	// annotations with this prefix hold the state of the team (passcode, solved challenges, ...)
+	teamStateAnnotationPrefix = "multi-juicer.owasp-juice.shop/"
+)
+
+// volatileAnnotations change on every proxied request. They are not backed up in the status of the instance to avoid constant writes.
+var volatileAnnotations = map[string]bool{
+	"multi-juicer.owasp-juice.shop/lastRequest":         true,
+	"multi-juicer.owasp-juice.shop/lastRequestReadable": true,
+}
+
+type controller struct {
+	bundle    *bundle.Bundle
+	instances cache.GenericNamespaceLister
+	queue     workqueue.TypedRateLimitingInterface[string]
+}
+
+// StartController reconciles the deployments, services and secrets of all teams with their JuiceShopInstance until ctx is canceled.
+// Deployments created before the JuiceShopInstance resource existed are adopted by creating an instance for them.
+// Must only run on the leader.
+func StartController(ctx context.Context, b *bundle.Bundle) {
+	informer := dynamicinformer.NewFilteredDynamicInformer(b.DynamicClient, bundle.JuiceShopInstanceResource, b.RuntimeEnvironment.Namespace, resyncPeriod, cache.Indexers{}, nil)
+	c := &controller{
+		bundle:    b,
+		instances: informer.Lister().ByNamespace(b.RuntimeEnvironment.Namespace),
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "juice-shop-instances"},
+		),
+	}
+	defer c.queue.ShutDown()
+
+	_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueInstance,
+		UpdateFunc: func(_, obj any) {
+			c.enqueueInstance(obj)
+		},
+		DeleteFunc: c.enqueueInstance,
+	})
+	if err != nil {
+		b.Log.Error("Failed to register the event handler for JuiceShopInstances", "error", err)
+		return
+	}
+	registration, err := b.JuiceShopInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueDeployment,
+		UpdateFunc: func(oldObj, newObj any) {
+			if deploymentChanged(oldObj.(*appsv1.Deployment), newObj.(*appsv1.Deployment)) {
+				c.enqueueDeployment(newObj)
+			}
+		},
+		DeleteFunc: c.enqueueDeployment,
+	})
+	if err != nil {
+		b.Log.Error("Failed to register the instance controller event handler for JuiceShop deployments", "error", err)
+		return
+	}
+	defer b.JuiceShopInformer.RemoveEventHandler(registration)
+
+	go informer.Informer().Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced, registration.HasSynced) {
+		b.Log.Warn("Instance controller stopped before the caches synced")
+		return
+	}
+
+	b.Log.Info("Starting the JuiceShopInstance controller")
+	go func() {
+		<-ctx.Done()
+		c.queue.ShutDown()
+	}()
+	for c.processNextItem(ctx) {
+	}
+	b.Log.Info("MultiJuicer context canceled. Exiting the JuiceShopInstance controller.")
+}
+
+func (c *controller) enqueueInstance(obj any) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	_, team, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return
+	}
+	c.queue.Add(team)
+}
+
+func (c *controller) enqueueDeployment(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if deployment, ok := obj.(*appsv1.Deployment); ok && deployment.Labels["team"] != "" {
+		c.queue.Add(deployment.Labels["team"])
+	}
+}
+
+// deploymentChanged ignores updates of the last request timestamps, which happen constantly while a team is active
+func deploymentChanged(oldDeployment, newDeployment *appsv1.Deployment) bool {
+	return oldDeployment.Generation != newDeployment.Generation ||
+		oldDeployment.Status.ReadyReplicas != newDeployment.Status.ReadyReplicas ||
+		!maps.Equal(teamState(oldDeployment.Annotations), teamState(newDeployment.Annotations))
+}
+
+func (c *controller) processNextItem(ctx context.Context) bool {
+	team, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(team)
+
+	if err := c.reconcile(ctx, team); err != nil {
+		c.bundle.Log.Error("Failed to reconcile JuiceShopInstance, retrying", "team", team, "error", err)
+		c.queue.AddRateLimited(team)
+		return true
+	}
+	c.queue.Forget(team)
+	return true
+}
+
+func (c *controller) reconcile(ctx context.Context, team string) error {
+	object, err := c.instances.Get(team)
+	if errors.IsNotFound(err) {
+		return adoptDeployment(ctx, c.bundle, team)
+	}
+	if err != nil {
+		return err
+	}
+	instance, err := fromUnstructured(object.(*unstructured.Unstructured))
+	if err != nil {
+		return err
+	}
+	return reconcileInstance(ctx, c.bundle, instance)
+}
+
+// adoptDeployment creates an instance for a deployment created before the JuiceShopInstance resource existed, the next reconcile then takes ownership of the deployment.
+// Deployments already controlled by an instance are left alone, their instance is being deleted.
+func adoptDeployment(ctx context.Context, b *bundle.Bundle, team string) error {
+	deployment, err := b.GetJuiceShopDeployment(team)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if owner := metav1.GetControllerOf(deployment); owner != nil && owner.Kind == Kind {
+		return nil
+	}
+
+	// only settings which differ from the global config become overrides, the rest keeps following the global config
+	instance := NewInstance(team)
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name != juiceShopContainerName {
+			continue
+		}
+		if image, tag := splitImage(container.Image); image != b.Config.JuiceShopConfig.Image || tag != b.Config.JuiceShopConfig.Tag {
+			instance.Spec.Image, instance.Spec.Tag = image, tag
+		}
+		if !equality.Semantic.DeepEqual(container.Resources, b.Config.JuiceShopConfig.Resources) {
+			resources := container.Resources
+			instance.Spec.Resources = &resources
+		}
+	}
+	instance.Spec.Paused = deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0
+
+	_, err = Create(ctx, b, instance)
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create JuiceShopInstance for existing deployment: %w", err)
+	}
+	b.Log.Info("Adopted existing JuiceShop deployment", "team", team)
+	return nil
+}
+
+// reconcileInstance converges the deployment, service, network policy and secret of the team to the instance and updates its status, including the backup of the team state.
+func reconcileInstance(ctx context.Context, b *bundle.Bundle, instance *JuiceShopInstance) error {
+	team := instance.Name
+	// services and secrets aren't cached, only check them when the spec changed or the deployment got recreated or updated
+	ensureOwnedResources := instance.Generation != instance.Status.ObservedGeneration
+
+	deployment, err := b.GetJuiceShopDeployment(team)
+	switch {
+	case errors.IsNotFound(err) && len(instance.Status.TeamState) == 0:
+		// the deployment of a new team is created by the join handler, there is no team state to restore yet
+		return nil
+	case errors.IsNotFound(err):
+		deployment, err = CreateDeployment(ctx, b, instance, restoreTeamState(instance))
+		if errors.IsAlreadyExists(err) {
+			// the cache hasn't caught up yet, e.g. because the join handler just created the deployment
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create deployment: %w", err)
+		}
+		b.Log.Info("Created deployment for JuiceShopInstance", "team", team)
+		ensureOwnedResources = true
+	case err != nil:
+		return err
+	default:
+		if updated, changed := convergeDeployment(b, instance, deployment); changed {
+			deployment, err = b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to update deployment: %w", err)
+			}
+			b.Log.Info("Updated deployment to match JuiceShopInstance", "team", team)
+			// the new pods might reference secrets the deployment didn't use before, e.g. after an upgrade of MultiJuicer
+			ensureOwnedResources = true
+		}
+	}
+
+	if ensureOwnedResources {
+		if err := ensureService(ctx, b, team, deployment); err != nil {
+			return err
+		}
+		if err := ensureWebhookSecret(ctx, b, team, deployment); err != nil {
+			return err
+		}
+		if b.Config.JuiceShopConfig.NetworkPolicy.Enabled {
+			if err := ensureNetworkPolicy(ctx, b, team, deployment); err != nil {
+				return err
+			}
+		}
+		// deployments from the warm pool keep the LLM token secret they were started with
+		if b.Config.JuiceShopConfig.LLM.Enabled && !isFromWarmPool(deployment) {
+			if err := ensureLLMTokenSecret(ctx, b, team, deployment); err != nil {
+				return err
+			}
+		}
+	}
+
+	status := JuiceShopInstanceStatus{
+		Ready:              deployment.Status.ReadyReplicas >= 1,
+		ReadyReplicas:      deployment.Status.ReadyReplicas,
+		Image:              containerImage(deployment),
+		ObservedGeneration: instance.Generation,
+		TeamState:          teamState(deployment.Annotations),
+	}
+	if !equality.Semantic.DeepEqual(status, instance.Status) {
+		instance.Status = status
+		if _, err := updateStatus(ctx, b, instance); err != nil {
+			return fmt.Errorf("failed to update JuiceShopInstance status: %w", err)
+		}
+	}
+	return nil
+}
+
+// convergeDeployment applies the spec of the instance to a copy of the deployment. Returns false if the deployment already matches.
+func convergeDeployment(b *bundle.Bundle, instance *JuiceShopInstance, deployment *appsv1.Deployment) (*appsv1.Deployment, bool) {
+	desired := BuildDeployment(b, instance, nil)
+	updated := deployment.DeepCopy()
+	changed := false
+
+	if !metav1.IsControlledBy(updated, instance) {
+		updated.OwnerReferences = desired.OwnerReferences
+		changed = true
+	}
+	currentReplicas := int32(1)
+	if updated.Spec.Replicas != nil {
+		currentReplicas = *updated.Spec.Replicas
+	}
+	if currentReplicas != *desired.Spec.Replicas {
+		updated.Spec.Replicas = desired.Spec.Replicas
+		changed = true
+	}
+	tag := instance.tag(b)
+	if updated.Labels["app.kubernetes.io/version"] != tag {
+		updated.Labels["app.kubernetes.io/version"] = tag
+		updated.Spec.Template.Labels["app.kubernetes.io/version"] = tag
+		changed = true
+	}
+	for i := range updated.Spec.Template.Spec.Containers {
+		container := &updated.Spec.Template.Spec.Containers[i]
+		if container.Name != juiceShopContainerName {
+			continue
+		}
+		if container.Image != instance.image(b) {
+			container.Image = instance.image(b)
+			changed = true
+		}
+		if !equality.Semantic.DeepEqual(container.Resources, instance.resources(b)) {
+			container.Resources = instance.resources(b)
+			changed = true
+		}
+		if env := desiredEnv(b, instance, deployment); !equality.Semantic.DeepEqual(container.Env, env) {
+			container.Env = env
+			changed = true
+		}
+	}
+	return updated, changed
+}
+
+// ensureService creates the service of the team if it's missing. An existing service of a previous deployment of the team is taken over,
+// so that it isn't garbage collected together with the old deployment and selects the pods of the current one.
+func ensureService(ctx context.Context, b *bundle.Bundle, team string, deployment *appsv1.Deployment) error {
+	service, err := b.ClientSet.CoreV1().Services(b.RuntimeEnvironment.Namespace).Get(ctx, DeploymentName(team), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if err := CreateService(ctx, b, team, deployment); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create service: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if metav1.IsControlledBy(service, deployment) && maps.Equal(service.Spec.Selector, deployment.Spec.Selector.MatchLabels) {
+		return nil
+	}
+	service.OwnerReferences = getDeploymentOwnerReferences(deployment)
+	service.Spec.Selector = maps.Clone(deployment.Spec.Selector.MatchLabels)
+	if _, err := b.ClientSet.CoreV1().Services(b.RuntimeEnvironment.Namespace).Update(ctx, service, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update service: %w", err)
+	}
+	return nil
+}
+
+// ensureNetworkPolicy creates the NetworkPolicy of the team if it's missing. Like the service, the policy of a previous deployment of the team is taken over
+// and updated to select the pods of the current one. Changes of the configured rules are applied as well.
+func ensureNetworkPolicy(ctx context.Context, b *bundle.Bundle, team string, deployment *appsv1.Deployment) error {
+	networkPolicy, err := b.ClientSet.NetworkingV1().NetworkPolicies(b.RuntimeEnvironment.Namespace).Get(ctx, DeploymentName(team), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if err := CreateNetworkPolicy(ctx, b, team, deployment); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create network policy: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	spec := buildNetworkPolicySpec(b, deployment)
+	if metav1.IsControlledBy(networkPolicy, deployment) && equality.Semantic.DeepEqual(networkPolicy.Spec, spec) {
+		return nil
+	}
+	networkPolicy.OwnerReferences = getDeploymentOwnerReferences(deployment)
+	networkPolicy.Spec = spec
+	if _, err := b.ClientSet.NetworkingV1().NetworkPolicies(b.RuntimeEnvironment.Namespace).Update(ctx, networkPolicy, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update network policy: %w", err)
+	}
+	return nil
+}
+
+// ensureWebhookSecret creates the solutions webhook secret of the deployment if it's missing. Unlike the LLM token secret it's named after the deployment,
+// deployments from the warm pool keep the secret they were started with.
+func ensureWebhookSecret(ctx context.Context, b *bundle.Bundle, team string, deployment *appsv1.Deployment) error {
+	_, err := b.ClientSet.CoreV1().Secrets(b.RuntimeEnvironment.Namespace).Get(ctx, webhookSecretName(deployment.Name), metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	if isFromWarmPool(deployment) {
+		err = createPoolWebhookSecret(ctx, b, deployment)
+	} else {
+		err = CreateWebhookSecret(ctx, b, team, deployment)
+	}
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// ensureLLMTokenSecret creates the LLM token secret of the team if it's missing and takes over the secret of a previous deployment of the team
+func ensureLLMTokenSecret(ctx context.Context, b *bundle.Bundle, team string, deployment *appsv1.Deployment) error {
+	secret, err := b.ClientSet.CoreV1().Secrets(b.RuntimeEnvironment.Namespace).Get(ctx, DeploymentName(team), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if err := CreateLLMTokenSecret(ctx, b, team, deployment); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil || metav1.IsControlledBy(secret, deployment) {
+		return err
+	}
+	secret.OwnerReferences = getDeploymentOwnerReferences(deployment)
+	if _, err := b.ClientSet.CoreV1().Secrets(b.RuntimeEnvironment.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update LLM token secret: %w", err)
+	}
+	return nil
+}
+
+// teamState returns the annotations holding the state of the team, without the volatile ones. Returns nil if there are none.
+func teamState(annotations map[string]string) map[string]string {
+	var state map[string]string
+	for key, value := range annotations {
+		if strings.HasPrefix(key, teamStateAnnotationPrefix) && !volatileAnnotations[key] {
+			if state == nil {
+				state = map[string]string{}
+			}
+			state[key] = value
+		}
+	}
+	return state
+}
+
+// restoreTeamState returns the annotations for a recreated deployment, using the state backed up in the status of the instance
+func restoreTeamState(instance *JuiceShopInstance) map[string]string {
+	annotations := NewTeamAnnotations("")
+	maps.Copy(annotations, teamState(instance.Status.TeamState))
+	return annotations
+}
+
+func containerImage(deployment *appsv1.Deployment) string {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == juiceShopContainerName {
+			return container.Image
+		}
+	}
+	return ""
+}
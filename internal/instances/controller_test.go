@@ -0,0 +1,290 @@
+package instances
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInstanceController(t *testing.T) {
+	multiJuicerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "multi-juicer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+
+	getDeployment := func(t *testing.T, clientset *fake.Clientset, team string) *appsv1.Deployment {
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), DeploymentName(team), metav1.GetOptions{})
+		require.NoError(t, err)
+		return deployment
+	}
+
+	t.Run("converges the deployment to the spec of the instance", func(t *testing.T) {
+		clientset := fake.NewClientset(multiJuicerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		ctx := context.Background()
+
+		_, err := Provision(ctx, bundle, "foobar", "passcode-hash")
+		require.NoError(t, err)
+		testutil.WaitForJuiceShopDeployment(bundle, "foobar", func(deployment *appsv1.Deployment) bool { return true })
+
+		instance, err := Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		instance.Spec.Tag = "v19.0.0"
+		instance.Spec.Paused = true
+		instance, err = Update(ctx, bundle, instance)
+		require.NoError(t, err)
+
+		require.NoError(t, reconcileInstance(ctx, bundle, instance))
+
+		deployment := getDeployment(t, clientset, "foobar")
+		assert.Equal(t, int32(0), *deployment.Spec.Replicas)
+		assert.Equal(t, "bkimminich/juice-shop:v19.0.0", deployment.Spec.Template.Spec.Containers[0].Image)
+		assert.Equal(t, "v19.0.0", deployment.Labels["app.kubernetes.io/version"])
+		assert.Equal(t, "passcode-hash", deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"], "team state must be kept when converging the deployment")
+
+		secret, err := clientset.CoreV1().Secrets("test-namespace").Get(ctx, "juiceshop-foobar-webhook", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "http://multijuicer-private.test-namespace.svc.cluster.local/team/foobar/webhook?token="+url.QueryEscape(testutil.SignTestTeamname(b.WebhookIdentityPrefix+"foobar")), string(secret.Data["url"]))
+		assert.NotContains(t, string(secret.Data["url"]), url.QueryEscape(testutil.SignTestTeamname("foobar")), "the webhook token must not be usable as team cookie")
+		assert.True(t, metav1.IsControlledBy(secret, deployment))
+
+		instance, err = Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		assert.False(t, instance.Status.Ready)
+		assert.Equal(t, "bkimminich/juice-shop:v19.0.0", instance.Status.Image)
+		assert.Equal(t, "passcode-hash", instance.Status.TeamState["multi-juicer.owasp-juice.shop/passcode"], "team state should be backed up in the status")
+		assert.NotContains(t, instance.Status.TeamState, "multi-juicer.owasp-juice.shop/lastRequest")
+		assert.Empty(t, instance.Annotations, "team state must not be mirrored into the metadata of the instance")
+	})
+
+	t.Run("leaves image, tag and resources of new instances to the global config", func(t *testing.T) {
+		clientset := fake.NewClientset(multiJuicerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		ctx := context.Background()
+
+		_, err := Provision(ctx, bundle, "foobar", "passcode-hash")
+		require.NoError(t, err)
+		testutil.WaitForJuiceShopDeployment(bundle, "foobar", func(deployment *appsv1.Deployment) bool { return true })
+
+		instance, err := Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		assert.Equal(t, JuiceShopInstanceSpec{}, instance.Spec)
+
+		// upgrading the chart changes the global tag, existing teams follow it
+		bundle.Config.JuiceShopConfig.Tag = "v19.0.0"
+		require.NoError(t, reconcileInstance(ctx, bundle, instance))
+		assert.Equal(t, "bkimminich/juice-shop:v19.0.0", getDeployment(t, clientset, "foobar").Spec.Template.Spec.Containers[0].Image)
+	})
+
+	t.Run("doesn't create a deployment for instances without a backed up team state", func(t *testing.T) {
+		clientset := fake.NewClientset(multiJuicerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		ctx := context.Background()
+
+		instance, err := Create(ctx, bundle, NewInstance("foobar"))
+		require.NoError(t, err)
+		require.NoError(t, reconcileInstance(ctx, bundle, instance))
+
+		_, err = clientset.AppsV1().Deployments("test-namespace").Get(ctx, DeploymentName("foobar"), metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err), "the join handler creates the deployment with the passcode of the team")
+	})
+
+	t.Run("recreates a deleted deployment with the team state backed up on the instance", func(t *testing.T) {
+		clientset := fake.NewClientset(multiJuicerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		ctx := context.Background()
+
+		deployment, err := Provision(ctx, bundle, "foobar", "passcode-hash")
+		require.NoError(t, err)
+		deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"] = `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`
+		_, err = clientset.AppsV1().Deployments("test-namespace").Update(ctx, deployment, metav1.UpdateOptions{})
+		require.NoError(t, err)
+		testutil.WaitForJuiceShopDeployment(bundle, "foobar", func(deployment *appsv1.Deployment) bool {
+			return deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"] != "[]"
+		})
+
+		instance, err := Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		require.NoError(t, reconcileInstance(ctx, bundle, instance))
+
+		require.NoError(t, clientset.AppsV1().Deployments("test-namespace").Delete(ctx, DeploymentName("foobar"), metav1.DeleteOptions{}))
+		require.Eventually(t, func() bool {
+			_, err := bundle.JuiceShopLister.Deployments("test-namespace").Get(DeploymentName("foobar"))
+			return errors.IsNotFound(err)
+		}, time.Second, 5*time.Millisecond)
+
+		instance, err = Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		require.NoError(t, reconcileInstance(ctx, bundle, instance))
+
+		recreated := getDeployment(t, clientset, "foobar")
+		assert.Equal(t, "passcode-hash", recreated.Annotations["multi-juicer.owasp-juice.shop/passcode"])
+		assert.Equal(t, `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, recreated.Annotations["multi-juicer.owasp-juice.shop/challenges"])
+		assert.True(t, metav1.IsControlledBy(recreated, instance))
+	})
+
+	t.Run("adopts deployments created before the instance resource existed", func(t *testing.T) {
+		truePointer := true
+		legacyDeployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "juiceshop-foobar",
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"app.kubernetes.io/version": "v18.0.0",
+					"team":                      "foobar",
+				},
+				Annotations: map[string]string{
+					"multi-juicer.owasp-juice.shop/passcode":    "passcode-hash",
+					"multi-juicer.owasp-juice.shop/lastRequest": "1729259667397",
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "Deployment", Name: "multi-juicer", UID: multiJuicerDeployment.UID, Controller: &truePointer},
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "foobar"}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app.kubernetes.io/version": "v18.0.0"}},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{Name: "juice-shop", Image: "registry.example.com:5000/juice-shop:v18.0.0", Resources: testutil.NewTestBundle().Config.JuiceShopConfig.Resources},
+						},
+					},
+				},
+			},
+		}
+		clientset := fake.NewClientset(multiJuicerDeployment, legacyDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		ctx := context.Background()
+
+		require.NoError(t, adoptDeployment(ctx, bundle, "foobar"))
+
+		instance, err := Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		assert.Equal(t, "registry.example.com:5000/juice-shop", instance.Spec.Image)
+		assert.Equal(t, "v18.0.0", instance.Spec.Tag)
+		assert.Nil(t, instance.Spec.Resources, "resources matching the global config aren't overrides")
+
+		require.NoError(t, reconcileInstance(ctx, bundle, instance))
+		instance, err = Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"multi-juicer.owasp-juice.shop/passcode": "passcode-hash"}, instance.Status.TeamState)
+
+		deployment := getDeployment(t, clientset, "foobar")
+		assert.True(t, metav1.IsControlledBy(deployment, instance), "the instance should take over the deployment")
+		assert.Equal(t, "registry.example.com:5000/juice-shop:v18.0.0", deployment.Spec.Template.Spec.Containers[0].Image, "adopting shouldn't change the image")
+		_, err = clientset.CoreV1().Secrets("test-namespace").Get(ctx, "juiceshop-foobar-webhook", metav1.GetOptions{})
+		assert.NoError(t, err, "the webhook secret referenced by the updated deployment should be created")
+	})
+
+	t.Run("rolls out per-team overrides", func(t *testing.T) {
+		clientset := fake.NewClientset(multiJuicerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		ctx := context.Background()
+
+		_, err := Provision(ctx, bundle, "foobar", "passcode-hash")
+		require.NoError(t, err)
+		testutil.WaitForJuiceShopDeployment(bundle, "foobar", func(deployment *appsv1.Deployment) bool { return true })
+
+		require.NoError(t, SetOverrides(ctx, bundle, "foobar", Overrides{
+			Tag: "v19.0.0",
+			Env: []corev1.EnvVar{{Name: "NODE_ENV", Value: "ctf"}, {Name: "DEBUG", Value: "true"}},
+		}))
+		instance, err := Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		require.NoError(t, reconcileInstance(ctx, bundle, instance))
+
+		deployment := getDeployment(t, clientset, "foobar")
+		container := deployment.Spec.Template.Spec.Containers[0]
+		assert.Equal(t, "bkimminich/juice-shop:v19.0.0", container.Image, "an empty image falls back to the global one")
+		assert.Equal(t, []corev1.EnvVar{
+			{Name: "NODE_ENV", Value: "ctf"},
+			{Name: "CTF_KEY", Value: ""},
+			{Name: "SOLUTIONS_WEBHOOK", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "juiceshop-foobar-webhook"}, Key: "url"}}},
+			{Name: "DEBUG", Value: "true"},
+		}, container.Env)
+
+		require.NoError(t, SetOverrides(ctx, bundle, "foobar", Overrides{}))
+		testutil.WaitForJuiceShopDeployment(bundle, "foobar", func(deployment *appsv1.Deployment) bool {
+			return deployment.Labels["app.kubernetes.io/version"] == "v19.0.0"
+		})
+		instance, err = Get(ctx, bundle, "foobar")
+		require.NoError(t, err)
+		require.NoError(t, reconcileInstance(ctx, bundle, instance))
+
+		container = getDeployment(t, clientset, "foobar").Spec.Template.Spec.Containers[0]
+		assert.Equal(t, "bkimminich/juice-shop:latest", container.Image)
+		assert.NotContains(t, container.Env, corev1.EnvVar{Name: "DEBUG", Value: "true"}, "removed overrides must be rolled back")
+	})
+
+	t.Run("isolates the instance of the team with a network policy", func(t *testing.T) {
+		clientset := fake.NewClientset(multiJuicerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.JuiceShopConfig.NetworkPolicy = b.NetworkPolicyConfig{
+			Enabled: true,
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				{To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}}},
+			},
+		}
+		ctx := context.Background()
+
+		deployment, err := Provision(ctx, bundle, "foobar", "passcode-hash")
+		require.NoError(t, err)
+
+		networkPolicy, err := clientset.NetworkingV1().NetworkPolicies("test-namespace").Get(ctx, "juiceshop-foobar", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.True(t, metav1.IsControlledBy(networkPolicy, deployment), "the network policy should be deleted together with the deployment")
+		assert.Equal(t, deployment.Spec.Selector.MatchLabels, networkPolicy.Spec.PodSelector.MatchLabels)
+		assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}, networkPolicy.Spec.PolicyTypes)
+		require.Len(t, networkPolicy.Spec.Ingress, 1)
+		assert.Equal(t, "multi-juicer", networkPolicy.Spec.Ingress[0].From[0].PodSelector.MatchLabels["app.kubernetes.io/name"])
+		assert.Equal(t, int32(3000), networkPolicy.Spec.Ingress[0].Ports[0].Port.IntVal)
+		require.Len(t, networkPolicy.Spec.Egress, 3)
+		assert.Equal(t, "multi-juicer", networkPolicy.Spec.Egress[0].To[0].PodSelector.MatchLabels["app.kubernetes.io/name"])
+		assert.Equal(t, int32(8082), networkPolicy.Spec.Egress[0].Ports[0].Port.IntVal)
+		assert.Equal(t, []networkingv1.NetworkPolicyPeer{kubeDNSPods}, networkPolicy.Spec.Egress[1].To, "DNS is only allowed to the cluster DNS")
+		assert.Equal(t, int32(53), networkPolicy.Spec.Egress[1].Ports[0].Port.IntVal)
+		assert.Equal(t, "10.0.0.0/8", networkPolicy.Spec.Egress[2].To[0].IPBlock.CIDR, "configured egress rules are added to the policy")
+
+		networkPolicy.Spec.Egress = nil
+		_, err = clientset.NetworkingV1().NetworkPolicies("test-namespace").Update(ctx, networkPolicy, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		require.NoError(t, ensureNetworkPolicy(ctx, bundle, "foobar", deployment))
+
+		networkPolicy, err = clientset.NetworkingV1().NetworkPolicies("test-namespace").Get(ctx, "juiceshop-foobar", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Len(t, networkPolicy.Spec.Egress, 3, "changes to the policy are reverted")
+	})
+
+	t.Run("allows DNS to the configured peers", func(t *testing.T) {
+		bundle := testutil.NewTestBundle()
+		nodeLocalDNS := networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "169.254.20.10/32"}}
+		bundle.Config.JuiceShopConfig.NetworkPolicy = b.NetworkPolicyConfig{
+			Enabled: true,
+			DNS:     []networkingv1.NetworkPolicyPeer{nodeLocalDNS},
+		}
+
+		spec := buildNetworkPolicySpec(bundle, BuildDeployment(bundle, NewInstance("foobar"), nil))
+
+		require.Len(t, spec.Egress, 2)
+		assert.Equal(t, []networkingv1.NetworkPolicyPeer{nodeLocalDNS}, spec.Egress[1].To)
+	})
+}
@@ -0,0 +1,322 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	Kind       = "JuiceShopInstance"
+	APIVersion = "multi-juicer.owasp-juice.shop/v1alpha1"
+)
+
+// JuiceShopInstance describes the JuiceShop instance of a team. The instance controller owns the deployment, service and secret of the team and converges them to the spec.
+// The name of the instance is the name of the team.
+type JuiceShopInstance struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   JuiceShopInstanceSpec   `json:"spec"`
+	Status JuiceShopInstanceStatus `json:"status,omitempty"`
+}
+
+type JuiceShopInstanceSpec struct {
+	// Image of the JuiceShop container without the tag. Falls back to the globally configured image when empty.
+	Image string `json:"image,omitempty"`
+	// Tag of the JuiceShop image. Falls back to the globally configured tag when empty.
+	Tag string `json:"tag,omitempty"`
+	// Resources of the JuiceShop container. Falls back to the globally configured resources when nil.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Env is added to the globally configured environment of the JuiceShop container. Variables with the same name replace the global ones.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// Paused scales the deployment down to zero replicas. The team and its progress are kept.
+	Paused bool `json:"paused,omitempty"`
+	// Hibernated scales the deployment down to zero replicas after the team was inactive for a while. Unlike Paused it's lifted by the next request of the team.
+	Hibernated bool `json:"hibernated,omitempty"`
+}
+
+type JuiceShopInstanceStatus struct {
+	// Ready is true once the JuiceShop of the team is ready to receive requests
+	Ready         bool  `json:"ready"`
+	ReadyReplicas int32 `json:"readyReplicas"`
+	// Image currently used by the deployment of the team
+	Image string `json:"image,omitempty"`
+	// ObservedGeneration is the generation of the spec the deployment was last converged to
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// TeamState is a backup of the annotations holding the state of the team (passcode, solved challenges, ...). The deployment owns the state,
+	// the controller copies it here and only reads it back to recreate a deleted deployment.
+	TeamState map[string]string `json:"teamState,omitempty"`
+}
+
+func (i *JuiceShopInstance) image(b *bundle.Bundle) string {
+	image := i.Spec.Image
+	if image == "" {
+		image = b.Config.JuiceShopConfig.Image
+	}
+	return fmt.Sprintf("%s:%s", image, i.tag(b))
+}
+
+func (i *JuiceShopInstance) tag(b *bundle.Bundle) string {
+	if i.Spec.Tag == "" {
+		return b.Config.JuiceShopConfig.Tag
+	}
+	return i.Spec.Tag
+}
+
+func (i *JuiceShopInstance) resources(b *bundle.Bundle) corev1.ResourceRequirements {
+	if i.Spec.Resources == nil {
+		return b.Config.JuiceShopConfig.Resources
+	}
+	return *i.Spec.Resources
+}
+
+// env returns the environment of the JuiceShop container with the env of the instance applied on top of the given base environment
+func (i *JuiceShopInstance) env(base []corev1.EnvVar) []corev1.EnvVar {
+	env := slices.Clone(base)
+	for _, override := range i.Spec.Env {
+		index := slices.IndexFunc(env, func(envVar corev1.EnvVar) bool { return envVar.Name == override.Name })
+		if index == -1 {
+			env = append(env, override)
+		} else {
+			env[index] = override
+		}
+	}
+	return env
+}
+
+// splitImage splits a container image reference into image and tag. The tag is empty if the reference doesn't have one.
+func splitImage(reference string) (string, string) {
+	separator := strings.LastIndex(reference, ":")
+	if separator == -1 || strings.Contains(reference[separator:], "/") {
+		// the colon separates a registry port, not a tag
+		return reference, ""
+	}
+	return reference[:separator], reference[separator+1:]
+}
+
+func instanceOwnerReference(instance *JuiceShopInstance) metav1.OwnerReference {
+	truePointer := true
+	return metav1.OwnerReference{
+		APIVersion:         APIVersion,
+		Kind:               Kind,
+		Name:               instance.Name,
+		UID:                instance.UID,
+		Controller:         &truePointer,
+		BlockOwnerDeletion: &truePointer,
+	}
+}
+
+func fromUnstructured(object *unstructured.Unstructured) (*JuiceShopInstance, error) {
+	instance := &JuiceShopInstance{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, instance); err != nil {
+		return nil, fmt.Errorf("failed to decode JuiceShopInstance %s: %w", object.GetName(), err)
+	}
+	return instance, nil
+}
+
+func toUnstructured(instance *JuiceShopInstance) (*unstructured.Unstructured, error) {
+	instance.APIVersion = APIVersion
+	instance.Kind = Kind
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JuiceShopInstance %s: %w", instance.Name, err)
+	}
+	return &unstructured.Unstructured{Object: object}, nil
+}
+
+// NewInstance returns the instance for a new team. Image, tag and resources are left empty, so the team follows the global config, e.g. after an upgrade of the chart.
+func NewInstance(team string) *JuiceShopInstance {
+	return &JuiceShopInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: team,
+			Labels: map[string]string{
+				"team":                      team,
+				"app.kubernetes.io/name":    "juice-shop",
+				"app.kubernetes.io/part-of": "multi-juicer",
+			},
+		},
+	}
+}
+
+// Create creates the instance resource. It's owned by the multi-juicer deployment so that it gets deleted together with MultiJuicer.
+// The deployment, service and secret of the team have to be created separately, e.g. by the join handler or the instance controller.
+func Create(ctx context.Context, b *bundle.Bundle, instance *JuiceShopInstance) (*JuiceShopInstance, error) {
+	ownerReferences, err := getMultiJuicerOwnerReferences(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	instance.OwnerReferences = ownerReferences
+
+	object, err := toUnstructured(instance)
+	if err != nil {
+		return nil, err
+	}
+	created, err := b.DynamicClient.Resource(bundle.JuiceShopInstanceResource).Namespace(b.RuntimeEnvironment.Namespace).Create(ctx, object, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return fromUnstructured(created)
+}
+
+// Provision creates the instance of a new team together with its deployment, service, network policy, webhook and LLM token secrets, without waiting for the instance controller.
+// The controller doesn't create deployments for instances without a backed up team state, so the deployment created here is the only one.
+func Provision(ctx context.Context, b *bundle.Bundle, team string, passcodeHash string) (*appsv1.Deployment, error) {
+	annotations := NewTeamAnnotations(passcodeHash)
+	instance, err := Create(ctx, b, NewInstance(team))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JuiceShopInstance: %w", err)
+	}
+
+	deployment, err := provisionResources(ctx, b, instance, team, annotations)
+	if err != nil {
+		// the controller doesn't create deployments for instances without a backed up team state, the instance would block the team name until deleted by hand
+		if deleteErr := Delete(ctx, b, team); deleteErr != nil && !errors.IsNotFound(deleteErr) {
+			b.Log.Error("Failed to clean up instance after failed provisioning", "team", team, "error", deleteErr)
+		}
+		return nil, err
+	}
+	return deployment, nil
+}
+
+func provisionResources(ctx context.Context, b *bundle.Bundle, instance *JuiceShopInstance, team string, annotations map[string]string) (*appsv1.Deployment, error) {
+	deployment, err := CreateDeployment(ctx, b, instance, annotations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	if err := CreateWebhookSecret(ctx, b, team, deployment); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, err
+	}
+
+	if b.Config.JuiceShopConfig.LLM.Enabled {
+		if err := CreateLLMTokenSecret(ctx, b, team, deployment); err != nil && !errors.IsAlreadyExists(err) {
+			return nil, err
+		}
+	}
+
+	if err := CreateService(ctx, b, team, deployment); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to create service: %w", err)
+	}
+
+	if b.Config.JuiceShopConfig.NetworkPolicy.Enabled {
+		if err := CreateNetworkPolicy(ctx, b, team, deployment); err != nil && !errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to create network policy: %w", err)
+		}
+	}
+	return deployment, nil
+}
+
+// Get fetches the instance of the team from the Kubernetes API
+func Get(ctx context.Context, b *bundle.Bundle, team string) (*JuiceShopInstance, error) {
+	object, err := b.DynamicClient.Resource(bundle.JuiceShopInstanceResource).Namespace(b.RuntimeEnvironment.Namespace).Get(ctx, team, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return fromUnstructured(object)
+}
+
+// Update writes the metadata and spec of the instance
+func Update(ctx context.Context, b *bundle.Bundle, instance *JuiceShopInstance) (*JuiceShopInstance, error) {
+	object, err := toUnstructured(instance)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := b.DynamicClient.Resource(bundle.JuiceShopInstanceResource).Namespace(b.RuntimeEnvironment.Namespace).Update(ctx, object, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return fromUnstructured(updated)
+}
+
+func updateStatus(ctx context.Context, b *bundle.Bundle, instance *JuiceShopInstance) (*JuiceShopInstance, error) {
+	object, err := toUnstructured(instance)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := b.DynamicClient.Resource(bundle.JuiceShopInstanceResource).Namespace(b.RuntimeEnvironment.Namespace).UpdateStatus(ctx, object, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return fromUnstructured(updated)
+}
+
+// SetHibernated hibernates or wakes up the instance of the team, the instance controller then scales the deployment accordingly.
+// Returns false if the instance already was in the requested state.
+func SetHibernated(ctx context.Context, b *bundle.Bundle, team string, hibernated bool) (bool, error) {
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		instance, err := Get(ctx, b, team)
+		if err != nil {
+			return err
+		}
+		if instance.Spec.Hibernated == hibernated {
+			changed = false
+			return nil
+		}
+		instance.Spec.Hibernated = hibernated
+		_, err = Update(ctx, b, instance)
+		changed = err == nil
+		return err
+	})
+	return changed, err
+}
+
+// Overrides are the per-team settings admins can change on the instance of a team. Empty values fall back to the global config.
+type Overrides struct {
+	Image     string                       `json:"image,omitempty"`
+	Tag       string                       `json:"tag,omitempty"`
+	Env       []corev1.EnvVar              `json:"env,omitempty"`
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// GetOverrides returns the image, tag, env and resources set on the instance of the team
+func GetOverrides(ctx context.Context, b *bundle.Bundle, team string) (Overrides, error) {
+	instance, err := Get(ctx, b, team)
+	if err != nil {
+		return Overrides{}, err
+	}
+	return Overrides{
+		Image:     instance.Spec.Image,
+		Tag:       instance.Spec.Tag,
+		Env:       instance.Spec.Env,
+		Resources: instance.Spec.Resources,
+	}, nil
+}
+
+// SetOverrides replaces the image, tag, env and resources of the instance of the team. The instance controller then re-renders the deployment, which restarts the JuiceShop of the team.
+func SetOverrides(ctx context.Context, b *bundle.Bundle, team string, overrides Overrides) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		instance, err := Get(ctx, b, team)
+		if err != nil {
+			return err
+		}
+		instance.Spec.Image = overrides.Image
+		instance.Spec.Tag = overrides.Tag
+		instance.Spec.Env = overrides.Env
+		instance.Spec.Resources = overrides.Resources
+		_, err = Update(ctx, b, instance)
+		return err
+	})
+}
+
+// Delete deletes the instance and the deployment of the team. Deleting the instance first keeps the controller from recreating the deployment.
+// The service and secret are owned by the deployment via OwnerReferences and will be garbage collected by Kubernetes.
+// Returns a NotFound error if the deployment didn't exist.
+func Delete(ctx context.Context, b *bundle.Bundle, team string) error {
+	err := b.DynamicClient.Resource(bundle.JuiceShopInstanceResource).Namespace(b.RuntimeEnvironment.Namespace).Delete(ctx, team, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete JuiceShopInstance: %w", err)
+	}
+	return b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Delete(ctx, b.JuiceShopDeploymentName(team), metav1.DeleteOptions{})
+}
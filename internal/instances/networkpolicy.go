@@ -0,0 +1,100 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// multiJuicerPrivatePort is the port of the MultiJuicer pods serving the solutions webhook and the LLM gateway
+const multiJuicerPrivatePort = 8082
+
+// multiJuicerPods selects the pods of MultiJuicer itself
+var multiJuicerPods = &metav1.LabelSelector{
+	MatchLabels: map[string]string{
+		"app.kubernetes.io/name":    "multi-juicer",
+		"app.kubernetes.io/part-of": "multi-juicer",
+	},
+}
+
+// kubeDNSPods selects the cluster DNS of most distributions, used when no DNS peers are configured
+var kubeDNSPods = networkingv1.NetworkPolicyPeer{
+	NamespaceSelector: &metav1.LabelSelector{
+		MatchLabels: map[string]string{"kubernetes.io/metadata.name": "kube-system"},
+	},
+	PodSelector: &metav1.LabelSelector{
+		MatchLabels: map[string]string{"k8s-app": "kube-dns"},
+	},
+}
+
+// buildNetworkPolicySpec only lets the MultiJuicer pods reach the JuiceShop of the team, and the JuiceShop only reach the private MultiJuicer port and DNS.
+// Rules configured in bundle.NetworkPolicyConfig are added on top.
+func buildNetworkPolicySpec(b *bundle.Bundle, deployment *appsv1.Deployment) networkingv1.NetworkPolicySpec {
+	tcp := corev1.ProtocolTCP
+	udp := corev1.ProtocolUDP
+	applicationPort := intstr.FromInt32(b.Application.Port())
+	privatePort := intstr.FromInt32(multiJuicerPrivatePort)
+	dnsPort := intstr.FromInt32(53)
+	dnsPeers := b.Config.JuiceShopConfig.NetworkPolicy.DNS
+	if len(dnsPeers) == 0 {
+		dnsPeers = []networkingv1.NetworkPolicyPeer{*kubeDNSPods.DeepCopy()}
+	}
+
+	ingress := []networkingv1.NetworkPolicyIngressRule{
+		{
+			From:  []networkingv1.NetworkPolicyPeer{{PodSelector: multiJuicerPods.DeepCopy()}},
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &applicationPort}},
+		},
+	}
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{
+			To:    []networkingv1.NetworkPolicyPeer{{PodSelector: multiJuicerPods.DeepCopy()}},
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &privatePort}},
+		},
+		{
+			// the solutions webhook is called using the cluster dns name of the private service. Only the cluster DNS is allowed, DNS to arbitrary resolvers could be used to tunnel data out.
+			// Clusters with a resolver that isn't a kube-dns pod, e.g. node local dns, have to configure it as DNS peer.
+			To: dnsPeers,
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dnsPort},
+				{Protocol: &tcp, Port: &dnsPort},
+			},
+		},
+	}
+
+	return networkingv1.NetworkPolicySpec{
+		// deployments claimed from the warm pool select their pods by the pool instance label instead of the team
+		PodSelector: metav1.LabelSelector{MatchLabels: maps.Clone(deployment.Spec.Selector.MatchLabels)},
+		PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		Ingress:     append(ingress, b.Config.JuiceShopConfig.NetworkPolicy.Ingress...),
+		Egress:      append(egress, b.Config.JuiceShopConfig.NetworkPolicy.Egress...),
+	}
+}
+
+// CreateNetworkPolicy creates the NetworkPolicy isolating the JuiceShop of the team. It's owned by the deployment of the team.
+func CreateNetworkPolicy(ctx context.Context, b *bundle.Bundle, team string, ownerDeployment *appsv1.Deployment) error {
+	networkPolicy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DeploymentName(team),
+			Labels: map[string]string{
+				"team":                        team,
+				"app.kubernetes.io/name":      "juice-shop",
+				"app.kubernetes.io/component": "vulnerable-app",
+				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
+				"app.kubernetes.io/part-of":   "multi-juicer",
+			},
+			OwnerReferences: getDeploymentOwnerReferences(ownerDeployment),
+		},
+		Spec: buildNetworkPolicySpec(b, ownerDeployment),
+	}
+
+	_, err := b.ClientSet.NetworkingV1().NetworkPolicies(b.RuntimeEnvironment.Namespace).Create(ctx, networkPolicy, metav1.CreateOptions{})
+	return err
+}
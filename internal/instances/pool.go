@@ -0,0 +1,235 @@
+package instances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	// poolInstanceLabel identifies the pods of a warm pool deployment. The team isn't known when the pods start,
+	// so the deployment and the service created on claim select the pods by this label instead of the team label.
+	poolInstanceLabel    = "multi-juicer.owasp-juice.shop/instance"
+	poolDeploymentPrefix = "juiceshop-pool-"
+	warmPoolInterval     = 10 * time.Second
+	warmPoolSelector     = "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer," + bundle.WarmPoolLabel
+)
+
+// ErrWarmPoolEmpty is returned by Claim if no ready instance is left in the warm pool
+var ErrWarmPoolEmpty = errors.New("no ready instance in the warm pool")
+
+// StartWarmPool keeps the configured number of unclaimed instances in the warm pool until ctx is canceled.
+// Must only run on the leader.
+func StartWarmPool(ctx context.Context, b *bundle.Bundle) {
+	size := b.Config.JuiceShopConfig.WarmPool.Size
+	if size == 0 {
+		return
+	}
+	b.Log.Info("Starting the JuiceShop warm pool", "size", size)
+
+	ticker := time.NewTicker(warmPoolInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := RefillWarmPool(ctx, b); err != nil && ctx.Err() == nil {
+			b.Log.Error("Failed to refill the warm pool", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			b.Log.Info("MultiJuicer context canceled. Exiting the warm pool.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefillWarmPool creates deployments until the warm pool has the configured size again.
+// Unclaimed deployments running an outdated image, e.g. after the JuiceShop tag was changed, get replaced.
+func RefillWarmPool(ctx context.Context, b *bundle.Bundle) error {
+	deployments, err := listWarmPool(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	current := 0
+	for _, deployment := range deployments {
+		if isOutdated(b, &deployment) {
+			b.Log.Info("Replacing outdated warm pool deployment", "deployment", deployment.Name)
+			err := b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Delete(ctx, deployment.Name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete outdated warm pool deployment: %w", err)
+			}
+			continue
+		}
+		current++
+	}
+
+	for range b.Config.JuiceShopConfig.WarmPool.Size - current {
+		if err := createPoolDeployment(ctx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Claim hands a ready deployment of the warm pool to a new team. The deployment keeps its name and pods, it's relabeled to the team and gets the team annotations.
+// The JuiceShopInstance of the team, the service and the network policy are created afterwards. Returns ErrWarmPoolEmpty if no ready deployment is available, the caller should provision a new instance then.
+func Claim(ctx context.Context, b *bundle.Bundle, team string, passcodeHash string) (*appsv1.Deployment, error) {
+	if b.Config.JuiceShopConfig.WarmPool.Size == 0 {
+		return nil, ErrWarmPoolEmpty
+	}
+	deployments, err := listWarmPool(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := NewTeamAnnotations(passcodeHash)
+	for _, deployment := range deployments {
+		if deployment.Status.ReadyReplicas < 1 || isOutdated(b, &deployment) {
+			continue
+		}
+
+		claimed := deployment.DeepCopy()
+		delete(claimed.Labels, bundle.WarmPoolLabel)
+		claimed.Labels["team"] = team
+		claimed.Labels["app.kubernetes.io/instance"] = fmt.Sprintf("juice-shop-%s", team)
+		if claimed.Annotations == nil {
+			claimed.Annotations = map[string]string{}
+		}
+		maps.Copy(claimed.Annotations, annotations)
+
+		// the update fails with a conflict if another replica claimed the deployment in the meantime
+		claimed, err = b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Update(ctx, claimed, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim warm pool deployment: %w", err)
+		}
+		b.Log.Info("Claimed deployment from the warm pool", "team", team, "deployment", claimed.Name)
+
+		if _, err := Create(ctx, b, NewInstance(team)); err != nil && !apierrors.IsAlreadyExists(err) {
+			// the instance controller adopts the deployment and creates the instance later on
+			b.Log.Warn("Failed to create JuiceShopInstance for claimed deployment", "team", team, "error", err)
+		}
+
+		if err := CreateService(ctx, b, team, claimed); err != nil && !apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to create service: %w", err)
+		}
+		if b.Config.JuiceShopConfig.NetworkPolicy.Enabled {
+			if err := CreateNetworkPolicy(ctx, b, team, claimed); err != nil && !apierrors.IsAlreadyExists(err) {
+				return nil, fmt.Errorf("failed to create network policy: %w", err)
+			}
+		}
+		return claimed, nil
+	}
+	return nil, ErrWarmPoolEmpty
+}
+
+func listWarmPool(ctx context.Context, b *bundle.Bundle) ([]appsv1.Deployment, error) {
+	deployments, err := b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: warmPoolSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list warm pool deployments: %w", err)
+	}
+	return deployments.Items, nil
+}
+
+// isOutdated returns true if the deployment doesn't run the globally configured image or was started with the webhook url in its env instead of a secret
+func isOutdated(b *bundle.Bundle, deployment *appsv1.Deployment) bool {
+	return containerImage(deployment) != (&JuiceShopInstance{}).image(b) || !readsWebhookFromSecret(deployment)
+}
+
+func readsWebhookFromSecret(deployment *appsv1.Deployment) bool {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, envVar := range container.Env {
+			if envVar.Name == "SOLUTIONS_WEBHOOK" {
+				return envVar.ValueFrom != nil && envVar.ValueFrom.SecretKeyRef != nil
+			}
+		}
+	}
+	return false
+}
+
+// createPoolWebhookSecret creates the solutions webhook secret of a warm pool deployment. The webhook url identifies the deployment, it gets resolved to the team that claimed it on every request.
+func createPoolWebhookSecret(ctx context.Context, b *bundle.Bundle, deployment *appsv1.Deployment) error {
+	return createWebhookSecret(ctx, b, deployment.Name, fmt.Sprintf("instance/%s", deployment.Name), bundle.InstanceIdentityPrefix+deployment.Name, map[string]string{poolInstanceLabel: deployment.Name}, deployment)
+}
+
+// isFromWarmPool returns true if the deployment was started in the warm pool, which means its name doesn't match the team and its LLM token secret belongs to the deployment instead of the team
+func isFromWarmPool(deployment *appsv1.Deployment) bool {
+	return deployment.Spec.Selector != nil && deployment.Spec.Selector.MatchLabels[poolInstanceLabel] != ""
+}
+
+func createPoolDeployment(ctx context.Context, b *bundle.Bundle) error {
+	ownerReferences, err := getMultiJuicerOwnerReferences(ctx, b)
+	if err != nil {
+		return err
+	}
+	deployment := buildPoolDeployment(b, poolDeploymentPrefix+rand.String(8), ownerReferences)
+	deployment, err = b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create warm pool deployment: %w", err)
+	}
+
+	if err := createPoolWebhookSecret(ctx, b, deployment); err != nil {
+		return err
+	}
+	if b.Config.JuiceShopConfig.LLM.Enabled {
+		err := createLLMTokenSecret(ctx, b, deployment.Name, bundle.InstanceIdentityPrefix+deployment.Name, map[string]string{poolInstanceLabel: deployment.Name}, deployment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildPoolDeployment renders an unclaimed deployment using the globally configured image and resources.
+// The webhook url and LLM token identify the deployment, they get resolved to the team that claimed it on every request.
+func buildPoolDeployment(b *bundle.Bundle, name string, ownerReferences []metav1.OwnerReference) *appsv1.Deployment {
+	instance := &JuiceShopInstance{}
+	replicas := desiredReplicas(instance)
+	tag := instance.tag(b)
+
+	podLabels := map[string]string{
+		poolInstanceLabel:           name,
+		"app.kubernetes.io/version": tag,
+	}
+	env := buildJuiceShopEnv(b, name)
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				bundle.WarmPoolLabel:          "true",
+				"app.kubernetes.io/version":   tag,
+				"app.kubernetes.io/component": "vulnerable-app",
+				"app.kubernetes.io/name":      "juice-shop",
+				"app.kubernetes.io/instance":  name,
+				"app.kubernetes.io/part-of":   "multi-juicer",
+			},
+			OwnerReferences: ownerReferences,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					poolInstanceLabel:           name,
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+				},
+			},
+			Template: buildPodTemplate(b, instance, podLabels, env),
+		},
+	}
+}
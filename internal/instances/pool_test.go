@@ -0,0 +1,125 @@
+package instances
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWarmPool(t *testing.T) {
+	multiJuicerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "multi-juicer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+
+	newPoolDeployment := func(b *bundle.Bundle, name string, readyReplicas int32) *appsv1.Deployment {
+		deployment := buildPoolDeployment(b, name, nil)
+		deployment.Namespace = "test-namespace"
+		deployment.Status.ReadyReplicas = readyReplicas
+		return deployment
+	}
+
+	listPool := func(t *testing.T, clientset *fake.Clientset) []appsv1.Deployment {
+		deployments, err := clientset.AppsV1().Deployments("test-namespace").List(context.Background(), metav1.ListOptions{LabelSelector: warmPoolSelector})
+		require.NoError(t, err)
+		return deployments.Items
+	}
+
+	t.Run("refills the pool up to the configured size and replaces outdated deployments", func(t *testing.T) {
+		clientset := fake.NewClientset(multiJuicerDeployment)
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.Config.JuiceShopConfig.WarmPool.Size = 3
+
+		outdated := newPoolDeployment(b, "juiceshop-pool-outdated", 1)
+		outdated.Spec.Template.Spec.Containers[0].Image = "bkimminich/juice-shop:v17.0.0"
+		_, err := clientset.AppsV1().Deployments("test-namespace").Create(context.Background(), outdated, metav1.CreateOptions{})
+		require.NoError(t, err)
+
+		require.NoError(t, RefillWarmPool(context.Background(), b))
+
+		pool := listPool(t, clientset)
+		assert.Len(t, pool, 3)
+		for _, deployment := range pool {
+			assert.NotEqual(t, "juiceshop-pool-outdated", deployment.Name)
+			assert.Equal(t, "bkimminich/juice-shop:latest", deployment.Spec.Template.Spec.Containers[0].Image)
+			assert.True(t, readsWebhookFromSecret(&deployment))
+			secret, err := clientset.CoreV1().Secrets("test-namespace").Get(context.Background(), deployment.Name+"-webhook", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "http://multijuicer-private.test-namespace.svc.cluster.local/instance/"+deployment.Name+"/webhook?token="+url.QueryEscape(testutil.SignTestTeamname(bundle.WebhookIdentityPrefix+bundle.InstanceIdentityPrefix+deployment.Name)), string(secret.Data["url"]))
+			assert.True(t, metav1.IsControlledBy(&deployment, multiJuicerDeployment))
+		}
+
+		require.NoError(t, RefillWarmPool(context.Background(), b))
+		assert.Len(t, listPool(t, clientset), 3, "a full pool shouldn't grow")
+	})
+
+	t.Run("claims a ready deployment for a new team", func(t *testing.T) {
+		b := testutil.NewTestBundle()
+		clientset := fake.NewClientset(
+			multiJuicerDeployment,
+			newPoolDeployment(b, "juiceshop-pool-starting", 0),
+			newPoolDeployment(b, "juiceshop-pool-ready", 1),
+		)
+		b = testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.Config.JuiceShopConfig.WarmPool.Size = 2
+		ctx := context.Background()
+
+		claimed, err := Claim(ctx, b, "foobar", "passcode-hash")
+		require.NoError(t, err)
+
+		assert.Equal(t, "juiceshop-pool-ready", claimed.Name)
+		assert.Equal(t, "foobar", claimed.Labels["team"])
+		assert.NotContains(t, claimed.Labels, bundle.WarmPoolLabel)
+		assert.Equal(t, "passcode-hash", claimed.Annotations["multi-juicer.owasp-juice.shop/passcode"])
+		assert.Equal(t, "[]", claimed.Annotations["multi-juicer.owasp-juice.shop/challenges"])
+		assert.Len(t, listPool(t, clientset), 1)
+
+		service, err := clientset.CoreV1().Services("test-namespace").Get(ctx, "juiceshop-foobar", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "juiceshop-pool-ready", service.Spec.Selector["multi-juicer.owasp-juice.shop/instance"])
+		assert.True(t, metav1.IsControlledBy(service, claimed))
+
+		instance, err := Get(ctx, b, "foobar")
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			deployment, err := b.GetJuiceShopDeployment("foobar")
+			return err == nil && deployment.Name == "juiceshop-pool-ready"
+		}, time.Second, 5*time.Millisecond)
+
+		require.NoError(t, reconcileInstance(ctx, b, instance))
+
+		_, err = clientset.AppsV1().Deployments("test-namespace").Get(ctx, "juiceshop-foobar", metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err), "the controller must not create a second deployment for a claimed team")
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(ctx, "juiceshop-pool-ready", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.True(t, metav1.IsControlledBy(deployment, instance))
+		instance, err = Get(ctx, b, "foobar")
+		require.NoError(t, err)
+		assert.Equal(t, "passcode-hash", instance.Status.TeamState["multi-juicer.owasp-juice.shop/passcode"])
+	})
+
+	t.Run("returns ErrWarmPoolEmpty if no deployment is ready", func(t *testing.T) {
+		b := testutil.NewTestBundle()
+		clientset := fake.NewClientset(multiJuicerDeployment, newPoolDeployment(b, "juiceshop-pool-starting", 0))
+		b = testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.Config.JuiceShopConfig.WarmPool.Size = 1
+
+		_, err := Claim(context.Background(), b, "foobar", "passcode-hash")
+		assert.ErrorIs(t, err, ErrWarmPoolEmpty)
+		assert.Len(t, listPool(t, clientset), 1)
+	})
+}
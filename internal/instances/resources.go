@@ -0,0 +1,330 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/signutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const juiceShopContainerName = "juice-shop"
+
+// DeploymentName returns the name of the deployment, service and secret of the team
+func DeploymentName(team string) string {
+	return fmt.Sprintf("juiceshop-%s", team)
+}
+
+// NewTeamAnnotations returns the annotations of the deployment of a newly created team
+func NewTeamAnnotations(passcodeHash string) map[string]string {
+	return map[string]string{
+		"multi-juicer.owasp-juice.shop/lastRequest":         fmt.Sprintf("%d", time.Now().UnixMilli()),
+		"multi-juicer.owasp-juice.shop/lastRequestReadable": time.Now().String(),
+		"multi-juicer.owasp-juice.shop/passcode":            passcodeHash,
+		"multi-juicer.owasp-juice.shop/challengesSolved":    "0",
+		"multi-juicer.owasp-juice.shop/challenges":          "[]",
+	}
+}
+
+func getDeploymentOwnerReferences(deployment *appsv1.Deployment) []metav1.OwnerReference {
+	truePointer := true
+	return []metav1.OwnerReference{
+		{
+			APIVersion:         "apps/v1",
+			Kind:               "Deployment",
+			Name:               deployment.Name,
+			UID:                deployment.UID,
+			Controller:         &truePointer,
+			BlockOwnerDeletion: &truePointer,
+		},
+	}
+}
+
+// uid of the multi-juicer kubernetes deployment resource. used to "attach" created JuiceShopInstances to it so that they get deleted when multi-juicer gets deleted
+var deploymentUid types.UID
+
+func getMultiJuicerOwnerReferences(context context.Context, b *bundle.Bundle) ([]metav1.OwnerReference, error) {
+	if deploymentUid == "" {
+		multiJuicerDeployment, err := b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Get(
+			context,
+			"multi-juicer",
+			metav1.GetOptions{},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get multi-juicer deployment to attach correct owner reference to start juice shop: %w", err)
+		}
+		deploymentUid = multiJuicerDeployment.ObjectMeta.UID
+	}
+
+	truePointer := true
+	ownerReferences := []metav1.OwnerReference{
+		{
+			APIVersion:         "apps/v1",
+			Kind:               "Deployment",
+			Name:               "multi-juicer",
+			UID:                deploymentUid,
+			Controller:         &truePointer,
+			BlockOwnerDeletion: &truePointer,
+		},
+	}
+	return ownerReferences, nil
+}
+
+// desiredReplicas returns the number of replicas the deployment of the instance should run
+func desiredReplicas(instance *JuiceShopInstance) int32 {
+	if instance.Spec.Paused || instance.Spec.Hibernated {
+		return 0
+	}
+	return 1
+}
+
+// BuildDeployment renders the deployment of the instance. The annotations hold the state of the team, see NewTeamAnnotations.
+func BuildDeployment(b *bundle.Bundle, instance *JuiceShopInstance, annotations map[string]string) *appsv1.Deployment {
+	team := instance.Name
+	tag := instance.tag(b)
+	replicas := desiredReplicas(instance)
+
+	podLabels := map[string]string{
+		"team":                      team,
+		"app.kubernetes.io/version": tag,
+	}
+	env := desiredEnv(b, instance, nil)
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DeploymentName(team),
+			Labels: map[string]string{
+				"team":                        team,
+				"app.kubernetes.io/version":   tag,
+				"app.kubernetes.io/component": "vulnerable-app",
+				"app.kubernetes.io/name":      "juice-shop",
+				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
+				"app.kubernetes.io/part-of":   "multi-juicer",
+			},
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{instanceOwnerReference(instance)},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"team":                      team,
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+				},
+			},
+			Template: buildPodTemplate(b, instance, podLabels, env),
+		},
+	}
+}
+
+// buildPodTemplate renders the pod of the instance, the application specific parts of the container come from the bundle.Application. The labels are added to the configured pod labels.
+func buildPodTemplate(b *bundle.Bundle, instance *JuiceShopInstance, labels map[string]string, env []corev1.EnvVar) corev1.PodTemplateSpec {
+	podLabels := maps.Clone(b.Config.JuiceShopConfig.JuiceShopPodConfig.Labels)
+	if podLabels == nil {
+		podLabels = map[string]string{}
+	}
+	maps.Copy(podLabels, labels)
+	podLabels["app.kubernetes.io/name"] = "juice-shop"
+	podLabels["app.kubernetes.io/part-of"] = "multi-juicer"
+
+	podAnnotations := map[string]string{}
+	if b.Config.JuiceShopConfig.JuiceShopPodConfig.Annotations != nil {
+		podAnnotations = b.Config.JuiceShopConfig.JuiceShopPodConfig.Annotations
+	}
+
+	container := b.Application.Container()
+	container.Name = juiceShopContainerName
+	container.Image = instance.image(b)
+	container.SecurityContext = &b.Config.JuiceShopConfig.ContainerSecurityContext
+	container.Resources = instance.resources(b)
+	container.Env = env
+	container.EnvFrom = b.Config.JuiceShopConfig.EnvFrom
+	container.VolumeMounts = append(slices.Clone(b.Config.JuiceShopConfig.VolumeMounts), container.VolumeMounts...)
+
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      podLabels,
+			Annotations: podAnnotations,
+		},
+		Spec: corev1.PodSpec{
+			Containers:       []corev1.Container{container},
+			Volumes:          append(slices.Clone(b.Config.JuiceShopConfig.Volumes), b.Application.Volumes()...),
+			ImagePullSecrets: b.Config.JuiceShopConfig.ImagePullSecrets,
+			Tolerations:      b.Config.JuiceShopConfig.Tolerations,
+			Affinity:         &b.Config.JuiceShopConfig.Affinity,
+			RuntimeClassName: b.Config.JuiceShopConfig.RuntimeClassName,
+			SecurityContext:  &b.Config.JuiceShopConfig.PodSecurityContext,
+		},
+	}
+}
+
+// CreateDeployment creates the deployment of the instance
+func CreateDeployment(context context.Context, b *bundle.Bundle, instance *JuiceShopInstance, annotations map[string]string) (*appsv1.Deployment, error) {
+	deployment := BuildDeployment(b, instance, annotations)
+	return b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Create(context, deployment, metav1.CreateOptions{})
+}
+
+// CreateService creates the service routing to the JuiceShop of the team. It's owned by the deployment of the team.
+func CreateService(context context.Context, b *bundle.Bundle, team string, ownerDeployment *appsv1.Deployment) error {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DeploymentName(team),
+			Labels: map[string]string{
+				"team":                        team,
+				"app.kubernetes.io/version":   ownerDeployment.Labels["app.kubernetes.io/version"],
+				"app.kubernetes.io/name":      "juice-shop",
+				"app.kubernetes.io/component": "vulnerable-app",
+				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
+				"app.kubernetes.io/part-of":   "multi-juicer",
+			},
+			OwnerReferences: getDeploymentOwnerReferences(ownerDeployment),
+		},
+		Spec: corev1.ServiceSpec{
+			// deployments claimed from the warm pool select their pods by the pool instance label instead of the team
+			Selector: maps.Clone(ownerDeployment.Spec.Selector.MatchLabels),
+			Ports: []corev1.ServicePort{
+				{
+					Port: b.Application.Port(),
+				},
+			},
+		},
+	}
+
+	_, err := b.ClientSet.CoreV1().Services(b.RuntimeEnvironment.Namespace).Create(context, service, metav1.CreateOptions{})
+	return err
+}
+
+// desiredEnv returns the environment of the JuiceShop container of the instance. Deployments claimed from the warm pool keep the webhook url and LLM token secret they were started with.
+func desiredEnv(b *bundle.Bundle, instance *JuiceShopInstance, deployment *appsv1.Deployment) []corev1.EnvVar {
+	if deployment != nil && isFromWarmPool(deployment) {
+		return instance.env(buildJuiceShopEnv(b, deployment.Name))
+	}
+	return instance.env(buildJuiceShopEnv(b, DeploymentName(instance.Name)))
+}
+
+// buildJuiceShopEnv returns the globally configured environment and the environment of the application, followed by the variables connecting the application to MultiJuicer.
+// The solutions webhook url and the LLM token are read from the secrets of the deployment with the given name, see CreateWebhookSecret and CreateLLMTokenSecret.
+func buildJuiceShopEnv(b *bundle.Bundle, deploymentName string) []corev1.EnvVar {
+	envVars := append(slices.Clone(b.Config.JuiceShopConfig.Env), b.Application.Container().Env...)
+	envVars = append(
+		envVars,
+		corev1.EnvVar{
+			Name: "SOLUTIONS_WEBHOOK",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: webhookSecretName(deploymentName),
+					},
+					Key: "url",
+				},
+			},
+		},
+	)
+
+	if b.Config.JuiceShopConfig.LLM.Enabled {
+		envVars = append(envVars, corev1.EnvVar{
+			Name: "LLM_API_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: deploymentName,
+					},
+					Key: "token",
+				},
+			},
+		})
+	}
+
+	return envVars
+}
+
+// solutionsWebhookUrl returns the url of the solutions webhook. The JuiceShop can't send custom headers with the webhook, so the signed identity is passed as token query parameter.
+// Without it, a team with code execution in its JuiceShop could credit solves to other teams by changing the team in the path.
+// The identity is signed with the bundle.WebhookIdentityPrefix, so that the token can't be used as team cookie.
+func solutionsWebhookUrl(b *bundle.Bundle, webhookPath string, identity string) (string, error) {
+	token, err := signutil.Sign(bundle.WebhookIdentityPrefix+identity, b.Config.CookieConfig.SigningKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign solutions webhook token: %w", err)
+	}
+	return fmt.Sprintf("http://multijuicer-private.%s.svc.cluster.local/%s/webhook?token=%s", b.RuntimeEnvironment.Namespace, webhookPath, url.QueryEscape(token)), nil
+}
+
+func webhookSecretName(deploymentName string) string {
+	return deploymentName + "-webhook"
+}
+
+// CreateWebhookSecret creates the secret holding the solutions webhook url of the team. It's owned by the deployment of the team.
+func CreateWebhookSecret(ctx context.Context, b *bundle.Bundle, team string, ownerDeployment *appsv1.Deployment) error {
+	return createWebhookSecret(ctx, b, DeploymentName(team), fmt.Sprintf("team/%s", team), team, map[string]string{"team": team}, ownerDeployment)
+}
+
+// createWebhookSecret creates the secret with the solutions webhook url of the deployment with the given name. The identity is either a team or, for the warm pool, the name of a deployment prefixed with bundle.InstanceIdentityPrefix.
+// The url contains the token, keeping it in a secret instead of the env of the deployment keeps it from everyone allowed to read deployments.
+func createWebhookSecret(ctx context.Context, b *bundle.Bundle, deploymentName string, webhookPath string, identity string, labels map[string]string, ownerDeployment *appsv1.Deployment) error {
+	webhookUrl, err := solutionsWebhookUrl(b, webhookPath, identity)
+	if err != nil {
+		return err
+	}
+
+	secretLabels := maps.Clone(labels)
+	secretLabels["app.kubernetes.io/component"] = "solutions-webhook"
+	secretLabels["app.kubernetes.io/part-of"] = "multi-juicer"
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            webhookSecretName(deploymentName),
+			Labels:          secretLabels,
+			OwnerReferences: getDeploymentOwnerReferences(ownerDeployment),
+		},
+		Data: map[string][]byte{
+			"url": []byte(webhookUrl),
+		},
+	}
+
+	_, err = b.ClientSet.CoreV1().Secrets(b.RuntimeEnvironment.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create solutions webhook secret: %w", err)
+	}
+	return nil
+}
+
+// CreateLLMTokenSecret creates the secret holding the token the JuiceShop of the team uses to authenticate against the LLM gateway. It's owned by the deployment of the team.
+func CreateLLMTokenSecret(ctx context.Context, b *bundle.Bundle, team string, ownerDeployment *appsv1.Deployment) error {
+	return createLLMTokenSecret(ctx, b, DeploymentName(team), team, map[string]string{"team": team}, ownerDeployment)
+}
+
+// createLLMTokenSecret creates a secret with a signed identity as LLM token. The identity is either a team or, for the warm pool, the name of a deployment prefixed with bundle.InstanceIdentityPrefix.
+func createLLMTokenSecret(ctx context.Context, b *bundle.Bundle, name string, identity string, labels map[string]string, ownerDeployment *appsv1.Deployment) error {
+	token, err := signutil.Sign(identity, b.Config.CookieConfig.SigningKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign LLM token: %w", err)
+	}
+
+	secretLabels := maps.Clone(labels)
+	secretLabels["app.kubernetes.io/component"] = "llm-token"
+	secretLabels["app.kubernetes.io/part-of"] = "multi-juicer"
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Labels:          secretLabels,
+			OwnerReferences: getDeploymentOwnerReferences(ownerDeployment),
+		},
+		Data: map[string][]byte{
+			"token": []byte(token),
+		},
+	}
+
+	_, err = b.ClientSet.CoreV1().Secrets(b.RuntimeEnvironment.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create LLM token secret: %w", err)
+	}
+	return nil
+}
@@ -46,11 +46,16 @@ func NewGateway(b *bundle.Bundle, usage *UsageTracker) (*Gateway, error) {
 
 // decodeTeamFromToken verifies the HMAC signature on a team token and returns the
 // enclosed team name, or "" if the token is invalid.
+// Tokens of warm pool instances enclose the name of their deployment instead, these
+// resolve to the team that claimed the deployment, or "" while it's unclaimed.
 func (g *Gateway) decodeTeamFromToken(token string) string {
 	team, err := signutil.Unsign(token, g.b.Config.CookieConfig.SigningKey)
 	if err != nil {
 		return ""
 	}
+	if deploymentName, ok := strings.CutPrefix(team, bundle.InstanceIdentityPrefix); ok {
+		team, _ = g.b.GetTeamOfJuiceShopDeployment(deploymentName)
+	}
 	return team
 }
 
@@ -11,6 +11,9 @@ import (
 	"github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/signutil"
 	"github.com/juice-shop/multi-juicer/internal/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 const testSigningKey = "test-secret-key"
@@ -99,6 +102,64 @@ func TestGateway_ProxiesWithRealKey(t *testing.T) {
 	}
 }
 
+func TestGateway_WarmPoolInstanceToken(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"usage": map[string]any{"prompt_tokens": 1, "completion_tokens": 2},
+		})
+	}))
+	defer upstream.Close()
+
+	newBundleWithDeployment := func(labels map[string]string) *bundle.Bundle {
+		b := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "juiceshop-pool-abc", Namespace: "test-namespace", Labels: labels},
+		}))
+		b.Config.CookieConfig.SigningKey = testSigningKey
+		b.Config.JuiceShopConfig.LLM.ApiUrl = upstream.URL
+		b.Config.JuiceShopConfig.LLM.ApiKey = "real-api-key"
+		return b
+	}
+	request := func(gw *Gateway) int {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"test","messages":[]}`))
+		req.Header.Set("Authorization", "Bearer "+signToken("instance:juiceshop-pool-abc"))
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("tracks usage for the team that claimed the instance", func(t *testing.T) {
+		usage := NewUsageTracker()
+		gw, _ := NewGateway(newBundleWithDeployment(map[string]string{
+			"app.kubernetes.io/name":    "juice-shop",
+			"app.kubernetes.io/part-of": "multi-juicer",
+			"team":                      "team-a",
+		}), usage)
+
+		if code := request(gw); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		usage.mu.Lock()
+		_, ok := usage.usage["team-a"]
+		usage.mu.Unlock()
+		if !ok {
+			t.Fatal("expected usage for team-a")
+		}
+	})
+
+	t.Run("rejects unclaimed instances", func(t *testing.T) {
+		gw, _ := NewGateway(newBundleWithDeployment(map[string]string{
+			"app.kubernetes.io/name":             "juice-shop",
+			"app.kubernetes.io/part-of":          "multi-juicer",
+			"multi-juicer.owasp-juice.shop/pool": "true",
+		}), NewUsageTracker())
+
+		if code := request(gw); code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", code)
+		}
+	})
+}
+
 func TestGateway_NonCompletionEndpoint_NoUsageTracking(t *testing.T) {
 	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
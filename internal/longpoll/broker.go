@@ -0,0 +1,124 @@
+package longpoll
+
+import (
+	"sync"
+)
+
+const (
+	TopicScoreBoard    = "score-board"
+	TopicActivityFeed  = "activity-feed"
+	TopicNotifications = "notifications"
+	// teamStatusTopicPrefix is followed by the team name, see TopicTeamStatus
+	teamStatusTopicPrefix = "team-status/"
+)
+
+// TopicTeamStatus returns the topic for changes to the status of a single team
+func TopicTeamStatus(team string) string {
+	return teamStatusTopicPrefix + team
+}
+
+// Broker fans out change notifications to subscribers, e.g. server-sent event connections.
+// Notifications only carry the topic which changed, subscribers fetch the current state themselves.
+// Publishing never blocks: multiple changes to the same topic are coalesced until the subscriber catches up.
+type Broker struct {
+	mutex       *sync.Mutex
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		mutex:       &sync.Mutex{},
+		subscribers: map[string]map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives change notifications for the topics it subscribed to until it is closed.
+type Subscription struct {
+	broker *Broker
+	topics []string
+
+	mutex   *sync.Mutex
+	pending map[string]struct{}
+	notify  chan struct{}
+}
+
+// Subscribe registers a new subscription for the given topics. The subscription must be closed once it isn't needed anymore.
+func (b *Broker) Subscribe(topics ...string) *Subscription {
+	subscription := &Subscription{
+		broker:  b,
+		topics:  topics,
+		mutex:   &sync.Mutex{},
+		pending: map[string]struct{}{},
+		notify:  make(chan struct{}, 1),
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, topic := range topics {
+		if _, ok := b.subscribers[topic]; !ok {
+			b.subscribers[topic] = map[*Subscription]struct{}{}
+		}
+		b.subscribers[topic][subscription] = struct{}{}
+	}
+	return subscription
+}
+
+// Publish notifies all subscribers of the topic that it has changed
+func (b *Broker) Publish(topic string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for subscription := range b.subscribers[topic] {
+		subscription.markPending(topic)
+	}
+}
+
+// SubscriberCount returns the number of subscriptions for the topic
+func (b *Broker) SubscriberCount(topic string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.subscribers[topic])
+}
+
+func (s *Subscription) markPending(topic string) {
+	s.mutex.Lock()
+	s.pending[topic] = struct{}{}
+	s.mutex.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+		// a notification is already queued, the subscriber will pick up this topic with it
+	}
+}
+
+// Changes returns a channel which receives a value whenever at least one of the subscribed topics changed.
+// Use Drain to find out which ones.
+func (s *Subscription) Changes() <-chan struct{} {
+	return s.notify
+}
+
+// Drain returns the topics which changed since the last call and resets them
+func (s *Subscription) Drain() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	topics := make([]string, 0, len(s.pending))
+	for _, topic := range s.topics {
+		if _, ok := s.pending[topic]; ok {
+			topics = append(topics, topic)
+		}
+	}
+	clear(s.pending)
+	return topics
+}
+
+// Close unregisters the subscription from the broker
+func (s *Subscription) Close() {
+	s.broker.mutex.Lock()
+	defer s.broker.mutex.Unlock()
+	for _, topic := range s.topics {
+		delete(s.broker.subscribers[topic], s)
+		if len(s.broker.subscribers[topic]) == 0 {
+			delete(s.broker.subscribers, topic)
+		}
+	}
+}
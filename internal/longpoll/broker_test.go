@@ -0,0 +1,73 @@
+package longpoll
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBroker_NotifiesSubscribersOfTheirTopics(t *testing.T) {
+	broker := NewBroker()
+	scoreBoard := broker.Subscribe(TopicScoreBoard, TopicNotifications)
+	defer scoreBoard.Close()
+	team := broker.Subscribe(TopicTeamStatus("foobar"))
+	defer team.Close()
+
+	broker.Publish(TopicNotifications)
+
+	select {
+	case <-scoreBoard.Changes():
+	case <-time.After(time.Second):
+		t.Fatal("Expected subscriber to be notified")
+	}
+	if topics := scoreBoard.Drain(); !slices.Equal(topics, []string{TopicNotifications}) {
+		t.Errorf("Expected only the notifications topic to be pending, got %v", topics)
+	}
+
+	select {
+	case <-team.Changes():
+		t.Error("Expected subscriber of other topics not to be notified")
+	default:
+	}
+}
+
+func TestBroker_CoalescesChangesForSlowSubscribers(t *testing.T) {
+	broker := NewBroker()
+	subscription := broker.Subscribe(TopicScoreBoard, TopicActivityFeed)
+	defer subscription.Close()
+
+	for range 100 {
+		broker.Publish(TopicScoreBoard)
+		broker.Publish(TopicActivityFeed)
+	}
+
+	<-subscription.Changes()
+	topics := subscription.Drain()
+	if !slices.Equal(topics, []string{TopicScoreBoard, TopicActivityFeed}) {
+		t.Errorf("Expected both topics to be pending exactly once, got %v", topics)
+	}
+	if topics := subscription.Drain(); len(topics) != 0 {
+		t.Errorf("Expected no pending topics after drain, got %v", topics)
+	}
+}
+
+func TestBroker_CloseUnsubscribes(t *testing.T) {
+	broker := NewBroker()
+	subscription := broker.Subscribe(TopicScoreBoard)
+
+	if count := broker.SubscriberCount(TopicScoreBoard); count != 1 {
+		t.Errorf("Expected 1 subscriber, got %d", count)
+	}
+
+	subscription.Close()
+	broker.Publish(TopicScoreBoard)
+
+	if count := broker.SubscriberCount(TopicScoreBoard); count != 0 {
+		t.Errorf("Expected 0 subscribers after close, got %d", count)
+	}
+	select {
+	case <-subscription.Changes():
+		t.Error("Expected closed subscription not to be notified")
+	default:
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/longpoll"
 	"github.com/juice-shop/multi-juicer/internal/timeutil"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -148,6 +149,8 @@ func (s *NotificationService) watchConfigMap(ctx context.Context) {
 }
 
 func (s *NotificationService) parseAndUpdateNotification(cm *corev1.ConfigMap) {
+	// deferred first, so that it runs after the mutex got unlocked
+	defer s.bundle.Broker.Publish(longpoll.TopicNotifications)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -0,0 +1,402 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Events a notifier can subscribe to. Apart from first bloods they match the types of the EventLog.
+const (
+	EventChallengeSolved = string(bundle.EventTypeChallengeSolved)
+	// EventFirstBlood is the first solve of a challenge. It's posted instead of the challenge_solved event to notifiers subscribed to it.
+	EventFirstBlood   = "first_blood"
+	EventTeamCreated  = string(bundle.EventTypeTeamCreated)
+	EventInstanceDown = string(bundle.EventTypeInstanceDown)
+)
+
+var allEvents = []string{EventChallengeSolved, EventFirstBlood, EventTeamCreated, EventInstanceDown}
+
+var defaultTemplates = map[string]string{
+	EventChallengeSolved: `Team {{ .Team }} solved "{{ .Challenge }}"`,
+	EventFirstBlood:      `First blood! Team {{ .Team }} is the first to solve "{{ .Challenge }}"`,
+	EventTeamCreated:     `Team {{ .Team }} joined`,
+	EventInstanceDown:    `The JuiceShop of team {{ .Team }} is down`,
+}
+
+const (
+	pollInterval = 1 * time.Second
+	// queueSize is the number of notifications buffered per notifier while it's busy retrying a delivery
+	queueSize           = 100
+	maxDeliveryAttempts = 5
+	initialRetryBackoff = 1 * time.Second
+	maxRetryBackoff     = 30 * time.Second
+	requestTimeout      = 10 * time.Second
+	// restartGracePeriod in which instances going down aren't reported, as an admin restarted them
+	restartGracePeriod = 2 * time.Minute
+)
+
+var deliveriesCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "multijuicer_notifier_deliveries",
+		Help: `Number of notifications posted by the notifiers (see labels "notifier" and "result").`,
+	},
+	[]string{"notifier", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesCounter)
+}
+
+// Notification is an event posted by the notifiers. Its fields can be used in the templates of the notifiers.
+type Notification struct {
+	Event        string    `json:"event"`
+	Team         string    `json:"team,omitempty"`
+	Challenge    string    `json:"challenge,omitempty"`
+	ChallengeKey string    `json:"challengeKey,omitempty"`
+	Difficulty   int       `json:"difficulty,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+	Details      string    `json:"details,omitempty"`
+}
+
+type target struct {
+	config    bundle.NotifierConfig
+	events    map[string]bool
+	templates map[string]*template.Template
+	queue     chan Notification
+}
+
+// Notifier posts solves, first bloods, team registrations and instance outages to the chat and HTTP endpoints configured in bundle.NotifierConfig.
+// It follows the EventLog, which is shared by all replicas, so the events are posted once by the leader no matter which replica recorded them.
+type Notifier struct {
+	bundle       *bundle.Bundle
+	targets      []*target
+	httpClient   *http.Client
+	pollInterval time.Duration
+	retryBackoff time.Duration
+}
+
+// New parses the templates of the configured notifiers
+func New(b *bundle.Bundle) (*Notifier, error) {
+	n := &Notifier{
+		bundle:       b,
+		httpClient:   &http.Client{Timeout: requestTimeout},
+		pollInterval: pollInterval,
+		retryBackoff: initialRetryBackoff,
+	}
+	for _, config := range b.Config.Notifiers {
+		t := &target{
+			config:    config,
+			events:    map[string]bool{},
+			templates: map[string]*template.Template{},
+		}
+		events := config.Events
+		if len(events) == 0 {
+			events = allEvents
+		}
+		for _, event := range events {
+			if _, ok := defaultTemplates[event]; !ok {
+				return nil, fmt.Errorf("notifier %q subscribes to unknown event %q. must be one of: %s", config.Name, event, strings.Join(allEvents, ", "))
+			}
+			t.events[event] = true
+		}
+		for _, event := range allEvents {
+			text := defaultTemplates[event]
+			if custom, ok := config.Templates[event]; ok {
+				text = custom
+			}
+			tmpl, err := template.New(event).Option("missingkey=error").Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the %s template of notifier %q: %w", event, config.Name, err)
+			}
+			t.templates[event] = tmpl
+		}
+		n.targets = append(n.targets, t)
+	}
+	return n, nil
+}
+
+// Start records instances going down in the EventLog and posts the events recorded after the start to the notifiers until ctx is canceled.
+// Must only run on the leader. Events recorded while no replica held the lease aren't posted.
+func (n *Notifier) Start(ctx context.Context) {
+	registration, err := n.bundle.JuiceShopInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj any) {
+			deployment := newObj.(*appsv1.Deployment)
+			if instanceWentDown(oldObj.(*appsv1.Deployment), deployment) {
+				go n.recordInstanceDown(ctx, deployment.Labels["team"])
+			}
+		},
+	})
+	if err != nil {
+		n.bundle.Log.Error("Failed to watch JuiceShop deployments going down", "error", err)
+	} else {
+		defer n.bundle.JuiceShopInformer.RemoveEventHandler(registration)
+	}
+
+	if len(n.targets) == 0 {
+		<-ctx.Done()
+		return
+	}
+
+	n.bundle.Log.Info("Starting notifier", "notifiers", len(n.targets))
+	for _, t := range n.targets {
+		t.queue = make(chan Notification, queueSize)
+		go n.deliverQueued(ctx, t)
+	}
+
+	state := newDispatchState(time.Now())
+	ticker := time.NewTicker(n.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.bundle.Log.Info("Notifier stopped")
+			return
+		case <-ticker.C:
+			n.dispatch(state)
+		}
+	}
+}
+
+// dispatchState remembers which events the notifier already dispatched
+type dispatchState struct {
+	startedAt time.Time
+	// offsets is the number of events of each chunk of the EventLog which were dispatched already
+	offsets map[int]int
+	// firstBloods are the challenges a first blood was dispatched for
+	firstBloods map[string]bool
+}
+
+func newDispatchState(startedAt time.Time) *dispatchState {
+	return &dispatchState{
+		startedAt:   startedAt,
+		offsets:     map[int]int{},
+		firstBloods: map[string]bool{},
+	}
+}
+
+// dispatch queues the notifications of all events recorded since startedAt which weren't dispatched yet.
+// Events of other replicas can show up late and out of order, so the dispatched events are tracked per chunk of the EventLog instead of by timestamp.
+func (n *Notifier) dispatch(state *dispatchState) {
+	var events []bundle.Event
+	events, state.offsets = n.bundle.EventLog.GetEventsSince(state.offsets)
+	for _, event := range events {
+		if event.Timestamp.Before(state.startedAt) {
+			continue
+		}
+		notification, ok := n.toNotification(event)
+		if !ok {
+			continue
+		}
+		firstBlood := false
+		if event.Type == bundle.EventTypeChallengeSolved {
+			firstBlood = !state.firstBloods[event.ChallengeKey] && n.isFirstSolve(event)
+			if firstBlood {
+				state.firstBloods[event.ChallengeKey] = true
+			}
+		}
+		for _, t := range n.targets {
+			t.enqueue(n.bundle, notification, firstBlood)
+		}
+	}
+}
+
+// isFirstSolve checks the scores for other teams which solved the challenge before the team of the event.
+// Unlike the EventLog the scores include solves from before the EventLog existed.
+func (n *Notifier) isFirstSolve(event bundle.Event) bool {
+	scores := n.bundle.ScoringService.GetScores()
+	solvedAt := event.Timestamp
+	if score, ok := scores[event.Team]; ok {
+		for _, challenge := range score.Challenges {
+			if challenge.Key == event.ChallengeKey {
+				solvedAt = challenge.SolvedAt
+			}
+		}
+	}
+	for team, score := range scores {
+		if team == event.Team {
+			continue
+		}
+		for _, challenge := range score.Challenges {
+			if challenge.Key == event.ChallengeKey && challenge.SolvedAt.Before(solvedAt) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (n *Notifier) toNotification(event bundle.Event) (Notification, bool) {
+	switch event.Type {
+	case bundle.EventTypeChallengeSolved, bundle.EventTypeTeamCreated, bundle.EventTypeInstanceDown:
+	default:
+		return Notification{}, false
+	}
+	notification := Notification{
+		Event:        string(event.Type),
+		Team:         event.Team,
+		ChallengeKey: event.ChallengeKey,
+		Timestamp:    event.Timestamp,
+		Details:      event.Details,
+	}
+	for _, challenge := range n.bundle.JuiceShopChallenges {
+		if challenge.Key == event.ChallengeKey {
+			notification.Challenge = challenge.Name
+			notification.Difficulty = challenge.Difficulty
+			break
+		}
+	}
+	return notification, true
+}
+
+func (t *target) enqueue(b *bundle.Bundle, notification Notification, firstBlood bool) {
+	if firstBlood && t.events[EventFirstBlood] {
+		notification.Event = EventFirstBlood
+	}
+	if !t.events[notification.Event] {
+		return
+	}
+	select {
+	case t.queue <- notification:
+	default:
+		b.Log.Warn("Notifier queue is full, dropping notification", "notifier", t.config.Name, "event", notification.Event, "team", notification.Team)
+		deliveriesCounter.WithLabelValues(t.config.Name, "dropped").Inc()
+	}
+}
+
+func (n *Notifier) deliverQueued(ctx context.Context, t *target) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-t.queue:
+			if err := n.deliver(ctx, t, notification); err != nil {
+				n.bundle.Log.Error("Failed to post notification", "notifier", t.config.Name, "event", notification.Event, "team", notification.Team, "error", err)
+				deliveriesCounter.WithLabelValues(t.config.Name, "failed").Inc()
+				continue
+			}
+			deliveriesCounter.WithLabelValues(t.config.Name, "delivered").Inc()
+		}
+	}
+}
+
+// deliver posts the notification, retrying with an exponential backoff on network errors, rate limits and server errors
+func (n *Notifier) deliver(ctx context.Context, t *target, notification Notification) error {
+	body, err := t.payload(notification)
+	if err != nil {
+		return err
+	}
+
+	backoff := n.retryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, t.config.Url, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == maxDeliveryAttempts {
+			return err
+		}
+		n.bundle.Log.Debug("Posting notification failed, retrying", "notifier", t.config.Name, "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// post returns whether a failed request should be retried
+func (n *Notifier) post(ctx context.Context, endpoint string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.httpClient.Do(req)
+	if err != nil {
+		// don't leak the url in the logs, it usually contains the credentials of the webhook
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	default:
+		return false, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+}
+
+// payload renders the message of the notification and wraps it in the request body expected by the type of the notifier
+func (t *target) payload(notification Notification) ([]byte, error) {
+	var message strings.Builder
+	if err := t.templates[notification.Event].Execute(&message, notification); err != nil {
+		return nil, fmt.Errorf("failed to render the %s template: %w", notification.Event, err)
+	}
+
+	switch t.config.Type {
+	case bundle.NotifierTypeSlack, bundle.NotifierTypeTeams:
+		return json.Marshal(map[string]string{"text": message.String()})
+	case bundle.NotifierTypeDiscord:
+		return json.Marshal(map[string]string{"content": message.String()})
+	default:
+		return json.Marshal(struct {
+			Notification
+			Message string `json:"message"`
+		}{notification, message.String()})
+	}
+}
+
+// instanceWentDown returns true if the last ready pod of the instance of a team went away although it's supposed to run.
+// Hibernated instances are scaled down on purpose, unclaimed deployments of the warm pool don't belong to a team yet.
+func instanceWentDown(oldDeployment, newDeployment *appsv1.Deployment) bool {
+	if newDeployment.Labels["team"] == "" || newDeployment.Spec.Replicas == nil || *newDeployment.Spec.Replicas < 1 {
+		return false
+	}
+	return oldDeployment.Status.ReadyReplicas >= 1 && newDeployment.Status.ReadyReplicas < 1
+}
+
+func (n *Notifier) recordInstanceDown(ctx context.Context, team string) {
+	if restartedRecently(n.bundle.EventLog.GetEvents(), team, time.Now()) {
+		return
+	}
+	n.bundle.Log.Warn("JuiceShop instance is down", "team", team)
+	if err := n.bundle.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeInstanceDown, Team: team, Details: "no ready pod"}); err != nil {
+		n.bundle.Log.Error("Failed to record instance down in event log", "team", team, "error", err)
+	}
+}
+
+// restartedRecently returns true if an admin restarted the instance of the team within the restartGracePeriod
+func restartedRecently(events []bundle.Event, team string, now time.Time) bool {
+	for i := len(events) - 1; i >= 0; i-- {
+		if now.Sub(events[i].Timestamp) > restartGracePeriod {
+			return false
+		}
+		if events[i].Type == bundle.EventTypeInstanceRestarted && events[i].Team == team {
+			return true
+		}
+	}
+	return false
+}
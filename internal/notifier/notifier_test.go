@@ -0,0 +1,250 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// chatStandIn records the bodies of the requests it receives and answers with the given status codes, the last one is repeated
+type chatStandIn struct {
+	mutex    sync.Mutex
+	bodies   []map[string]any
+	statuses []int
+}
+
+func (s *chatStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	s.bodies = append(s.bodies, body)
+	status := s.statuses[min(len(s.bodies), len(s.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func newTestNotifier(t *testing.T, configs ...bundle.NotifierConfig) (*Notifier, *bundle.Bundle) {
+	b := testutil.NewTestBundle()
+	b.Config.Notifiers = configs
+	n, err := New(b)
+	require.NoError(t, err)
+	n.retryBackoff = time.Millisecond
+	for _, target := range n.targets {
+		target.queue = make(chan Notification, queueSize)
+	}
+	return n, b
+}
+
+func drain(queue chan Notification) []Notification {
+	notifications := []Notification{}
+	for {
+		select {
+		case notification := <-queue:
+			notifications = append(notifications, notification)
+		default:
+			return notifications
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	b := testutil.NewTestBundle()
+
+	t.Run("fails for unknown events", func(t *testing.T) {
+		b.Config.Notifiers = []bundle.NotifierConfig{{Name: "slack", Type: bundle.NotifierTypeSlack, Url: "http://localhost", Events: []string{"team_deleted"}}}
+		_, err := New(b)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails for invalid templates", func(t *testing.T) {
+		b.Config.Notifiers = []bundle.NotifierConfig{{Name: "slack", Type: bundle.NotifierTypeSlack, Url: "http://localhost", Templates: map[string]string{EventTeamCreated: "{{ .Team "}}}
+		_, err := New(b)
+		assert.Error(t, err)
+	})
+}
+
+func TestDispatch(t *testing.T) {
+	startedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	t.Run("queues the events recorded since the start once", func(t *testing.T) {
+		n, b := newTestNotifier(t,
+			bundle.NotifierConfig{Name: "everything", Type: bundle.NotifierTypeGeneric, Url: "http://localhost"},
+			bundle.NotifierConfig{Name: "solves-only", Type: bundle.NotifierTypeSlack, Url: "http://localhost", Events: []string{EventChallengeSolved}},
+		)
+		ctx := context.Background()
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeChallengeSolved, Team: "foobar", ChallengeKey: "scoreBoardChallenge", Timestamp: startedAt.Add(-time.Minute)}))
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeTeamCreated, Team: "barfoo", Timestamp: startedAt.Add(time.Second)}))
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeChallengeSolved, Team: "barfoo", ChallengeKey: "scoreBoardChallenge", Timestamp: startedAt.Add(2 * time.Second)}))
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeChallengeSolved, Team: "barfoo", ChallengeKey: "nullByteChallenge", Timestamp: startedAt.Add(3 * time.Second)}))
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypePasscodeReset, Team: "barfoo", Timestamp: startedAt.Add(4 * time.Second)}))
+
+		b.ScoringService = scoring.NewScoringServiceWithInitialScores(b, map[string]*bundle.TeamScore{
+			"foobar": {Name: "foobar", Challenges: []bundle.ChallengeProgress{{Key: "scoreBoardChallenge", SolvedAt: startedAt.Add(-time.Minute)}}},
+		})
+
+		state := newDispatchState(startedAt)
+		n.dispatch(state)
+		n.dispatch(state)
+
+		assert.Equal(t, []Notification{
+			{Event: EventTeamCreated, Team: "barfoo", Timestamp: startedAt.Add(time.Second)},
+			{Event: EventChallengeSolved, Team: "barfoo", Challenge: "Score Board", ChallengeKey: "scoreBoardChallenge", Difficulty: 1, Timestamp: startedAt.Add(2 * time.Second)},
+			{Event: EventFirstBlood, Team: "barfoo", Challenge: "Poison Null Byte", ChallengeKey: "nullByteChallenge", Difficulty: 4, Timestamp: startedAt.Add(3 * time.Second)},
+		}, drain(n.targets[0].queue))
+		assert.Equal(t, []Notification{
+			{Event: EventChallengeSolved, Team: "barfoo", Challenge: "Score Board", ChallengeKey: "scoreBoardChallenge", Difficulty: 1, Timestamp: startedAt.Add(2 * time.Second)},
+			{Event: EventChallengeSolved, Team: "barfoo", Challenge: "Poison Null Byte", ChallengeKey: "nullByteChallenge", Difficulty: 4, Timestamp: startedAt.Add(3 * time.Second)},
+		}, drain(n.targets[1].queue))
+	})
+
+	t.Run("announces first bloods only for challenges nobody solved before, including solves from before the event log", func(t *testing.T) {
+		n, b := newTestNotifier(t, bundle.NotifierConfig{Name: "first-bloods", Type: bundle.NotifierTypeSlack, Url: "http://localhost", Events: []string{EventFirstBlood}})
+		ctx := context.Background()
+		b.ScoringService = scoring.NewScoringServiceWithInitialScores(b, map[string]*bundle.TeamScore{
+			"foobar": {Name: "foobar", Challenges: []bundle.ChallengeProgress{{Key: "scoreBoardChallenge", SolvedAt: startedAt.Add(-24 * time.Hour)}}},
+		})
+		state := newDispatchState(startedAt)
+
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeChallengeSolved, Team: "barfoo", ChallengeKey: "scoreBoardChallenge", Timestamp: startedAt.Add(time.Second)}))
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeChallengeSolved, Team: "barfoo", ChallengeKey: "nullByteChallenge", Timestamp: startedAt.Add(2 * time.Second)}))
+		n.dispatch(state)
+		// another replica recorded a solve of the same challenge, it shows up after the first dispatch
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeChallengeSolved, Team: "foobar", ChallengeKey: "nullByteChallenge", Timestamp: startedAt.Add(3 * time.Second)}))
+		n.dispatch(state)
+
+		assert.Equal(t, []Notification{
+			{Event: EventFirstBlood, Team: "barfoo", Challenge: "Poison Null Byte", ChallengeKey: "nullByteChallenge", Difficulty: 4, Timestamp: startedAt.Add(2 * time.Second)},
+		}, drain(n.targets[0].queue))
+	})
+}
+
+func TestDeliver(t *testing.T) {
+	notification := Notification{Event: EventFirstBlood, Team: "foobar", Challenge: "Score Board", ChallengeKey: "scoreBoardChallenge", Difficulty: 1, Timestamp: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+
+	testCases := []struct {
+		name     string
+		notifier bundle.NotifierConfig
+		expected map[string]any
+	}{
+		{
+			name:     "slack",
+			notifier: bundle.NotifierConfig{Type: bundle.NotifierTypeSlack},
+			expected: map[string]any{"text": `First blood! Team foobar is the first to solve "Score Board"`},
+		},
+		{
+			name:     "discord",
+			notifier: bundle.NotifierConfig{Type: bundle.NotifierTypeDiscord},
+			expected: map[string]any{"content": `First blood! Team foobar is the first to solve "Score Board"`},
+		},
+		{
+			name:     "teams with a custom template",
+			notifier: bundle.NotifierConfig{Type: bundle.NotifierTypeTeams, Templates: map[string]string{EventFirstBlood: "{{ .Team }} drew first blood on {{ .Challenge }} ({{ .Difficulty }}★)"}},
+			expected: map[string]any{"text": "foobar drew first blood on Score Board (1★)"},
+		},
+		{
+			name:     "generic",
+			notifier: bundle.NotifierConfig{Type: bundle.NotifierTypeGeneric},
+			expected: map[string]any{
+				"event":        "first_blood",
+				"team":         "foobar",
+				"challenge":    "Score Board",
+				"challengeKey": "scoreBoardChallenge",
+				"difficulty":   float64(1),
+				"timestamp":    "2026-10-18T12:00:00Z",
+				"message":      `First blood! Team foobar is the first to solve "Score Board"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run("posts the notification to "+tc.name, func(t *testing.T) {
+			standIn := &chatStandIn{statuses: []int{http.StatusOK}}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			tc.notifier.Name = tc.name
+			tc.notifier.Url = server.URL
+			n, _ := newTestNotifier(t, tc.notifier)
+
+			assert.NoError(t, n.deliver(context.Background(), n.targets[0], notification))
+			assert.Equal(t, []map[string]any{tc.expected}, standIn.bodies)
+		})
+	}
+
+	t.Run("retries rate limits and server errors", func(t *testing.T) {
+		standIn := &chatStandIn{statuses: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusNoContent}}
+		server := httptest.NewServer(standIn)
+		defer server.Close()
+		n, _ := newTestNotifier(t, bundle.NotifierConfig{Name: "slack", Type: bundle.NotifierTypeSlack, Url: server.URL})
+
+		assert.NoError(t, n.deliver(context.Background(), n.targets[0], notification))
+		assert.Len(t, standIn.bodies, 3)
+	})
+
+	t.Run("gives up after the max attempts", func(t *testing.T) {
+		standIn := &chatStandIn{statuses: []int{http.StatusServiceUnavailable}}
+		server := httptest.NewServer(standIn)
+		defer server.Close()
+		n, _ := newTestNotifier(t, bundle.NotifierConfig{Name: "slack", Type: bundle.NotifierTypeSlack, Url: server.URL})
+
+		assert.Error(t, n.deliver(context.Background(), n.targets[0], notification))
+		assert.Len(t, standIn.bodies, maxDeliveryAttempts)
+	})
+
+	t.Run("doesn't retry client errors", func(t *testing.T) {
+		standIn := &chatStandIn{statuses: []int{http.StatusNotFound}}
+		server := httptest.NewServer(standIn)
+		defer server.Close()
+		n, _ := newTestNotifier(t, bundle.NotifierConfig{Name: "slack", Type: bundle.NotifierTypeSlack, Url: server.URL})
+
+		assert.Error(t, n.deliver(context.Background(), n.targets[0], notification))
+		assert.Len(t, standIn.bodies, 1)
+	})
+}
+
+func TestInstanceDown(t *testing.T) {
+	deployment := func(team string, replicas int32, readyReplicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": team}},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: readyReplicas},
+		}
+	}
+
+	t.Run("detects running instances losing their last ready pod", func(t *testing.T) {
+		assert.True(t, instanceWentDown(deployment("foobar", 1, 1), deployment("foobar", 1, 0)))
+		assert.False(t, instanceWentDown(deployment("foobar", 1, 0), deployment("foobar", 1, 0)))
+		assert.False(t, instanceWentDown(deployment("foobar", 1, 0), deployment("foobar", 1, 1)))
+	})
+
+	t.Run("ignores hibernated instances and the warm pool", func(t *testing.T) {
+		assert.False(t, instanceWentDown(deployment("foobar", 1, 1), deployment("foobar", 0, 0)))
+		assert.False(t, instanceWentDown(deployment("", 1, 1), deployment("", 1, 0)))
+	})
+
+	t.Run("records the outage in the event log unless an admin just restarted the instance", func(t *testing.T) {
+		n, b := newTestNotifier(t)
+		ctx := context.Background()
+		require.NoError(t, b.EventLog.Append(ctx, bundle.Event{Type: bundle.EventTypeInstanceRestarted, Team: "barfoo", Actor: "admin"}))
+
+		n.recordInstanceDown(ctx, "foobar")
+		n.recordInstanceDown(ctx, "barfoo")
+
+		events := b.EventLog.GetEvents()
+		require.Len(t, events, 2)
+		assert.Equal(t, bundle.EventTypeInstanceDown, events[1].Type)
+		assert.Equal(t, "foobar", events[1].Team)
+	})
+}
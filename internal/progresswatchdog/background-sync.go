@@ -1,21 +1,17 @@
 package progresswatchdog
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"log/slog"
-	"net/http"
-	"os"
+	"slices"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
-	"github.com/speps/go-hashids/v2"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
 )
 
 const workerCount = 10
@@ -23,29 +19,8 @@ const workerCount = 10
 type ProgressUpdateJobs struct {
 	Team                  string
 	LastChallengeProgress []ChallengeStatus
-}
-
-type ChallengeResponse struct {
-	Status string      `json:"status"`
-	Data   []Challenge `json:"data"`
-}
-type Challenge struct {
-	Id          int    `json:"id"`
-	Name        string `json:"name"`
-	Key         string `json:"key"`
-	Description string `json:"description"`
-	Category    string `json:"category"`
-	Difficulty  int    `json:"difficulty"`
-	Solved      bool   `json:"solved"`
-	UpdatedAt   string `json:"updatedAt"`
-}
-
-var challengeIdLookup = map[string]int{}
-var challengeIdLookupOnce sync.Once
-
-// JuiceShopChallenge represents a challenge in the Juice Shop config file. reduced to just the key, everything else is not needed
-type JuiceShopChallenge struct {
-	Key string `json:"key"`
+	// RevokedChallenges are left out of the progress fetched from the JuiceShop, so that revoked solves don't come back
+	RevokedChallenges map[string]bool
 }
 
 // StartBackgroundSync runs the JuiceShop progress reconciliation loop. It blocks until ctx is cancelled.
@@ -53,10 +28,28 @@ type JuiceShopChallenge struct {
 func StartBackgroundSync(ctx context.Context, b *bundle.Bundle) {
 	b.Log.Info("Starting background-sync looking for JuiceShop challenge progress changes", "workers", workerCount)
 
-	challengeIdLookupOnce.Do(createChallengeIdLookup)
-
 	progressUpdateJobs := make(chan ProgressUpdateJobs)
 
+	// JuiceShops waking up from hibernation or coming back after a restart lost their progress, they get synced as soon as they are ready
+	readyDeployments := make(chan *appsv1.Deployment, workerCount)
+	registration, err := b.JuiceShopInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj any) {
+			oldDeployment, newDeployment := oldObj.(*appsv1.Deployment), newObj.(*appsv1.Deployment)
+			if oldDeployment.Status.ReadyReplicas < 1 && newDeployment.Status.ReadyReplicas >= 1 {
+				select {
+				case readyDeployments <- newDeployment:
+				default:
+					// the periodic sync picks it up if the workers are busy
+				}
+			}
+		},
+	})
+	if err != nil {
+		b.Log.Error("Failed to watch JuiceShop deployments becoming ready, only syncing periodically", "error", err)
+	} else {
+		defer b.JuiceShopInformer.RemoveEventHandler(registration)
+	}
+
 	var wg sync.WaitGroup
 	for range workerCount {
 		wg.Go(func() {
@@ -64,78 +57,73 @@ func StartBackgroundSync(ctx context.Context, b *bundle.Bundle) {
 		})
 	}
 
-	createProgressUpdateJobs(ctx, b, progressUpdateJobs)
+	createProgressUpdateJobs(ctx, b, progressUpdateJobs, readyDeployments)
 
 	close(progressUpdateJobs)
 	wg.Wait()
 	b.Log.Info("Background-sync stopped")
 }
 
-func createChallengeIdLookup() {
-	challengesBytes, err := os.ReadFile("/challenges.json")
-	if err != nil {
-		panic(fmt.Errorf("failed to read challenges.json. This is fatal as the progress watchdog needs it to map between challenge keys and challenge ids: %w", err))
-	}
-
-	var challenges []JuiceShopChallenge
-	err = json.Unmarshal(challengesBytes, &challenges)
-	if err != nil {
-		panic(fmt.Errorf("failed to decode challenges.json. This is fatal as the progress watchdog needs it to map between challenge keys and challenge ids: %w", err))
-	}
-
-	for i, challenge := range challenges {
-		challengeIdLookup[challenge.Key] = i + 1
-	}
-}
-
 // Lists all JuiceShops managed by MultiJuicer and queues progressUpdateJobs for them, looping until ctx is cancelled.
-func createProgressUpdateJobs(ctx context.Context, b *bundle.Bundle, progressUpdateJobs chan<- ProgressUpdateJobs) {
+// In between, JuiceShops that just became ready are queued right away.
+func createProgressUpdateJobs(ctx context.Context, b *bundle.Bundle, progressUpdateJobs chan<- ProgressUpdateJobs, readyDeployments <-chan *appsv1.Deployment) {
 	for {
-		opts := metav1.ListOptions{
-			LabelSelector: "app.kubernetes.io/name=juice-shop",
-		}
-		juiceShops, err := b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).List(ctx, opts)
+		juiceShops, err := b.JuiceShopLister.List(labels.Everything())
 		if err != nil {
-			if ctx.Err() != nil {
-				return
-			}
 			b.Log.Error("Failed to list JuiceShop deployments", "error", err)
 		} else {
-			b.Log.Debug("Background-sync started syncing instances", "count", len(juiceShops.Items))
-
-			for _, instance := range juiceShops.Items {
-				team := instance.Labels["team"]
+			b.Log.Debug("Background-sync started syncing instances", "count", len(juiceShops))
 
+			for _, instance := range juiceShops {
 				if instance.Status.ReadyReplicas != 1 {
 					continue
 				}
-
-				var lastChallengeProgress []ChallengeStatus
-				json.Unmarshal([]byte(instance.Annotations["multi-juicer.owasp-juice.shop/challenges"]), &lastChallengeProgress)
-
-				select {
-				case <-ctx.Done():
+				if !queueProgressUpdateJob(ctx, progressUpdateJobs, instance) {
 					return
-				case progressUpdateJobs <- ProgressUpdateJobs{
-					Team:                  team,
-					LastChallengeProgress: lastChallengeProgress,
-				}:
 				}
 			}
 		}
 
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(60 * time.Second):
+		nextSync := time.After(60 * time.Second)
+	waitForNextSync:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case instance := <-readyDeployments:
+				b.Log.Debug("JuiceShop became ready, syncing its progress", "team", instance.Labels["team"])
+				if !queueProgressUpdateJob(ctx, progressUpdateJobs, instance) {
+					return
+				}
+			case <-nextSync:
+				break waitForNextSync
+			}
 		}
 	}
 }
 
+// queueProgressUpdateJob returns false if ctx got cancelled before a worker picked up the job
+func queueProgressUpdateJob(ctx context.Context, progressUpdateJobs chan<- ProgressUpdateJobs, instance *appsv1.Deployment) bool {
+	var lastChallengeProgress []ChallengeStatus
+	json.Unmarshal([]byte(instance.Annotations["multi-juicer.owasp-juice.shop/challenges"]), &lastChallengeProgress)
+	revokedChallenges, _ := ParseRevokedChallenges(instance)
+
+	select {
+	case <-ctx.Done():
+		return false
+	case progressUpdateJobs <- ProgressUpdateJobs{
+		Team:                  instance.Labels["team"],
+		LastChallengeProgress: lastChallengeProgress,
+		RevokedChallenges:     revokedChallenges,
+	}:
+		return true
+	}
+}
+
 func workOnProgressUpdates(ctx context.Context, b *bundle.Bundle, progressUpdateJobs <-chan ProgressUpdateJobs) {
 	for job := range progressUpdateJobs {
 		lastChallengeProgress := job.LastChallengeProgress
-		challengeProgress, err := getCurrentChallengeProgress(job.Team)
+		challengeProgress, err := getCurrentChallengeProgress(ctx, b, job.Team, job.RevokedChallenges, lastChallengeProgress)
 
 		if err != nil {
 			b.Log.Error("failed to fetch current Challenge Progress from Juice Shop", "team", job.Team, "error", err)
@@ -151,7 +139,9 @@ func workOnProgressUpdates(ctx context.Context, b *bundle.Bundle, progressUpdate
 		switch CompareChallengeStates(challengeProgress, lastChallengeProgress) {
 		case ApplyCode:
 			b.Log.Debug("Last ContinueCode contains unsolved challenges", "team", job.Team)
-			applyChallengeProgress(b.Log, job.Team, lastChallengeProgress)
+			if err := b.Application.RestoreProgress(ctx, b.GetJuiceShopUrlForTeam(job.Team, b), lastChallengeProgress); err != nil {
+				b.Log.Error("failed to restore challenge progress", "team", job.Team, "error", err)
+			}
 
 			if frozen {
 				// Scoreboard frozen: progress was restored to the pod, but we don't
@@ -159,107 +149,40 @@ func workOnProgressUpdates(ctx context.Context, b *bundle.Bundle, progressUpdate
 				continue
 			}
 
-			challengeProgress, err = getCurrentChallengeProgress(job.Team)
+			challengeProgress, err = getCurrentChallengeProgress(ctx, b, job.Team, job.RevokedChallenges, lastChallengeProgress)
 
 			if err != nil {
 				b.Log.Error("failed to re-fetch challenge progress from Juice Shop to reapply it", "team", job.Team, "error", err)
 				continue
 			}
-			PersistProgress(ctx, b, job.Team, challengeProgress, nil)
+			PersistProgress(ctx, b, job.Team, challengeProgress, nil, nil)
 		case UpdateCache:
 			if frozen {
 				b.Log.Debug("Scoreboard frozen, ignoring newly discovered solves from background-sync", "team", job.Team)
 				continue
 			}
-			PersistProgress(ctx, b, job.Team, challengeProgress, nil)
+			PersistProgress(ctx, b, job.Team, challengeProgress, nil, nil)
 		case NoOp:
 		}
 	}
 }
 
-func getCurrentChallengeProgress(team string) ([]ChallengeStatus, error) {
-	url := fmt.Sprintf("http://juiceshop-%s:3000/api/challenges", team)
-
-	req, err := http.NewRequest("GET", url, bytes.NewBuffer([]byte{}))
+// getCurrentChallengeProgress returns the solved challenges of the instance of the team without the revoked ones, sorted by key like the persisted progress.
+// The progress of the instance isn't verified, teams can mark challenges as solved in their own instance. While CTF flags are verified, only challenges
+// which are already recorded (via the verified solutions webhook or an admin) are kept, so the background sync only restores progress but never adds solves.
+func getCurrentChallengeProgress(ctx context.Context, b *bundle.Bundle, team string, revokedChallenges map[string]bool, lastChallengeProgress []ChallengeStatus) ([]ChallengeStatus, error) {
+	challengeStatus, err := b.Application.FetchProgress(ctx, b.GetJuiceShopUrlForTeam(team, b))
 	if err != nil {
-		panic("Failed to create http request")
-	}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, errors.New("failed to fetch Challenge Status")
-	}
-	defer res.Body.Close()
-
-	switch res.StatusCode {
-	case 200:
-		challengeResponse := ChallengeResponse{}
-
-		err = json.NewDecoder(res.Body).Decode(&challengeResponse)
-		if err != nil {
-			return nil, errors.New("failed to parse JSON from Juice Shop Challenge Status response")
-		}
-
-		challengeStatus := make(ChallengeStatuses, 0)
-
-		for _, challenge := range challengeResponse.Data {
-			if challenge.Solved {
-				challengeStatus = append(challengeStatus, ChallengeStatus{
-					Key:      challenge.Key,
-					SolvedAt: challenge.UpdatedAt,
-				})
-			}
-		}
-
-		sort.Stable(challengeStatus)
-
-		return challengeStatus, nil
-	default:
-		return nil, fmt.Errorf("unexpected response status code '%d' from Juice Shop", res.StatusCode)
+		return nil, err
 	}
-}
-
-func applyChallengeProgress(log *slog.Logger, team string, challengeProgress []ChallengeStatus) {
-	continueCode, err := GenerateContinueCode(challengeProgress)
-	if err != nil {
-		log.Error("failed to encode challenge progress into continue code", "error", err)
-		return
+	verified := map[string]bool{}
+	for _, status := range lastChallengeProgress {
+		verified[status.Key] = true
 	}
-
-	url := fmt.Sprintf("http://juiceshop-%s:3000/rest/continue-code/apply/%s", team, continueCode)
-
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer([]byte{}))
-	if err != nil {
-		log.Error("failed to create http request to set the current ContinueCode", "error", err)
-		return
-	}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Error("failed to set the current ContinueCode to juice shop", "error", err)
-		return
-	}
-	defer res.Body.Close()
-}
-
-// ParseContinueCode returns the number of challenges solved by this ContinueCode
-func GenerateContinueCode(challenges []ChallengeStatus) (string, error) {
-	hd := hashids.NewData()
-	hd.Salt = "this is my salt"
-	hd.MinLength = 60
-	hd.Alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
-
-	hashIDClient, _ := hashids.NewWithData(hd)
-
-	challengeIds := []int{}
-
-	for _, challenge := range challenges {
-		challengeIds = append(challengeIds, challengeIdLookup[challenge.Key])
-	}
-
-	continueCode, err := hashIDClient.Encode(challengeIds)
-
-	if err != nil {
-		return "", err
-	}
-
-	return continueCode, nil
+	verifiesCtfFlags := b.VerifiesCtfFlags()
+	challengeStatus = slices.DeleteFunc(challengeStatus, func(status ChallengeStatus) bool {
+		return revokedChallenges[status.Key] || (verifiesCtfFlags && !verified[status.Key])
+	})
+	sort.Stable(ChallengeStatuses(challengeStatus))
+	return challengeStatus, nil
 }
@@ -0,0 +1,59 @@
+package progresswatchdog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCurrentChallengeProgress(t *testing.T) {
+	juiceShop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[
+			{"id":1,"key":"scoreBoardChallenge","solved":true,"updatedAt":"2026-06-11T10:00:00Z"},
+			{"id":2,"key":"nullByteChallenge","solved":true,"updatedAt":"2026-06-11T11:00:00Z"},
+			{"id":3,"key":"xssChallenge","solved":false,"updatedAt":"2026-06-11T09:00:00Z"}
+		]}`))
+	}))
+	defer juiceShop.Close()
+
+	newBundle := func(ctfKey string) *bundle.Bundle {
+		b := testutil.NewTestBundle()
+		b.Config.JuiceShopConfig.CtfKey = ctfKey
+		b.GetJuiceShopUrlForTeam = func(team string, bundle *bundle.Bundle) string {
+			return juiceShop.URL
+		}
+		return b
+	}
+	recorded := []ChallengeStatus{{Key: "scoreBoardChallenge", SolvedAt: "2026-06-11T10:00:00Z"}}
+
+	t.Run("only keeps recorded solves while ctf flags are verified", func(t *testing.T) {
+		progress, err := getCurrentChallengeProgress(context.Background(), newBundle("test-ctf-key"), "foobar", nil, recorded)
+
+		require.NoError(t, err)
+		assert.Equal(t, recorded, progress)
+	})
+
+	t.Run("keeps all solves of the instance without ctf key", func(t *testing.T) {
+		progress, err := getCurrentChallengeProgress(context.Background(), newBundle(""), "foobar", nil, recorded)
+
+		require.NoError(t, err)
+		assert.Equal(t, []ChallengeStatus{
+			{Key: "scoreBoardChallenge", SolvedAt: "2026-06-11T10:00:00Z"},
+			{Key: "nullByteChallenge", SolvedAt: "2026-06-11T11:00:00Z"},
+		}, progress)
+	})
+
+	t.Run("leaves out revoked challenges", func(t *testing.T) {
+		progress, err := getCurrentChallengeProgress(context.Background(), newBundle(""), "foobar", map[string]bool{"nullByteChallenge": true}, recorded)
+
+		require.NoError(t, err)
+		assert.Equal(t, recorded, progress)
+	})
+}
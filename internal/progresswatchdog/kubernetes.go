@@ -4,11 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type CheatScoreEntry struct {
@@ -16,10 +21,7 @@ type CheatScoreEntry struct {
 	Timestamp       string  `json:"timestamp"`
 }
 
-type ChallengeStatus struct {
-	Key      string `json:"key"`
-	SolvedAt string `json:"solvedAt"`
-}
+type ChallengeStatus = bundle.ChallengeStatus
 
 type ChallengeStatuses []ChallengeStatus
 
@@ -27,57 +29,133 @@ func (a ChallengeStatuses) Len() int           { return len(a) }
 func (a ChallengeStatuses) Less(i, j int) bool { return strings.Compare(a[i].Key, a[j].Key) > 0 }
 func (a ChallengeStatuses) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
-type UpdateProgressDeploymentDiff struct {
-	Metadata UpdateProgressDeploymentMetadata `json:"metadata"`
-}
-
-// UpdateProgressDeploymentMetadata a shim of the k8s metadata object containing only annotations
-type UpdateProgressDeploymentMetadata struct {
-	Annotations UpdateProgressDeploymentDiffAnnotations `json:"annotations"`
-}
+const persistProgressMaxRetries = 5
 
-// UpdateProgressDeploymentDiffAnnotations the app specific annotations relevant to the `progress-watchdog`
-type UpdateProgressDeploymentDiffAnnotations struct {
-	Challenges       string `json:"multi-juicer.owasp-juice.shop/challenges"`
-	ChallengesSolved string `json:"multi-juicer.owasp-juice.shop/challengesSolved"`
-	CheatScores      string `json:"multi-juicer.owasp-juice.shop/cheatScores,omitempty"`
-}
-
-func PersistProgress(ctx context.Context, b *bundle.Bundle, team string, solvedChallenges []ChallengeStatus, cheatScores []CheatScoreEntry) {
+// PersistProgress adds the solved challenges to the progress persisted on the deployment of the team. Challenges which are already persisted or which an admin revoked are left out.
+// The cheat scores and the evidence of the added solves are appended to the ones already persisted. Returns the challenges which got added, nothing is written if there are none.
+// Reads and updates the deployment with optimistic concurrency (retrying on conflicts), so that solves revoked or granted by an admin in between aren't overwritten.
+// Failures are logged, the returned error is for callers which have to report them.
+func PersistProgress(ctx context.Context, b *bundle.Bundle, team string, solvedChallenges []ChallengeStatus, cheatScores []CheatScoreEntry, solves []bundle.SolveEvidence) ([]ChallengeStatus, error) {
 	b.Log.Debug("Updating saved ContinueCode", "team", team)
 
-	encodedSolvedChallenges, err := json.Marshal(solvedChallenges)
-	if err != nil {
-		panic("Could not encode json, to update ContinueCode and challengeSolved count on deployment")
-	}
+	for attempt := range persistProgressMaxRetries {
+		deployment, err := b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Get(ctx, b.JuiceShopDeploymentName(team), metav1.GetOptions{})
+		if err != nil {
+			b.Log.Error("failed to get deployment to persist progress", "team", team, "error", err)
+			return nil, fmt.Errorf("failed to get deployment: %w", err)
+		}
+		persistedChallenges, err := ParseChallengeStatuses(deployment)
+		if err != nil {
+			b.Log.Error("failed to decode persisted progress", "team", team, "error", err)
+			return nil, err
+		}
+		revokedChallenges, err := ParseRevokedChallenges(deployment)
+		if err != nil {
+			b.Log.Warn("failed to decode revoked challenges, assuming none", "team", team, "error", err)
+		}
 
-	annotations := UpdateProgressDeploymentDiffAnnotations{
-		Challenges:       string(encodedSolvedChallenges),
-		ChallengesSolved: fmt.Sprintf("%d", len(solvedChallenges)),
-	}
+		persisted := map[string]bool{}
+		for _, challenge := range persistedChallenges {
+			persisted[challenge.Key] = true
+		}
+		added := []ChallengeStatus{}
+		for _, challenge := range solvedChallenges {
+			if !persisted[challenge.Key] && !revokedChallenges[challenge.Key] {
+				persisted[challenge.Key] = true
+				added = append(added, challenge)
+			}
+		}
+		if len(added) == 0 {
+			return added, nil
+		}
 
-	if len(cheatScores) > 0 {
-		encodedCheatScores, err := json.Marshal(cheatScores)
+		challenges := append(persistedChallenges, added...)
+		sort.Stable(challenges)
+		encodedChallenges, err := json.Marshal(challenges)
 		if err != nil {
-			b.Log.Error("failed to encode cheat scores", "team", team, "error", err)
-		} else {
-			annotations.CheatScores = string(encodedCheatScores)
+			return nil, fmt.Errorf("failed to encode challenges annotation: %w", err)
 		}
-	}
+		updatedAnnotations := make(map[string]string, len(deployment.Annotations)+4)
+		maps.Copy(updatedAnnotations, deployment.Annotations)
+		updatedAnnotations["multi-juicer.owasp-juice.shop/challenges"] = string(encodedChallenges)
+		updatedAnnotations["multi-juicer.owasp-juice.shop/challengesSolved"] = strconv.Itoa(len(challenges))
+
+		if len(cheatScores) > 0 {
+			persistedCheatScores := []CheatScoreEntry{}
+			if value, ok := deployment.Annotations["multi-juicer.owasp-juice.shop/cheatScores"]; ok {
+				if err := json.Unmarshal([]byte(value), &persistedCheatScores); err != nil {
+					b.Log.Error("failed to decode cheat scores from juice shop deployment annotation", "team", team, "error", err)
+					persistedCheatScores = []CheatScoreEntry{}
+				}
+			}
+			encodedCheatScores, err := json.Marshal(append(persistedCheatScores, cheatScores...))
+			if err != nil {
+				b.Log.Error("failed to encode cheat scores", "team", team, "error", err)
+			} else {
+				updatedAnnotations["multi-juicer.owasp-juice.shop/cheatScores"] = string(encodedCheatScores)
+			}
+		}
+
+		addedEvidence := slices.DeleteFunc(slices.Clone(solves), func(solve bundle.SolveEvidence) bool {
+			return !slices.ContainsFunc(added, func(challenge ChallengeStatus) bool { return challenge.Key == solve.Key })
+		})
+		if len(addedEvidence) > 0 {
+			persistedEvidence := []bundle.SolveEvidence{}
+			if value, ok := deployment.Annotations["multi-juicer.owasp-juice.shop/solves"]; ok {
+				if err := json.Unmarshal([]byte(value), &persistedEvidence); err != nil {
+					b.Log.Error("failed to decode solve evidence from juice shop deployment annotation", "team", team, "error", err)
+					persistedEvidence = []bundle.SolveEvidence{}
+				}
+			}
+			encodedEvidence, err := json.Marshal(append(persistedEvidence, addedEvidence...))
+			if err != nil {
+				b.Log.Error("failed to encode solve evidence", "team", team, "error", err)
+			} else {
+				updatedAnnotations["multi-juicer.owasp-juice.shop/solves"] = string(encodedEvidence)
+			}
+		}
+		deployment.Annotations = updatedAnnotations
 
-	diff := UpdateProgressDeploymentDiff{
-		Metadata: UpdateProgressDeploymentMetadata{
-			Annotations: annotations,
-		},
+		_, err = b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+		if err == nil {
+			return added, nil
+		}
+		if !errors.IsConflict(err) {
+			b.Log.Error("failed to update progress of deployment", "team", team, "error", err)
+			return nil, fmt.Errorf("failed to update progress of deployment: %w", err)
+		}
+		b.Log.Debug("Progress update conflict, retrying", "team", team, "attempt", attempt+1, "maxRetries", persistProgressMaxRetries)
 	}
+	b.Log.Error("failed to update progress of deployment due to conflicts", "team", team)
+	return nil, fmt.Errorf("failed to update progress of deployment after %d retries due to conflicts", persistProgressMaxRetries)
+}
 
-	jsonBytes, err := json.Marshal(diff)
-	if err != nil {
-		panic("Could not encode json, to update ContinueCode and challengeSolved count on deployment")
+// ParseChallengeStatuses decodes the solved challenges persisted in the challenges annotation of the deployment of a team
+func ParseChallengeStatuses(deployment *appsv1.Deployment) (ChallengeStatuses, error) {
+	challengesAnnotation := deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"]
+	if challengesAnnotation == "" {
+		challengesAnnotation = "[]"
+	}
+	challenges := ChallengeStatuses{}
+	if err := json.Unmarshal([]byte(challengesAnnotation), &challenges); err != nil {
+		return nil, fmt.Errorf("failed to decode challenges annotation: %w", err)
 	}
+	return challenges, nil
+}
 
-	_, err = b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Patch(ctx, fmt.Sprintf("juiceshop-%s", team), types.MergePatchType, jsonBytes, v1.PatchOptions{})
-	if err != nil {
-		b.Log.Error("failed to patch new ContinueCode into deployment", "team", team, "error", err)
+// ParseRevokedChallenges returns the keys of the challenges an admin revoked from the team
+func ParseRevokedChallenges(deployment *appsv1.Deployment) (map[string]bool, error) {
+	revokedChallenges := map[string]bool{}
+	revokedChallengesAnnotation := deployment.Annotations["multi-juicer.owasp-juice.shop/revokedChallenges"]
+	if revokedChallengesAnnotation == "" {
+		return revokedChallenges, nil
+	}
+	var keys []string
+	if err := json.Unmarshal([]byte(revokedChallengesAnnotation), &keys); err != nil {
+		return revokedChallenges, fmt.Errorf("failed to decode revokedChallenges annotation: %w", err)
+	}
+	for _, key := range keys {
+		revokedChallenges[key] = true
 	}
+	return revokedChallenges, nil
 }
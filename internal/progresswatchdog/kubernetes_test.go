@@ -0,0 +1,85 @@
+package progresswatchdog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPersistProgress(t *testing.T) {
+	createTeam := func(annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "juiceshop-foobar",
+				Namespace:   "test-namespace",
+				Annotations: annotations,
+				Labels:      map[string]string{"team": "foobar"},
+			},
+		}
+	}
+	getAnnotations := func(t *testing.T, clientset *fake.Clientset) map[string]string {
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		require.NoError(t, err)
+		return deployment.Annotations
+	}
+
+	t.Run("adds new solves with their cheat scores and evidence to the persisted ones", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges":  `[{"key":"scoreBoardChallenge","solvedAt":"2026-06-11T10:00:00Z"}]`,
+			"multi-juicer.owasp-juice.shop/cheatScores": `[{"totalCheatScore":0.1,"timestamp":"2026-06-11T10:00:00Z"}]`,
+		}))
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		added, err := PersistProgress(context.Background(), b, "foobar",
+			[]ChallengeStatus{{Key: "scoreBoardChallenge", SolvedAt: "2026-06-11T12:00:00Z"}, {Key: "nullByteChallenge", SolvedAt: "2026-06-11T11:00:00Z"}},
+			[]CheatScoreEntry{{TotalCheatScore: 0.2, Timestamp: "2026-06-11T11:00:00Z"}},
+			[]bundle.SolveEvidence{{Key: "nullByteChallenge", SolvedAt: "2026-06-11T11:00:00Z", Evidence: "%00"}},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, []ChallengeStatus{{Key: "nullByteChallenge", SolvedAt: "2026-06-11T11:00:00Z"}}, added)
+		annotations := getAnnotations(t, clientset)
+		assert.Equal(t, `[{"key":"scoreBoardChallenge","solvedAt":"2026-06-11T10:00:00Z"},{"key":"nullByteChallenge","solvedAt":"2026-06-11T11:00:00Z"}]`, annotations["multi-juicer.owasp-juice.shop/challenges"])
+		assert.Equal(t, "2", annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+		assert.Equal(t, `[{"totalCheatScore":0.1,"timestamp":"2026-06-11T10:00:00Z"},{"totalCheatScore":0.2,"timestamp":"2026-06-11T11:00:00Z"}]`, annotations["multi-juicer.owasp-juice.shop/cheatScores"])
+		assert.Equal(t, `[{"key":"nullByteChallenge","solvedAt":"2026-06-11T11:00:00Z","evidence":"%00"}]`, annotations["multi-juicer.owasp-juice.shop/solves"])
+	})
+
+	t.Run("doesn't bring back a solve an admin revoked while the progress was persisted", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges": `[]`,
+		}))
+		// the admin revokes the solve between the read and the update, the update conflicts with it
+		revoked := false
+		clientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if revoked {
+				return false, nil, nil
+			}
+			revoked = true
+			deployment := createTeam(map[string]string{
+				"multi-juicer.owasp-juice.shop/challenges":        `[]`,
+				"multi-juicer.owasp-juice.shop/revokedChallenges": `["nullByteChallenge"]`,
+			})
+			require.NoError(t, clientset.Tracker().Update(appsv1.SchemeGroupVersion.WithResource("deployments"), deployment, "test-namespace"))
+			return true, nil, errors.NewConflict(appsv1.Resource("deployments"), "juiceshop-foobar", fmt.Errorf("the object has been modified"))
+		})
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		added, err := PersistProgress(context.Background(), b, "foobar", []ChallengeStatus{{Key: "nullByteChallenge", SolvedAt: "2026-06-11T11:00:00Z"}}, nil, nil)
+
+		require.NoError(t, err)
+		assert.Empty(t, added)
+		assert.Equal(t, `[]`, getAnnotations(t, clientset)["multi-juicer.owasp-juice.shop/challenges"])
+	})
+}
@@ -11,5 +11,6 @@ import (
 
 func AddRoutes(ctx context.Context, mux *http.ServeMux, b *bundle.Bundle) {
 	mux.Handle("POST /team/{team}/webhook", metrics.TrackRequestMetrics(metrics.RequestTypeAPIInternal, middleware.RequireJSONContentType(NewSolutionsWebhookHandler(b))))
+	mux.Handle("POST /instance/{deployment}/webhook", metrics.TrackRequestMetrics(metrics.RequestTypeAPIInternal, middleware.RequireJSONContentType(NewInstanceSolutionsWebhookHandler(b))))
 	mux.Handle("/", metrics.TrackRequestMetrics(metrics.RequestTypeAPIInternal, newLLMGatewayHandler(ctx, b)))
 }
@@ -1,17 +1,41 @@
 package private
 
 import (
+	"context"
+	"crypto/hmac"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"sort"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/progresswatchdog"
+	"github.com/juice-shop/multi-juicer/internal/signutil"
+	"github.com/prometheus/client_golang/prometheus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+var rejectedSolvesCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "multijuicer_rejected_solves",
+		Help: `Number of rejected solutions webhooks (see label "reason").`,
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(rejectedSolvesCounter)
+}
+
+const (
+	// maxEvidenceLength is the number of characters of the evidence of a solve that are persisted
+	maxEvidenceLength = 500
+	// maxIssuerFieldLength is the number of characters of the version and host name of the instance that are persisted
+	maxIssuerFieldLength = 100
+)
+
 type juiceShopWebhookSolution struct {
 	Challenge       string   `json:"challenge"`
 	Evidence        *string  `json:"evidence"`
@@ -35,91 +59,243 @@ type juiceShopWebhook struct {
 }
 
 // NewSolutionsWebhookHandler returns the handler that JuiceShop instances call when a challenge is solved.
-// It's safe to register this on every replica: PersistProgress patches deployment annotations idempotently
-// and an early "challenge already solved?" check makes duplicate webhooks no-ops.
+// It's safe to register this on every replica: PersistProgress only adds challenges which aren't solved or revoked yet
+// and updates the deployment with optimistic concurrency, so duplicate webhooks are no-ops and concurrent admin edits aren't overwritten.
+// The token query parameter has to be the signed webhook identity of the team in the path, see isAuthenticatedWebhook.
 func NewSolutionsWebhookHandler(b *bundle.Bundle) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
 		team := r.PathValue("team")
-
-		var webhook juiceShopWebhook
-		if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
+		if !isAuthenticatedWebhook(b, r, team) {
+			b.Log.Warn("Rejected solutions webhook with invalid token", "team", team)
+			rejectedSolvesCounter.WithLabelValues("invalid_token").Inc()
+			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
+		handleSolutionsWebhook(b, w, r, team)
+	}
+}
 
-		// Once the event countdown has elapsed and scoreboard freezing is enabled,
-		// new challenge solves reported by JuiceShops are ignored so the final
-		// scores stay locked in. We still ack with 200 so JuiceShop doesn't retry.
-		if b.NotificationService.IsScoreboardFrozen() {
-			b.Log.Info("Scoreboard frozen, ignoring solve webhook", "team", team, "challenge", webhook.Solution.Challenge)
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("ok"))
+// NewInstanceSolutionsWebhookHandler returns the handler for the JuiceShop instances of the warm pool. They are started before a team claims them,
+// so their webhook url contains the name of their deployment instead of the team. The team gets looked up on every request.
+func NewInstanceSolutionsWebhookHandler(b *bundle.Bundle) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deploymentName := r.PathValue("deployment")
+		if !isAuthenticatedWebhook(b, r, bundle.InstanceIdentityPrefix+deploymentName) {
+			b.Log.Warn("Rejected solutions webhook with invalid token", "deployment", deploymentName)
+			rejectedSolvesCounter.WithLabelValues("invalid_token").Inc()
+			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
-
-		deployment, err := b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Get(ctx, fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
-		if err != nil {
-			b.Log.Error("failed to get deployment for team received via webhook", "team", team, "error", err)
-			http.Error(w, "deployment lookup failed", http.StatusInternalServerError)
+		team, ok := b.GetTeamOfJuiceShopDeployment(deploymentName)
+		if !ok {
+			http.Error(w, "instance isn't claimed by a team", http.StatusNotFound)
 			return
 		}
+		handleSolutionsWebhook(b, w, r, team)
+	}
+}
 
-		challengeStatusJson := "[]"
-		if value, ok := deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"]; ok {
-			challengeStatusJson = value
+// isAuthenticatedWebhook checks that the token query parameter of the webhook is the signed webhook identity of the JuiceShop the path points to.
+// The private port is reachable from every JuiceShop, without the token a team could credit solves to other teams by changing the path.
+// Team cookies aren't accepted as token, they lack the bundle.WebhookIdentityPrefix.
+func isAuthenticatedWebhook(b *bundle.Bundle, r *http.Request, identity string) bool {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return false
+	}
+	signedIdentity, err := signutil.Unsign(token, b.Config.CookieConfig.SigningKey)
+	return err == nil && signedIdentity == bundle.WebhookIdentityPrefix+identity
+}
+
+// sanitizeSolveEvidence removes invalid utf-8 and control characters except for line breaks and tabs and cuts the value off after maxLength characters.
+// The solves of a team share the annotation size limit of the deployment, every challenge is only persisted once.
+func sanitizeSolveEvidence(value string, maxLength int) string {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
 		}
+		return r
+	}, strings.ToValidUTF8(value, ""))
+
+	runes := []rune(strings.TrimSpace(value))
+	if len(runes) > maxLength {
+		return string(runes[:maxLength]) + "…"
+	}
+	return string(runes)
+}
 
-		challengeStatus := make(progresswatchdog.ChallengeStatuses, 0)
-		if err := json.Unmarshal([]byte(challengeStatusJson), &challengeStatus); err != nil {
-			b.Log.Error("failed to decode json from juice shop deployment annotation", "error", err)
+// hasValidCtfFlag recomputes the flag of the solved challenge, so that forged webhooks without knowledge of the CTF key are rejected.
+// Challenges unknown to MultiJuicer, e.g. of a team running another JuiceShop version, can't be verified and are rejected while flags are verified.
+func hasValidCtfFlag(b *bundle.Bundle, webhook juiceShopWebhook) bool {
+	for _, challenge := range b.JuiceShopChallenges {
+		if challenge.Key != webhook.Solution.Challenge {
+			continue
 		}
+		expected := b.Application.CtfFlag(challenge)
+		return expected == "" || hmac.Equal([]byte(expected), []byte(webhook.CtfFlag))
+	}
+	return !b.VerifiesCtfFlags()
+}
+
+func handleSolutionsWebhook(b *bundle.Bundle, w http.ResponseWriter, r *http.Request, team string) {
+	ctx := r.Context()
+
+	var webhook juiceShopWebhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
 
-		cheatScoresJson := "[]"
-		if value, ok := deployment.Annotations["multi-juicer.owasp-juice.shop/cheatScores"]; ok {
-			cheatScoresJson = value
+	if !hasValidCtfFlag(b, webhook) {
+		b.Log.Warn("Rejected solutions webhook with invalid CTF flag", "team", team, "challenge", webhook.Solution.Challenge)
+		rejectedSolvesCounter.WithLabelValues("invalid_flag").Inc()
+		if err := b.EventLog.Append(ctx, bundle.Event{
+			Type:         bundle.EventTypeSolveRejected,
+			Team:         team,
+			ChallengeKey: webhook.Solution.Challenge,
+			Details:      "invalid ctf flag",
+		}); err != nil {
+			b.Log.Error("Failed to record rejected solve in event log", "team", team, "challenge", webhook.Solution.Challenge, "error", err)
 		}
+		http.Error(w, "invalid ctf flag", http.StatusBadRequest)
+		return
+	}
+
+	// Once the event countdown has elapsed and scoreboard freezing is enabled,
+	// new challenge solves reported by JuiceShops are ignored so the final
+	// scores stay locked in. We still ack with 200 so JuiceShop doesn't retry.
+	if b.NotificationService.IsScoreboardFrozen() {
+		b.Log.Info("Scoreboard frozen, ignoring solve webhook", "team", team, "challenge", webhook.Solution.Challenge)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+		return
+	}
+
+	deployment, err := b.ClientSet.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Get(ctx, b.JuiceShopDeploymentName(team), metav1.GetOptions{})
+	if err != nil {
+		b.Log.Error("failed to get deployment for team received via webhook", "team", team, "error", err)
+		http.Error(w, "deployment lookup failed", http.StatusInternalServerError)
+		return
+	}
 
-		cheatScores := make([]progresswatchdog.CheatScoreEntry, 0)
-		if err := json.Unmarshal([]byte(cheatScoresJson), &cheatScores); err != nil {
+	challengeStatusJson := "[]"
+	if value, ok := deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"]; ok {
+		challengeStatusJson = value
+	}
+
+	challengeStatus := make(progresswatchdog.ChallengeStatuses, 0)
+	if err := json.Unmarshal([]byte(challengeStatusJson), &challengeStatus); err != nil {
+		b.Log.Error("failed to decode json from juice shop deployment annotation", "error", err)
+	}
+
+	previousCheatScore := 0.0
+	persistedCheatScores := make([]progresswatchdog.CheatScoreEntry, 0)
+	if value, ok := deployment.Annotations["multi-juicer.owasp-juice.shop/cheatScores"]; ok {
+		if err := json.Unmarshal([]byte(value), &persistedCheatScores); err != nil {
 			b.Log.Error("failed to decode cheat scores from juice shop deployment annotation", "error", err)
-			cheatScores = make([]progresswatchdog.CheatScoreEntry, 0)
+		} else if len(persistedCheatScores) > 0 {
+			previousCheatScore = persistedCheatScores[len(persistedCheatScores)-1].TotalCheatScore
 		}
+	}
 
-		for _, status := range challengeStatus {
-			if status.Key == webhook.Solution.Challenge {
-				b.Log.Info("Challenge already solved, ignoring webhook", "challenge", webhook.Solution.Challenge, "team", team)
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("ok"))
-				return
-			}
-		}
+	revokedChallenges, err := progresswatchdog.ParseRevokedChallenges(deployment)
+	if err != nil {
+		b.Log.Error("failed to decode revoked challenges from juice shop deployment annotation", "error", err)
+	}
+	if revokedChallenges[webhook.Solution.Challenge] {
+		b.Log.Info("Challenge solve was revoked by an admin, ignoring webhook", "challenge", webhook.Solution.Challenge, "team", team)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+		return
+	}
 
-		solvedAtTime, err := time.Parse(time.RFC3339, webhook.Solution.IssuedOn)
-		if err != nil {
-			b.Log.Warn("Failed to parse timestamp, using current time in UTC", "timestamp", webhook.Solution.IssuedOn, "error", err)
-			solvedAtTime = time.Now().UTC()
+	for _, status := range challengeStatus {
+		if status.Key == webhook.Solution.Challenge {
+			b.Log.Info("Challenge already solved, ignoring webhook", "challenge", webhook.Solution.Challenge, "team", team)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("ok"))
+			return
 		}
-		solvedAtUTC := solvedAtTime.UTC().Format(time.RFC3339)
+	}
 
-		challengeStatus = append(challengeStatus, progresswatchdog.ChallengeStatus{
-			Key:      webhook.Solution.Challenge,
-			SolvedAt: solvedAtUTC,
-		})
-		sort.Stable(challengeStatus)
+	solvedAtTime, err := time.Parse(time.RFC3339, webhook.Solution.IssuedOn)
+	if err != nil {
+		b.Log.Warn("Failed to parse timestamp, using current time in UTC", "timestamp", webhook.Solution.IssuedOn, "error", err)
+		solvedAtTime = time.Now().UTC()
+	}
+	solvedAtUTC := solvedAtTime.UTC().Format(time.RFC3339)
 
-		if webhook.Solution.TotalCheatScore != nil {
-			cheatScores = append(cheatScores, progresswatchdog.CheatScoreEntry{
-				TotalCheatScore: *webhook.Solution.TotalCheatScore,
-				Timestamp:       solvedAtUTC,
-			})
-		}
+	solved := []progresswatchdog.ChallengeStatus{{
+		Key:      webhook.Solution.Challenge,
+		SolvedAt: solvedAtUTC,
+	}}
 
-		progresswatchdog.PersistProgress(ctx, b, team, challengeStatus, cheatScores)
+	cheatScores := []progresswatchdog.CheatScoreEntry{}
+	if webhook.Solution.TotalCheatScore != nil {
+		cheatScores = append(cheatScores, progresswatchdog.CheatScoreEntry{
+			TotalCheatScore: *webhook.Solution.TotalCheatScore,
+			Timestamp:       solvedAtUTC,
+		})
+	}
 
-		b.Log.Info("Received webhook", "team", team, "challenge", webhook.Solution.Challenge)
+	evidence := ""
+	if webhook.Solution.Evidence != nil {
+		evidence = sanitizeSolveEvidence(*webhook.Solution.Evidence, maxEvidenceLength)
+	}
+	solves := []bundle.SolveEvidence{{
+		Key:            webhook.Solution.Challenge,
+		SolvedAt:       solvedAtUTC,
+		Evidence:       evidence,
+		IssuerVersion:  sanitizeSolveEvidence(webhook.Issuer.Version, maxIssuerFieldLength),
+		IssuerHostName: sanitizeSolveEvidence(webhook.Issuer.HostName, maxIssuerFieldLength),
+	}}
 
+	added, err := progresswatchdog.PersistProgress(ctx, b, team, solved, cheatScores, solves)
+	if err != nil {
+		http.Error(w, "failed to persist progress", http.StatusInternalServerError)
+		return
+	}
+	if len(added) == 0 {
+		// solved by a concurrent webhook or revoked by an admin since the deployment was read above
+		b.Log.Info("Challenge already solved or revoked, ignoring webhook", "challenge", webhook.Solution.Challenge, "team", team)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
+		return
+	}
+
+	b.Log.Info("Received webhook", "team", team, "challenge", webhook.Solution.Challenge)
+
+	if err := b.EventLog.Append(ctx, bundle.Event{
+		Type:         bundle.EventTypeChallengeSolved,
+		Team:         team,
+		Timestamp:    solvedAtTime.UTC(),
+		ChallengeKey: webhook.Solution.Challenge,
+	}); err != nil {
+		b.Log.Error("Failed to record challenge solve in event log", "team", team, "challenge", webhook.Solution.Challenge, "error", err)
+	}
+	if webhook.Solution.TotalCheatScore != nil {
+		recordCheatDecision(ctx, b, team, previousCheatScore, *webhook.Solution.TotalCheatScore)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// recordCheatDecision records it in the event log when the new cheat score of the team got it flagged as suspicious or disqualified.
+// The scoring service applies the decision on its own, as it's derived from the cheat scores persisted on the deployment.
+func recordCheatDecision(ctx context.Context, b *bundle.Bundle, team string, previousCheatScore float64, cheatScore float64) {
+	thresholds := b.Config.ScoringConfig.CheatScore
+	decision := thresholds.Decide(cheatScore)
+	if decision == "" || decision == thresholds.Decide(previousCheatScore) {
+		return
+	}
+
+	eventType := bundle.EventTypeTeamSuspicious
+	if decision == bundle.CheatDecisionDisqualified {
+		eventType = bundle.EventTypeTeamDisqualified
+	}
+	b.Log.Warn("Team got flagged by its cheat score", "team", team, "decision", decision, "cheatScore", cheatScore)
+	if err := b.EventLog.Append(ctx, bundle.Event{Type: eventType, Team: team, Details: fmt.Sprintf("cheat score %.2f", cheatScore)}); err != nil {
+		b.Log.Error("Failed to record cheat decision in event log", "team", team, "decision", decision, "error", err)
 	}
 }
@@ -3,9 +3,12 @@ package private
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -50,6 +53,11 @@ func newJuiceShopDeployment(team, challengesAnnotation string) *appsv1.Deploymen
 	}
 }
 
+// webhookUrl returns the path of a webhook authenticated by the signed webhook identity, like the SOLUTIONS_WEBHOOK the JuiceShops are started with
+func webhookUrl(path string, identity string) string {
+	return path + "?token=" + url.QueryEscape(testutil.SignTestTeamname(bundle.WebhookIdentityPrefix+identity))
+}
+
 func webhookBody(challenge string) []byte {
 	return fmt.Appendf(nil, `{"solution":{"challenge":%q,"issuedOn":"2026-06-11T10:00:00Z"}}`, challenge)
 }
@@ -62,7 +70,7 @@ func TestSolutionsWebhookHandlerFreezing(t *testing.T) {
 		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		b.NotificationService = &stubNotificationService{frozen: true}
 
-		req, _ := http.NewRequest("POST", fmt.Sprintf("/team/%s/webhook", team), bytes.NewBuffer(webhookBody("newChallenge")))
+		req, _ := http.NewRequest("POST", webhookUrl(fmt.Sprintf("/team/%s/webhook", team), team), bytes.NewBuffer(webhookBody("newChallenge")))
 		req.SetPathValue("team", team)
 		rr := httptest.NewRecorder()
 
@@ -81,7 +89,7 @@ func TestSolutionsWebhookHandlerFreezing(t *testing.T) {
 		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		b.NotificationService = &stubNotificationService{frozen: false}
 
-		req, _ := http.NewRequest("POST", fmt.Sprintf("/team/%s/webhook", team), bytes.NewBuffer(webhookBody("newChallenge")))
+		req, _ := http.NewRequest("POST", webhookUrl(fmt.Sprintf("/team/%s/webhook", team), team), bytes.NewBuffer(webhookBody("newChallenge")))
 		req.SetPathValue("team", team)
 		rr := httptest.NewRecorder()
 
@@ -94,3 +102,267 @@ func TestSolutionsWebhookHandlerFreezing(t *testing.T) {
 		assert.Contains(t, deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"], "newChallenge")
 	})
 }
+
+func TestInstanceSolutionsWebhookHandler(t *testing.T) {
+	claimedDeployment := func() *appsv1.Deployment {
+		deployment := newJuiceShopDeployment("foobar", `[]`)
+		deployment.Name = "juiceshop-pool-abc"
+		deployment.Labels = map[string]string{
+			"app.kubernetes.io/name":    "juice-shop",
+			"app.kubernetes.io/part-of": "multi-juicer",
+			"team":                      "foobar",
+		}
+		return deployment
+	}
+
+	t.Run("records solves for the team that claimed the warm pool instance", func(t *testing.T) {
+		clientset := fake.NewClientset(claimedDeployment())
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.NotificationService = &stubNotificationService{}
+
+		req, _ := http.NewRequest("POST", webhookUrl("/instance/juiceshop-pool-abc/webhook", bundle.InstanceIdentityPrefix+"juiceshop-pool-abc"), bytes.NewBuffer(webhookBody("newChallenge")))
+		req.SetPathValue("deployment", "juiceshop-pool-abc")
+		rr := httptest.NewRecorder()
+
+		NewInstanceSolutionsWebhookHandler(b).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		deployment, err := clientset.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Get(req.Context(), "juiceshop-pool-abc", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Contains(t, deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"], "newChallenge")
+		events := b.EventLog.GetEvents()
+		assert.Len(t, events, 1)
+		assert.Equal(t, "foobar", events[0].Team)
+	})
+
+	t.Run("rejects solves of unclaimed warm pool instances", func(t *testing.T) {
+		unclaimed := claimedDeployment()
+		delete(unclaimed.Labels, "team")
+		clientset := fake.NewClientset(unclaimed)
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.NotificationService = &stubNotificationService{}
+
+		req, _ := http.NewRequest("POST", webhookUrl("/instance/juiceshop-pool-abc/webhook", bundle.InstanceIdentityPrefix+"juiceshop-pool-abc"), bytes.NewBuffer(webhookBody("newChallenge")))
+		req.SetPathValue("deployment", "juiceshop-pool-abc")
+		rr := httptest.NewRecorder()
+
+		NewInstanceSolutionsWebhookHandler(b).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestSolutionsWebhookAuthentication(t *testing.T) {
+	for name, path := range map[string]string{
+		"without token":              "/team/foobar/webhook",
+		"with token of another team": webhookUrl("/team/foobar/webhook", "barfoo"),
+		"with forged token":          "/team/foobar/webhook?token=" + url.QueryEscape("foobar.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"),
+		"with token of an instance":  webhookUrl("/team/foobar/webhook", bundle.InstanceIdentityPrefix+"juiceshop-foobar"),
+		"with the team cookie":       "/team/foobar/webhook?token=" + url.QueryEscape(testutil.SignTestTeamname("foobar")),
+	} {
+		t.Run("rejects webhooks "+name, func(t *testing.T) {
+			clientset := fake.NewClientset(newJuiceShopDeployment("foobar", `[]`))
+			b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+			b.NotificationService = &stubNotificationService{}
+
+			req, _ := http.NewRequest("POST", path, bytes.NewBuffer(webhookBody("newChallenge")))
+			req.SetPathValue("team", "foobar")
+			rr := httptest.NewRecorder()
+
+			NewSolutionsWebhookHandler(b).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			deployment, err := clientset.AppsV1().Deployments(b.RuntimeEnvironment.Namespace).Get(req.Context(), "juiceshop-foobar", metav1.GetOptions{})
+			assert.Nil(t, err)
+			assert.Equal(t, "[]", deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"])
+			assert.Empty(t, b.EventLog.GetEvents())
+		})
+	}
+
+	t.Run("rejects webhooks of warm pool instances with the token of another instance", func(t *testing.T) {
+		clientset := fake.NewClientset(newJuiceShopDeployment("foobar", `[]`))
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.NotificationService = &stubNotificationService{}
+
+		req, _ := http.NewRequest("POST", webhookUrl("/instance/juiceshop-foobar/webhook", bundle.InstanceIdentityPrefix+"juiceshop-pool-abc"), bytes.NewBuffer(webhookBody("newChallenge")))
+		req.SetPathValue("deployment", "juiceshop-foobar")
+		rr := httptest.NewRecorder()
+
+		NewInstanceSolutionsWebhookHandler(b).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestSolutionsWebhookCtfFlagVerification(t *testing.T) {
+	const scoreBoardFlag = "41c543fd95e1d050a977a55ecd3dde5672685ff6"
+
+	postWebhook := func(t *testing.T, challenge string, ctfFlag string) (*httptest.ResponseRecorder, *bundle.Bundle, *fake.Clientset) {
+		clientset := fake.NewClientset(newJuiceShopDeployment("foobar", `[]`))
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.NotificationService = &stubNotificationService{}
+		b.Config.JuiceShopConfig.CtfKey = "test-ctf-key"
+
+		body := fmt.Appendf(nil, `{"solution":{"challenge":%q,"issuedOn":"2026-06-11T10:00:00Z"},"ctfFlag":%q}`, challenge, ctfFlag)
+		req, _ := http.NewRequest("POST", webhookUrl("/team/foobar/webhook", "foobar"), bytes.NewBuffer(body))
+		req.SetPathValue("team", "foobar")
+		rr := httptest.NewRecorder()
+
+		NewSolutionsWebhookHandler(b).ServeHTTP(rr, req)
+		return rr, b, clientset
+	}
+
+	getChallengesAnnotation := func(t *testing.T, clientset *fake.Clientset) string {
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.Nil(t, err)
+		return deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"]
+	}
+
+	t.Run("records solves with a valid flag", func(t *testing.T) {
+		rr, _, clientset := postWebhook(t, "scoreBoardChallenge", scoreBoardFlag)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, getChallengesAnnotation(t, clientset), "scoreBoardChallenge")
+	})
+
+	t.Run("rejects solves with an invalid flag and records them in the event log", func(t *testing.T) {
+		rr, b, clientset := postWebhook(t, "scoreBoardChallenge", "0000000000000000000000000000000000000000")
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "[]", getChallengesAnnotation(t, clientset))
+		events := b.EventLog.GetEvents()
+		assert.Len(t, events, 1)
+		assert.Equal(t, bundle.EventTypeSolveRejected, events[0].Type)
+		assert.Equal(t, "foobar", events[0].Team)
+		assert.Equal(t, "scoreBoardChallenge", events[0].ChallengeKey)
+	})
+
+	t.Run("rejects solves with the flag of another challenge", func(t *testing.T) {
+		rr, _, clientset := postWebhook(t, "nullByteChallenge", scoreBoardFlag)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "[]", getChallengesAnnotation(t, clientset))
+	})
+
+	t.Run("rejects solves of challenges unknown to multijuicer", func(t *testing.T) {
+		rr, _, clientset := postWebhook(t, "challengeOfAnotherVersion", "")
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "[]", getChallengesAnnotation(t, clientset))
+	})
+}
+
+func TestSolutionsWebhookEvidence(t *testing.T) {
+	postWebhook := func(t *testing.T, body string) *appsv1.Deployment {
+		clientset := fake.NewClientset(newJuiceShopDeployment("foobar", `[]`))
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.NotificationService = &stubNotificationService{}
+
+		req, _ := http.NewRequest("POST", webhookUrl("/team/foobar/webhook", "foobar"), bytes.NewBufferString(body))
+		req.SetPathValue("team", "foobar")
+		rr := httptest.NewRecorder()
+
+		NewSolutionsWebhookHandler(b).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.Nil(t, err)
+		return deployment
+	}
+
+	t.Run("persists the evidence and issuer of the solve", func(t *testing.T) {
+		deployment := postWebhook(t, `{"solution":{"challenge":"scoreBoardChallenge","evidence":"/#/score-board","issuedOn":"2026-06-11T10:00:00Z"},"issuer":{"hostName":"juiceshop-foobar-abc","version":"19.0.0"}}`)
+
+		assert.JSONEq(t, `[{"key":"scoreBoardChallenge","solvedAt":"2026-06-11T10:00:00Z","evidence":"/#/score-board","issuerVersion":"19.0.0","issuerHostName":"juiceshop-foobar-abc"}]`, deployment.Annotations["multi-juicer.owasp-juice.shop/solves"])
+	})
+
+	t.Run("removes control characters and limits the length of the evidence", func(t *testing.T) {
+		deployment := postWebhook(t, fmt.Sprintf(`{"solution":{"challenge":"scoreBoardChallenge","evidence":"line\u001b[31m\nnext%s","issuedOn":"2026-06-11T10:00:00Z"}}`, strings.Repeat("a", 1000)))
+
+		var solves []bundle.SolveEvidence
+		assert.Nil(t, json.Unmarshal([]byte(deployment.Annotations["multi-juicer.owasp-juice.shop/solves"]), &solves))
+		assert.Len(t, solves, 1)
+		assert.True(t, strings.HasPrefix(solves[0].Evidence, "line[31m\nnext"))
+		assert.Len(t, []rune(solves[0].Evidence), maxEvidenceLength+1)
+	})
+}
+
+func TestSolutionsWebhookCheatDecision(t *testing.T) {
+	postWebhook := func(t *testing.T, cheatScoresAnnotation string, totalCheatScore float64) *bundle.Bundle {
+		deployment := newJuiceShopDeployment("foobar", `[]`)
+		deployment.Annotations["multi-juicer.owasp-juice.shop/cheatScores"] = cheatScoresAnnotation
+		b := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset(deployment))
+		b.NotificationService = &stubNotificationService{}
+		b.Config.ScoringConfig.CheatScore = bundle.CheatScoreConfig{SuspiciousThreshold: 0.5, DisqualifyThreshold: 0.8}
+
+		body := fmt.Appendf(nil, `{"solution":{"challenge":"scoreBoardChallenge","issuedOn":"2026-06-11T10:00:00Z","totalCheatScore":%v}}`, totalCheatScore)
+		req, _ := http.NewRequest("POST", webhookUrl("/team/foobar/webhook", "foobar"), bytes.NewBuffer(body))
+		req.SetPathValue("team", "foobar")
+		rr := httptest.NewRecorder()
+
+		NewSolutionsWebhookHandler(b).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return b
+	}
+	eventsOfType := func(b *bundle.Bundle, eventType bundle.EventType) []bundle.Event {
+		events := []bundle.Event{}
+		for _, event := range b.EventLog.GetEvents() {
+			if event.Type == eventType {
+				events = append(events, event)
+			}
+		}
+		return events
+	}
+
+	t.Run("records teams crossing the disqualify threshold in the event log", func(t *testing.T) {
+		b := postWebhook(t, `[{"totalCheatScore":0.6,"timestamp":"2026-06-11T09:00:00Z"}]`, 0.85)
+
+		events := eventsOfType(b, bundle.EventTypeTeamDisqualified)
+		assert.Len(t, events, 1)
+		assert.Equal(t, "foobar", events[0].Team)
+		assert.Equal(t, "cheat score 0.85", events[0].Details)
+	})
+
+	t.Run("records teams becoming suspicious", func(t *testing.T) {
+		b := postWebhook(t, ``, 0.55)
+
+		assert.Len(t, eventsOfType(b, bundle.EventTypeTeamSuspicious), 1)
+	})
+
+	t.Run("doesn't record teams again which were already flagged", func(t *testing.T) {
+		b := postWebhook(t, `[{"totalCheatScore":0.6,"timestamp":"2026-06-11T09:00:00Z"}]`, 0.7)
+
+		assert.Empty(t, eventsOfType(b, bundle.EventTypeTeamSuspicious))
+	})
+
+	t.Run("doesn't record teams below the thresholds", func(t *testing.T) {
+		b := postWebhook(t, ``, 0.2)
+
+		assert.Empty(t, eventsOfType(b, bundle.EventTypeTeamSuspicious))
+		assert.Empty(t, eventsOfType(b, bundle.EventTypeTeamDisqualified))
+	})
+}
+
+func TestSolutionsWebhookRevokedChallenges(t *testing.T) {
+	t.Run("ignores solves of challenges an admin revoked from the team", func(t *testing.T) {
+		deployment := newJuiceShopDeployment("foobar", `[]`)
+		deployment.Annotations["multi-juicer.owasp-juice.shop/revokedChallenges"] = `["scoreBoardChallenge"]`
+		clientset := fake.NewClientset(deployment)
+		b := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		b.NotificationService = &stubNotificationService{}
+
+		req, _ := http.NewRequest("POST", webhookUrl("/team/foobar/webhook", "foobar"), bytes.NewBuffer(webhookBody("scoreBoardChallenge")))
+		req.SetPathValue("team", "foobar")
+		rr := httptest.NewRecorder()
+
+		NewSolutionsWebhookHandler(b).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, `[]`, deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"])
+		assert.Empty(t, b.EventLog.GetEvents())
+	})
+}
@@ -10,34 +10,26 @@ import (
 	b "github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/longpoll"
 	appsv1 "k8s.io/api/apps/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-)
-
-// EventType represents the type of activity event
-type EventType string
-
-const (
-	EventTypeTeamCreated     EventType = "team_created"
-	EventTypeChallengeSolved EventType = "challenge_solved"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // ActivityEvent is the interface that all activity events must implement
 type ActivityEvent interface {
-	GetEventType() EventType
+	GetEventType() b.EventType
 	GetTeam() string
 	GetTimestamp() time.Time
 }
 
 // BaseEvent contains common fields for all activity events
 type BaseEvent struct {
-	Team      string    `json:"team"`
-	EventType EventType `json:"eventType"`
-	Timestamp time.Time `json:"timestamp"`
+	Team      string      `json:"team"`
+	EventType b.EventType `json:"eventType"`
+	Timestamp time.Time   `json:"timestamp"`
 }
 
-func (e BaseEvent) GetEventType() EventType { return e.EventType }
-func (e BaseEvent) GetTeam() string         { return e.Team }
-func (e BaseEvent) GetTimestamp() time.Time { return e.Timestamp }
+func (e BaseEvent) GetEventType() b.EventType { return e.EventType }
+func (e BaseEvent) GetTeam() string           { return e.Team }
+func (e BaseEvent) GetTimestamp() time.Time   { return e.Timestamp }
 
 // TeamCreatedEvent represents a team joining the CTF
 type TeamCreatedEvent struct {
@@ -50,9 +42,17 @@ type ChallengeSolvedEvent struct {
 	ChallengeKey  string `json:"challengeKey"`
 	ChallengeName string `json:"challengeName"`
 	Points        int    `json:"points"`
+	Bonus         int    `json:"bonus,omitempty"`
 	IsFirstSolve  bool   `json:"isFirstSolve,omitempty"`
 }
 
+// ScoreAdjustedEvent represents an admin awarding points to or taking points from a team
+type ScoreAdjustedEvent struct {
+	BaseEvent
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+}
+
 // Type assertion helpers
 func IsTeamCreatedEvent(event ActivityEvent) (*TeamCreatedEvent, bool) {
 	e, ok := event.(*TeamCreatedEvent)
@@ -64,6 +64,11 @@ func IsChallengeSolvedEvent(event ActivityEvent) (*ChallengeSolvedEvent, bool) {
 	return e, ok
 }
 
+func IsScoreAdjustedEvent(event ActivityEvent) (*ScoreAdjustedEvent, bool) {
+	e, ok := event.(*ScoreAdjustedEvent)
+	return e, ok
+}
+
 // ByTimestamp sorts events by their timestamp, newest first.
 type ByTimestamp []ActivityEvent
 
@@ -85,7 +90,7 @@ func buildTeamCreationEvents(deployments []*appsv1.Deployment) []ActivityEvent {
 		events = append(events, &TeamCreatedEvent{
 			BaseEvent: BaseEvent{
 				Team:      teamName,
-				EventType: EventTypeTeamCreated,
+				EventType: b.EventTypeTeamCreated,
 				Timestamp: deployment.CreationTimestamp.Time,
 			},
 		})
@@ -93,7 +98,8 @@ func buildTeamCreationEvents(deployments []*appsv1.Deployment) []ActivityEvent {
 	return events
 }
 
-// buildActivityFeed constructs the activity feed from team scores and deployments
+// buildActivityFeed constructs the activity feed from the event log, team scores and deployments.
+// The event log keeps the history of teams whose instances have since been deleted. Live scores and deployments cover everything that happened before the event log existed.
 func buildActivityFeed(
 	bundle *b.Bundle,
 	allTeamScores map[string]*b.TeamScore,
@@ -102,43 +108,97 @@ func buildActivityFeed(
 
 	allEvents := make([]ActivityEvent, 0)
 	firstSolves := make(map[string]time.Time) // Map challengeKey -> first solve time
+	seenSolves := make(map[string]bool)       // Map team/challengeKey -> already added
 
 	challengeMap := make(map[string]b.JuiceShopChallenge)
 	for _, ch := range bundle.JuiceShopChallenges {
 		challengeMap[ch.Key] = ch
 	}
 
-	// 1. Collect all solve events from all teams
+	addSolve := func(teamName string, challengeKey string, solvedAt time.Time, bonus int) {
+		challengeDetails, ok := challengeMap[challengeKey]
+		if !ok {
+			return // Should not happen in a consistent system
+		}
+		if seenSolves[teamName+"/"+challengeKey] {
+			return
+		}
+		seenSolves[teamName+"/"+challengeKey] = true
+
+		allEvents = append(allEvents, &ChallengeSolvedEvent{
+			BaseEvent: BaseEvent{
+				Team:      teamName,
+				EventType: b.EventTypeChallengeSolved,
+				Timestamp: solvedAt,
+			},
+			ChallengeKey:  challengeKey,
+			ChallengeName: challengeDetails.Name,
+			Points:        bundle.ScoringService.GetChallengePoints(challengeKey),
+			Bonus:         bonus,
+		})
+
+		// Track the earliest solve time for each challenge
+		if firstTime, exists := firstSolves[challengeKey]; !exists || solvedAt.Before(firstTime) {
+			firstSolves[challengeKey] = solvedAt
+		}
+	}
+
+	// 1. Collect all solve events from all teams. Live scores come first as they know about early solve bonuses.
 	for teamName, teamScore := range allTeamScores {
 		for _, solvedChallenge := range teamScore.Challenges {
-			challengeDetails, ok := challengeMap[solvedChallenge.Key]
-			if !ok {
-				continue // Should not happen in a consistent system
-			}
+			addSolve(teamName, solvedChallenge.Key, solvedChallenge.SolvedAt, solvedChallenge.Bonus)
+		}
+	}
+	// Logged solves of teams which are still around are left out, their deployment knows better, e.g. when an admin revoked a solve
+	liveTeams := make(map[string]bool, len(deployments))
+	for _, deployment := range deployments {
+		if teamName, ok := deployment.Labels["team"]; ok {
+			liveTeams[teamName] = true
+		}
+	}
+	loggedEvents := bundle.EventLog.GetEvents()
+	for _, event := range loggedEvents {
+		if event.Type == b.EventTypeChallengeSolved && !liveTeams[event.Team] {
+			addSolve(event.Team, event.ChallengeKey, event.Timestamp, 0)
+		}
+	}
 
-			event := &ChallengeSolvedEvent{
+	// 2. Add team creation events
+	teamCreationEvents := buildTeamCreationEvents(deployments)
+	createdTeams := make(map[string]bool, len(teamCreationEvents))
+	for _, event := range teamCreationEvents {
+		createdTeams[event.GetTeam()] = true
+	}
+	for _, event := range loggedEvents {
+		if event.Type == b.EventTypeTeamCreated && !createdTeams[event.Team] {
+			createdTeams[event.Team] = true
+			teamCreationEvents = append(teamCreationEvents, &TeamCreatedEvent{
 				BaseEvent: BaseEvent{
-					Team:      teamName,
-					EventType: EventTypeChallengeSolved,
-					Timestamp: solvedChallenge.SolvedAt,
+					Team:      event.Team,
+					EventType: b.EventTypeTeamCreated,
+					Timestamp: event.Timestamp,
 				},
-				ChallengeKey:  solvedChallenge.Key,
-				ChallengeName: challengeDetails.Name,
-				Points:        challengeDetails.Difficulty * 10,
-			}
-			allEvents = append(allEvents, event)
-
-			// Track the earliest solve time for each challenge
-			if firstTime, exists := firstSolves[solvedChallenge.Key]; !exists || solvedChallenge.SolvedAt.Before(firstTime) {
-				firstSolves[solvedChallenge.Key] = solvedChallenge.SolvedAt
-			}
+			})
 		}
 	}
+	allEvents = append(allEvents, teamCreationEvents...)
 
-	// 2. Add team creation events
-	allEvents = append(allEvents, buildTeamCreationEvents(deployments)...)
+	// 3. Add score adjustments. Adjustments of deleted teams no longer count, so they are left out
+	for teamName, teamScore := range allTeamScores {
+		for _, adjustment := range teamScore.Adjustments {
+			allEvents = append(allEvents, &ScoreAdjustedEvent{
+				BaseEvent: BaseEvent{
+					Team:      teamName,
+					EventType: b.EventTypeScoreAdjusted,
+					Timestamp: adjustment.CreatedAt,
+				},
+				Points: adjustment.Points,
+				Reason: adjustment.Reason,
+			})
+		}
+	}
 
-	// 3. Add "First Solve" information
+	// 4. Add "First Solve" information
 	for i := range allEvents {
 		event := allEvents[i]
 		if solvedEvent, ok := IsChallengeSolvedEvent(event); ok {
@@ -148,58 +208,52 @@ func buildActivityFeed(
 		}
 	}
 
-	// 4. Sort all events chronologically (newest first)
+	// 5. Sort all events chronologically (newest first)
 	sort.Sort(ByTimestamp(allEvents))
 
-	// 5. Limit to the 30 most recent events
+	// 6. Limit to the 30 most recent events
 	limit := min(len(allEvents), 30)
 	recentEvents := allEvents[:limit]
 
 	return recentEvents
 }
 
+// fetchActivityFeed lists the team deployments and builds the activity feed for the given team scores
+func fetchActivityFeed(ctx context.Context, bundle *b.Bundle, allTeamScores []*b.TeamScore) ([]ActivityEvent, error) {
+	deployments, err := bundle.JuiceShopLister.List(labels.Everything())
+	if err != nil {
+		bundle.Log.Error("Failed to list deployments for activity feed", "error", err)
+		return nil, err
+	}
+
+	// Convert sorted team scores to map
+	scoresMap := make(map[string]*b.TeamScore)
+	for _, score := range allTeamScores {
+		scoresMap[score.Name] = score
+	}
+	return buildActivityFeed(bundle, scoresMap, deployments), nil
+}
+
 func handleActivityFeed(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Define the fetch function for long polling
 		fetchFunc := func(ctx context.Context, waitAfter *time.Time) ([]ActivityEvent, time.Time, bool, error) {
-
-			// Fetch all deployments from Kubernetes
-			deploymentList, err := bundle.ClientSet.
-				AppsV1().
-				Deployments(bundle.RuntimeEnvironment.Namespace).
-				List(ctx, metav1.ListOptions{
-					LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-				})
-			if err != nil {
-				bundle.Log.Error("Failed to list deployments for activity feed", "error", err)
-				return nil, time.Time{}, false, err
-			}
-
-			deployments := make([]*appsv1.Deployment, len(deploymentList.Items))
-			for i := range deploymentList.Items {
-				deployments[i] = &deploymentList.Items[i]
-			}
-
 			if waitAfter != nil {
 				allTeamScores, lastUpdateTime := bundle.ScoringService.WaitForUpdatesNewerThanWithTimestamp(ctx, *waitAfter)
 				if allTeamScores == nil {
 					return nil, time.Time{}, false, nil
 				}
-				// Convert sorted team scores to map
-				scoresMap := make(map[string]*b.TeamScore)
-				for _, score := range allTeamScores {
-					scoresMap[score.Name] = score
+				activityFeed, err := fetchActivityFeed(ctx, bundle, allTeamScores)
+				if err != nil {
+					return nil, time.Time{}, false, err
 				}
-				activityFeed := buildActivityFeed(bundle, scoresMap, deployments)
 				return activityFeed, lastUpdateTime, true, nil
 			}
 			allTeamScores, lastUpdateTime := bundle.ScoringService.GetTopScoresWithTimestamp()
-			// Convert sorted team scores to map
-			scoresMap := make(map[string]*b.TeamScore)
-			for _, score := range allTeamScores {
-				scoresMap[score.Name] = score
+			activityFeed, err := fetchActivityFeed(ctx, bundle, allTeamScores)
+			if err != nil {
+				return nil, time.Time{}, false, err
 			}
-			activityFeed := buildActivityFeed(bundle, scoresMap, deployments)
 			return activityFeed, lastUpdateTime, true, nil
 		}
 
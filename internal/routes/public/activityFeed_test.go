@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/scoring"
 	"github.com/juice-shop/multi-juicer/internal/testutil"
 	"github.com/stretchr/testify/assert"
@@ -35,18 +36,24 @@ func unmarshalActivityFeed(data []byte) ([]ActivityEvent, error) {
 		}
 
 		switch base.EventType {
-		case EventTypeTeamCreated:
+		case b.EventTypeTeamCreated:
 			var event TeamCreatedEvent
 			if err := json.Unmarshal(raw, &event); err != nil {
 				return nil, err
 			}
 			events = append(events, &event)
-		case EventTypeChallengeSolved:
+		case b.EventTypeChallengeSolved:
 			var event ChallengeSolvedEvent
 			if err := json.Unmarshal(raw, &event); err != nil {
 				return nil, err
 			}
 			events = append(events, &event)
+		case b.EventTypeScoreAdjusted:
+			var event ScoreAdjustedEvent
+			if err := json.Unmarshal(raw, &event); err != nil {
+				return nil, err
+			}
+			events = append(events, &event)
 		}
 	}
 
@@ -178,6 +185,94 @@ func TestActivityFeedHandler(t *testing.T) {
 		assert.True(t, ok2, "Second event should be a team created event")
 	})
 
+	t.Run("keeps solves and team creations of deleted teams from the event log", func(t *testing.T) {
+		solvedAt := time.Now().Add(-10 * time.Minute).UTC()
+		clientset := fake.NewClientset(
+			createTeamWithSolvedChallenges("team-alpha", "[]"),
+		)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		require.NoError(t, bundle.EventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamCreated, Team: "team-deleted", Timestamp: solvedAt.Add(-time.Minute)}))
+		require.NoError(t, bundle.EventLog.Append(context.Background(), b.Event{Type: b.EventTypeChallengeSolved, Team: "team-deleted", Timestamp: solvedAt, ChallengeKey: "scoreBoardChallenge"}))
+		require.NoError(t, bundle.EventLog.Append(context.Background(), b.Event{Type: b.EventTypeTeamDeleted, Team: "team-deleted", Details: "inactive"}))
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		server := http.NewServeMux()
+		bundle.ScoringService = scoringService
+		AddRoutes(server, bundle)
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/activity-feed", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		feed, err := unmarshalActivityFeed(rr.Body.Bytes())
+		require.NoError(t, err)
+		require.Len(t, feed, 3, "Expected the solve of the deleted team and two team creation events")
+
+		solvedEvent, ok := IsChallengeSolvedEvent(feed[0])
+		require.True(t, ok)
+		assert.Equal(t, "team-deleted", solvedEvent.Team)
+		assert.Equal(t, "scoreBoardChallenge", solvedEvent.ChallengeKey)
+		assert.True(t, solvedEvent.IsFirstSolve)
+		assert.True(t, solvedAt.Equal(solvedEvent.Timestamp))
+
+		createdEvent, ok := IsTeamCreatedEvent(feed[1])
+		require.True(t, ok)
+		assert.Equal(t, "team-deleted", createdEvent.Team)
+	})
+
+	t.Run("leaves out logged solves of teams whose deployment no longer has them, e.g. after an admin revoked them", func(t *testing.T) {
+		clientset := fake.NewClientset(
+			createTeamWithSolvedChallenges("team-alpha", "[]"),
+		)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		require.NoError(t, bundle.EventLog.Append(context.Background(), b.Event{Type: b.EventTypeChallengeSolved, Team: "team-alpha", Timestamp: time.Now().Add(-time.Minute), ChallengeKey: "scoreBoardChallenge"}))
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		server := http.NewServeMux()
+		bundle.ScoringService = scoringService
+		AddRoutes(server, bundle)
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/activity-feed", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		feed, err := unmarshalActivityFeed(rr.Body.Bytes())
+		require.NoError(t, err)
+		require.Len(t, feed, 1)
+		_, ok := IsTeamCreatedEvent(feed[0])
+		assert.True(t, ok)
+	})
+
+	t.Run("includes the score adjustments of the teams", func(t *testing.T) {
+		adjustedAt := time.Now().Add(-5 * time.Minute).UTC().Truncate(time.Second)
+		deployment := createTeamWithSolvedChallenges("team-alpha", "[]")
+		deployment.Annotations["multi-juicer.owasp-juice.shop/adjustments"] = fmt.Sprintf(`[{"points":20,"reason":"write-up","createdAt":"%s"}]`, adjustedAt.Format(time.RFC3339))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset(deployment))
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		server := http.NewServeMux()
+		bundle.ScoringService = scoringService
+		AddRoutes(server, bundle)
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/activity-feed", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		feed, err := unmarshalActivityFeed(rr.Body.Bytes())
+		require.NoError(t, err)
+		require.Len(t, feed, 2, "Expected the adjustment and the team creation event")
+
+		adjustedEvent, ok := IsScoreAdjustedEvent(feed[0])
+		require.True(t, ok)
+		assert.Equal(t, "team-alpha", adjustedEvent.Team)
+		assert.Equal(t, 20, adjustedEvent.Points)
+		assert.Equal(t, "write-up", adjustedEvent.Reason)
+		assert.True(t, adjustedAt.Equal(adjustedEvent.Timestamp))
+	})
+
 	t.Run("with more than 30 solves, should return only the 30 newest events", func(t *testing.T) {
 		var mockDeployments []runtime.Object
 		var newestSolveTime time.Time
@@ -0,0 +1,24 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+)
+
+// handleAdminCollusionReport returns the team pairs solving the same challenges at nearly the same time, with a similarity score per pair, and the teams solving hard challenges at an implausible pace
+func handleAdminCollusionReport(bundle *b.Bundle) http.Handler {
+	challengesByKeys := getChallengesByKeys(bundle)
+
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			report := scoring.DetectCollusion(bundle.ScoringService.GetScores(), challengesByKeys)
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(report)
+		},
+	)
+}
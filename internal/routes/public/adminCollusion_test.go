@@ -0,0 +1,59 @@
+package public
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdminCollusionReportHandler(t *testing.T) {
+	solvedAt := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	newServer := func() *http.ServeMux {
+		bu := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset())
+		bu.ScoringService = scoring.NewScoringServiceWithInitialScores(bu, map[string]*b.TeamScore{
+			"foobar": {Name: "foobar", Challenges: []b.ChallengeProgress{{Key: "nullByteChallenge", SolvedAt: solvedAt}}},
+			"barfoo": {Name: "barfoo", Challenges: []b.ChallengeProgress{{Key: "nullByteChallenge", SolvedAt: solvedAt.Add(15 * time.Second)}}},
+		})
+		server := http.NewServeMux()
+		AddRoutes(server, bu)
+		return server
+	}
+
+	getReport := func(team string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/admin/collusion", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
+		rr := httptest.NewRecorder()
+		newServer().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("requires admin login", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, getReport("foobar").Code)
+	})
+
+	t.Run("returns the similarity of team pairs", func(t *testing.T) {
+		rr := getReport("admin")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var report scoring.CollusionReport
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+		assert.Equal(t, []scoring.TeamPairSimilarity{
+			{
+				Teams:       [2]string{"barfoo", "foobar"},
+				Similarity:  0.75,
+				CloseSolves: []scoring.CloseSolve{{ChallengeKey: "nullByteChallenge", SecondsApart: 15, Solvers: 2}},
+			},
+		}, report.Pairs)
+		assert.Empty(t, report.FastTeams)
+	})
+}
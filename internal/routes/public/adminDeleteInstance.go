@@ -1,15 +1,14 @@
 package public
 
 import (
-	"fmt"
 	"net/http"
 
-	"github.com/juice-shop/multi-juicer/internal/bundle"
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/instances"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func handleAdminDeleteInstance(bundle *bundle.Bundle) http.Handler {
+func handleAdminDeleteInstance(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			teamToDelete := req.PathValue("team")
@@ -18,15 +17,17 @@ func handleAdminDeleteInstance(bundle *bundle.Bundle) http.Handler {
 				return
 			}
 
-			// Only the deployment needs to be deleted explicitly.
-			// The service and secret are owned by the deployment via OwnerReferences and will be garbage collected by Kubernetes.
-			err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Delete(req.Context(), fmt.Sprintf("juiceshop-%s", teamToDelete), metav1.DeleteOptions{})
+			err := instances.Delete(req.Context(), bundle, teamToDelete)
 			if err != nil && !errors.IsNotFound(err) {
 				bundle.Log.Error("Failed to delete deployment", "team", teamToDelete, "error", err)
 				http.Error(responseWriter, "", http.StatusInternalServerError)
 				return
 			}
 
+			if err := bundle.EventLog.Append(req.Context(), b.Event{Type: b.EventTypeTeamDeleted, Team: teamToDelete, Actor: "admin"}); err != nil {
+				bundle.Log.Error("Failed to record team deletion in event log", "team", teamToDelete, "error", err)
+			}
+
 			responseWriter.WriteHeader(http.StatusOK)
 			responseWriter.Write([]byte{}) // nosemgrep: go.lang.security.audit.xss.no-direct-write-to-responsewriter.no-direct-write-to-responsewriter
 		},
@@ -0,0 +1,148 @@
+package public
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/instances"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// reservedEnvVars are set by MultiJuicer to connect the JuiceShop to MultiJuicer, overriding them would break progress tracking, the verification of the CTF flags and the LLM gateway.
+// The environment of the application (e.g. CTF_KEY and NODE_ENV of the JuiceShop) is reserved as well, see isReservedEnvVar.
+var reservedEnvVars = map[string]bool{
+	"SOLUTIONS_WEBHOOK": true,
+	"CTF_KEY":           true,
+	"LLM_API_KEY":       true,
+}
+
+// AdminInstanceOverridesResponse are the overrides set on the instance, together with warnings about overrides that affect the scoring of the team
+type AdminInstanceOverridesResponse struct {
+	instances.Overrides
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func handleAdminGetInstanceOverrides(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+			if !isValidTeamName(team) {
+				http.Error(responseWriter, "invalid team name", http.StatusBadRequest)
+				return
+			}
+
+			overrides, err := instances.GetOverrides(req.Context(), bundle, team)
+			if errors.IsNotFound(err) {
+				http.Error(responseWriter, "", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				bundle.Log.Error("Failed to get instance overrides", "team", team, "error", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(overrides)
+		},
+	)
+}
+
+// handleAdminSetInstanceOverrides replaces the image, tag, env and resources of the instance of a team. Omitted fields fall back to the global config.
+func handleAdminSetInstanceOverrides(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+			if !isValidTeamName(team) {
+				http.Error(responseWriter, "invalid team name", http.StatusBadRequest)
+				return
+			}
+
+			var overrides instances.Overrides
+			if err := json.NewDecoder(req.Body).Decode(&overrides); err != nil {
+				http.Error(responseWriter, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			if err := validateOverrides(bundle, overrides); err != nil {
+				http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			err := instances.SetOverrides(req.Context(), bundle, team, overrides)
+			if errors.IsNotFound(err) {
+				http.Error(responseWriter, "", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				bundle.Log.Error("Failed to set instance overrides", "team", team, "error", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			bundle.Log.Info("Admin changed the instance overrides of team", "team", team, "image", overrides.Image, "tag", overrides.Tag)
+			warnings := overrideWarnings(bundle, overrides)
+			for _, warning := range warnings {
+				bundle.Log.Warn("Instance overrides affect the scoring of team", "team", team, "warning", warning)
+			}
+
+			details, _ := json.Marshal(overrides)
+			if err := bundle.EventLog.Append(req.Context(), b.Event{Type: b.EventTypeInstanceOverridesSet, Team: team, Actor: "admin", Details: string(details)}); err != nil {
+				bundle.Log.Error("Failed to record instance overrides in event log", "team", team, "error", err)
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(AdminInstanceOverridesResponse{Overrides: overrides, Warnings: warnings})
+		},
+	)
+}
+
+// overrideWarnings warns about a tag other than the one MultiJuicer was configured with. The challenges are only known for the configured JuiceShop version,
+// solves of challenges missing in it don't score and challenges added in it can't be solved by the team.
+func overrideWarnings(bundle *b.Bundle, overrides instances.Overrides) []string {
+	if overrides.Tag == "" || overrides.Tag == bundle.Config.JuiceShopConfig.Tag {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("tag %q differs from the JuiceShop version %q MultiJuicer was configured with, the challenge set of the team might change. Solves of challenges unknown to %q don't score", overrides.Tag, bundle.Config.JuiceShopConfig.Tag, bundle.Config.JuiceShopConfig.Tag),
+	}
+}
+
+// isReservedEnvVar returns true for env vars set by MultiJuicer or required by the application, e.g. the CTF_KEY the flags of the solution webhook are verified with
+func isReservedEnvVar(bundle *b.Bundle, name string) bool {
+	if reservedEnvVars[name] {
+		return true
+	}
+	return slices.ContainsFunc(bundle.Application.Container().Env, func(envVar corev1.EnvVar) bool { return envVar.Name == name })
+}
+
+func validateOverrides(bundle *b.Bundle, overrides instances.Overrides) error {
+	if strings.ContainsAny(overrides.Image, " @") {
+		return fmt.Errorf("invalid image %q", overrides.Image)
+	}
+	if separator := strings.LastIndex(overrides.Image, ":"); separator != -1 && !strings.Contains(overrides.Image[separator:], "/") {
+		return fmt.Errorf("invalid image %q, the tag has to be set separately", overrides.Image)
+	}
+	if strings.ContainsAny(overrides.Tag, " :/@") {
+		return fmt.Errorf("invalid tag %q", overrides.Tag)
+	}
+	for _, envVar := range overrides.Env {
+		if errs := validation.IsEnvVarName(envVar.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid env var name %q: %s", envVar.Name, strings.Join(errs, ", "))
+		}
+		if isReservedEnvVar(bundle, envVar.Name) {
+			return fmt.Errorf("env var %q is set by MultiJuicer and can't be overridden", envVar.Name)
+		}
+		if envVar.ValueFrom != nil {
+			// the value would be readable from within the JuiceShop, which the teams are supposed to hack
+			return fmt.Errorf("env var %q must have a plain value", envVar.Name)
+		}
+	}
+	return nil
+}
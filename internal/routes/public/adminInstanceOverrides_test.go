@@ -0,0 +1,128 @@
+package public
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/instances"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdminInstanceOverridesHandler(t *testing.T) {
+	multiJuicerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "multi-juicer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+
+	newServer := func(t *testing.T) (*http.ServeMux, *bundle.Bundle) {
+		clientset := fake.NewClientset(multiJuicerDeployment)
+		bu := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		_, err := instances.Provision(context.Background(), bu, "foobar", "passcode-hash")
+		require.NoError(t, err)
+
+		server := http.NewServeMux()
+		AddRoutes(server, bu)
+		return server, bu
+	}
+
+	putOverrides := func(team string, cookieTeam string, body string) *http.Request {
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/multi-juicer/api/admin/teams/%s/overrides", team), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(cookieTeam)))
+		return req
+	}
+
+	t.Run("requires admin login", func(t *testing.T) {
+		server, _ := newServer(t)
+		rr := httptest.NewRecorder()
+
+		server.ServeHTTP(rr, putOverrides("foobar", "foobar", `{"tag":"v19.0.0"}`))
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("sets the overrides on the instance of the team", func(t *testing.T) {
+		server, bu := newServer(t)
+		rr := httptest.NewRecorder()
+
+		server.ServeHTTP(rr, putOverrides("foobar", "admin", `{"image":"registry.example.com:5000/juice-shop","tag":"v19.0.0","env":[{"name":"DEBUG","value":"true"}]}`))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response AdminInstanceOverridesResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, "v19.0.0", response.Tag)
+		require.Len(t, response.Warnings, 1, "changing the JuiceShop version might change the challenge set")
+		assert.Contains(t, response.Warnings[0], `"latest"`)
+		instance, err := instances.Get(context.Background(), bu, "foobar")
+		require.NoError(t, err)
+		assert.Equal(t, "registry.example.com:5000/juice-shop", instance.Spec.Image)
+		assert.Equal(t, "v19.0.0", instance.Spec.Tag)
+		assert.Equal(t, []corev1.EnvVar{{Name: "DEBUG", Value: "true"}}, instance.Spec.Env)
+		assert.Nil(t, instance.Spec.Resources, "omitted fields fall back to the global config")
+
+		events := bu.EventLog.GetEvents()
+		require.Len(t, events, 1)
+		assert.Equal(t, bundle.EventTypeInstanceOverridesSet, events[0].Type)
+		assert.Equal(t, "foobar", events[0].Team)
+		assert.Equal(t, "admin", events[0].Actor)
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/admin/teams/foobar/overrides", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var overrides instances.Overrides
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &overrides))
+		assert.Equal(t, "v19.0.0", overrides.Tag)
+	})
+
+	t.Run("rejects invalid overrides", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"image with tag":      `{"image":"bkimminich/juice-shop:v19.0.0"}`,
+			"invalid tag":         `{"tag":"v19 0"}`,
+			"invalid env name":    `{"env":[{"name":"1NVALID","value":"x"}]}`,
+			"reserved env var":    `{"env":[{"name":"SOLUTIONS_WEBHOOK","value":"http://example.com"}]}`,
+			"ctf key":             `{"env":[{"name":"CTF_KEY","value":"guessable"}]}`,
+			"application env var": `{"env":[{"name":"NODE_ENV","value":"production"}]}`,
+			"env var from secret": `{"env":[{"name":"SECRET","valueFrom":{"secretKeyRef":{"name":"multi-juicer","key":"cookieSigningKey"}}}]}`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				server, bu := newServer(t)
+				rr := httptest.NewRecorder()
+
+				server.ServeHTTP(rr, putOverrides("foobar", "admin", body))
+
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				instance, err := instances.Get(context.Background(), bu, "foobar")
+				require.NoError(t, err)
+				assert.Empty(t, instance.Spec.Env)
+				assert.Empty(t, instance.Spec.Tag)
+			})
+		}
+	})
+
+	t.Run("returns 404 for unknown teams", func(t *testing.T) {
+		server, _ := newServer(t)
+		rr := httptest.NewRecorder()
+
+		server.ServeHTTP(rr, putOverrides("barfoo", "admin", `{"tag":"v19.0.0"}`))
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
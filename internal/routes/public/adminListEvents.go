@@ -0,0 +1,34 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+)
+
+type AdminListEventsResponse struct {
+	Events []b.Event `json:"events"`
+}
+
+// handleAdminListEvents returns the event log as audit trail for admins, oldest events first.
+// The "team" and "type" query parameters only return the events of the given team or type.
+func handleAdminListEvents(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.URL.Query().Get("team")
+			eventType := b.EventType(req.URL.Query().Get("type"))
+
+			events := []b.Event{}
+			for _, event := range bundle.EventLog.GetEvents() {
+				if (team == "" || event.Team == team) && (eventType == "" || event.Type == eventType) {
+					events = append(events, event)
+				}
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(AdminListEventsResponse{Events: events})
+		},
+	)
+}
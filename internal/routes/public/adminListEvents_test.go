@@ -0,0 +1,68 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdminListEventsHandler(t *testing.T) {
+	newServer := func(t *testing.T) *http.ServeMux {
+		bu := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset())
+		for _, event := range []b.Event{
+			{Type: b.EventTypeTeamCreated, Team: "foobar"},
+			{Type: b.EventTypeSolveRejected, Team: "foobar", ChallengeKey: "scoreBoardChallenge", Details: "invalid ctf flag"},
+			{Type: b.EventTypeSolveRejected, Team: "barfoo", ChallengeKey: "nullByteChallenge", Details: "invalid ctf flag"},
+		} {
+			require.NoError(t, bu.EventLog.Append(context.Background(), event))
+		}
+
+		server := http.NewServeMux()
+		AddRoutes(server, bu)
+		return server
+	}
+
+	getEvents := func(t *testing.T, server *http.ServeMux, query string, team string) (int, AdminListEventsResponse) {
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/admin/events"+query, nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		var response AdminListEventsResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		}
+		return rr.Code, response
+	}
+
+	t.Run("requires admin login", func(t *testing.T) {
+		code, _ := getEvents(t, newServer(t), "", "foobar")
+
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("lists all events", func(t *testing.T) {
+		code, response := getEvents(t, newServer(t), "", "admin")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, response.Events, 3)
+	})
+
+	t.Run("filters the events by team and type", func(t *testing.T) {
+		code, response := getEvents(t, newServer(t), "?team=foobar&type=solve_rejected", "admin")
+
+		assert.Equal(t, http.StatusOK, code)
+		require.Len(t, response.Events, 1)
+		assert.Equal(t, "scoreBoardChallenge", response.Events[0].ChallengeKey)
+		assert.Equal(t, "invalid ctf flag", response.Events[0].Details)
+	})
+}
@@ -3,11 +3,15 @@ package public
 import (
 	"encoding/json"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type AdminListInstancesResponse struct {
@@ -15,12 +19,14 @@ type AdminListInstancesResponse struct {
 }
 
 type AdminListJuiceShopInstance struct {
-	Team              string            `json:"team"`
-	Ready             bool              `json:"ready"`
-	CreatedAt         int64             `json:"createdAt"`
-	LastConnect       int64             `json:"lastConnect"`
-	CheatScore        *float64          `json:"cheatScore,omitempty"`
-	CheatScoreHistory []CheatScoreEntry `json:"cheatScoreHistory,omitempty"`
+	Team              string              `json:"team"`
+	Ready             bool                `json:"ready"`
+	CreatedAt         int64               `json:"createdAt"`
+	LastConnect       int64               `json:"lastConnect"`
+	CheatScore        *float64            `json:"cheatScore,omitempty"`
+	CheatScoreHistory []CheatScoreEntry   `json:"cheatScoreHistory,omitempty"`
+	CheatDecision     string              `json:"cheatDecision,omitempty"`
+	UnlockedHints     []bundle.HintUnlock `json:"unlockedHints,omitempty"`
 }
 
 type CheatScoreEntry struct {
@@ -31,17 +37,19 @@ type CheatScoreEntry struct {
 func handleAdminListInstances(bundle *bundle.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
-			deployments, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).List(req.Context(), metav1.ListOptions{
-				LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-			})
+			deployments, err := bundle.JuiceShopLister.List(labels.Everything())
 			if err != nil {
 				bundle.Log.Error("Failed to list deployments", "error", err)
 				http.Error(responseWriter, "unable to get instances", http.StatusInternalServerError)
 				return
 			}
+			// the cache returns the deployments in random order, sort them by name like the kubernetes api does
+			slices.SortFunc(deployments, func(a, b *appsv1.Deployment) int {
+				return strings.Compare(a.Name, b.Name)
+			})
 
 			instances := []AdminListJuiceShopInstance{}
-			for _, teamDeployment := range deployments.Items {
+			for _, teamDeployment := range deployments {
 
 				lastConnectAnnotation := teamDeployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"]
 				lastConnection := time.UnixMilli(0)
@@ -56,6 +64,7 @@ func handleAdminListInstances(bundle *bundle.Bundle) http.Handler {
 
 				// Parse cheat scores and get the newest one
 				var cheatScore *float64
+				cheatDecision := ""
 				var cheatScores []CheatScoreEntry
 				cheatScoresAnnotation := teamDeployment.Annotations["multi-juicer.owasp-juice.shop/cheatScores"]
 				if cheatScoresAnnotation != "" {
@@ -64,9 +73,15 @@ func handleAdminListInstances(bundle *bundle.Bundle) http.Handler {
 						// Get the newest cheat score (last entry in the array)
 						newestScore := cheatScores[len(cheatScores)-1].TotalCheatScore
 						cheatScore = &newestScore
+						cheatDecision = bundle.Config.ScoringConfig.CheatScore.Decide(newestScore)
 					}
 				}
 
+				unlockedHints, err := scoring.ParseHintUnlocks(teamDeployment)
+				if err != nil {
+					bundle.Log.Warn("Failed to decode hints annotation", "team", teamDeployment.Labels["team"], "error", err)
+				}
+
 				instances = append(instances, AdminListJuiceShopInstance{
 					Team:              teamDeployment.Labels["team"],
 					Ready:             teamDeployment.Status.ReadyReplicas == 1,
@@ -74,6 +89,8 @@ func handleAdminListInstances(bundle *bundle.Bundle) http.Handler {
 					LastConnect:       lastConnection.UnixMilli(),
 					CheatScore:        cheatScore,
 					CheatScoreHistory: cheatScores,
+					CheatDecision:     cheatDecision,
+					UnlockedHints:     unlockedHints,
 				})
 			}
 
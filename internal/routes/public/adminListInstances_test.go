@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
@@ -170,6 +171,40 @@ func TestAdminListInstanceshandler(t *testing.T) {
 		assert.Nil(t, teamWithoutScores.CheatScore)
 	})
 
+	t.Run("includes the decision for cheat scores above the configured thresholds", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/admin/all", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewClientset(
+			createTeamWithCheatScores("cheater", time.UnixMilli(1_700_000_000_000), time.UnixMilli(1_729_259_666_123), 1, `[{"totalCheatScore":0.9,"timestamp":"2024-10-18T13:55:18Z"}]`),
+			createTeamWithCheatScores("suspect", time.UnixMilli(1_600_000_000_000), time.UnixMilli(1_729_259_333_123), 1, `[{"totalCheatScore":0.9,"timestamp":"2024-10-18T13:50:10Z"},{"totalCheatScore":0.6,"timestamp":"2024-10-18T14:50:10Z"}]`),
+			createTeamWithCheatScores("honest", time.UnixMilli(1_650_000_000_000), time.UnixMilli(1_729_259_555_123), 1, `[{"totalCheatScore":0.1,"timestamp":"2024-10-18T13:50:10Z"}]`),
+		)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.ScoringConfig.CheatScore = b.CheatScoreConfig{SuspiciousThreshold: 0.5, DisqualifyThreshold: 0.8}
+		AddRoutes(server, bundle)
+
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response AdminListInstancesResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &response)
+		assert.Nil(t, err)
+
+		decisions := map[string]string{}
+		for _, instance := range response.Instances {
+			decisions[instance.Team] = instance.CheatDecision
+		}
+		assert.Equal(t, map[string]string{
+			"cheater": b.CheatDecisionDisqualified,
+			"suspect": b.CheatDecisionSuspicious,
+			"honest":  "",
+		}, decisions)
+	})
+
 	t.Run("handles invalid cheat score JSON gracefully", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/multi-juicer/api/admin/all", nil)
 		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
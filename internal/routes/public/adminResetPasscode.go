@@ -2,16 +2,15 @@ package public
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
-	"github.com/juice-shop/multi-juicer/internal/bundle"
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
 	"golang.org/x/crypto/bcrypt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
-func handleAdminResetPasscode(bundle *bundle.Bundle) http.Handler {
+func handleAdminResetPasscode(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			teamToReset := req.PathValue("team")
@@ -22,7 +21,7 @@ func handleAdminResetPasscode(bundle *bundle.Bundle) http.Handler {
 
 			newPasscode := bundle.GeneratePasscode()
 
-			deployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(req.Context(), fmt.Sprintf("juiceshop-%s", teamToReset), metav1.GetOptions{})
+			deployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(req.Context(), bundle.JuiceShopDeploymentName(teamToReset), metav1.GetOptions{})
 			if err != nil {
 				http.NotFound(responseWriter, req)
 				return
@@ -62,6 +61,10 @@ func handleAdminResetPasscode(bundle *bundle.Bundle) http.Handler {
 				return
 			}
 
+			if err := bundle.EventLog.Append(req.Context(), b.Event{Type: b.EventTypePasscodeReset, Team: teamToReset, Actor: "admin"}); err != nil {
+				bundle.Log.Error("Failed to record passcode reset in event log", "team", teamToReset, "error", err)
+			}
+
 			responseBody := ResetPasscodeResponse{
 				Message:  "Passcode reset successfully",
 				Passcode: newPasscode,
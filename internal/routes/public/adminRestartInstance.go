@@ -1,14 +1,14 @@
 package public
 
 import (
-	"fmt"
 	"net/http"
 
-	"github.com/juice-shop/multi-juicer/internal/bundle"
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func handleAdminRestartInstance(bundle *bundle.Bundle) http.Handler {
+func handleAdminRestartInstance(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			teamToRestart := req.PathValue("team")
@@ -17,10 +17,20 @@ func handleAdminRestartInstance(bundle *bundle.Bundle) http.Handler {
 				return
 			}
 
-			// find pod for service
+			// find pod for service. deployments claimed from the warm pool don't have the team label on their pods, so use the selector of the deployment
+			deployment, err := bundle.GetJuiceShopDeployment(teamToRestart)
+			if errors.IsNotFound(err) {
+				http.Error(responseWriter, "", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				bundle.Log.Error("Failed to get deployment", "team", teamToRestart, "error", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
 
 			pods, err := bundle.ClientSet.CoreV1().Pods(bundle.RuntimeEnvironment.Namespace).List(req.Context(), metav1.ListOptions{
-				LabelSelector: fmt.Sprintf("app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer,team=%s", teamToRestart),
+				LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
 			})
 
 			if err != nil {
@@ -45,6 +55,10 @@ func handleAdminRestartInstance(bundle *bundle.Bundle) http.Handler {
 				return
 			}
 
+			if err := bundle.EventLog.Append(req.Context(), b.Event{Type: b.EventTypeInstanceRestarted, Team: teamToRestart, Actor: "admin"}); err != nil {
+				bundle.Log.Error("Failed to record instance restart in event log", "team", teamToRestart, "error", err)
+			}
+
 			responseWriter.WriteHeader(http.StatusOK)
 			responseWriter.Write([]byte{}) // nosemgrep: go.lang.security.audit.xss.no-direct-write-to-responsewriter.no-direct-write-to-responsewriter
 		},
@@ -7,9 +7,12 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/testutil"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
@@ -30,6 +33,28 @@ func TestAdminRestartInstanceHandler(t *testing.T) {
 			Spec: corev1.PodSpec{},
 		}
 	}
+	createDeploymentForTeam := func(team string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app.kubernetes.io/name":    "juice-shop",
+						"app.kubernetes.io/part-of": "multi-juicer",
+						"team":                      team,
+					},
+				},
+			},
+		}
+	}
 
 	t.Run("restarting instances requires admin login", func(t *testing.T) {
 		req, _ := http.NewRequest("POST", "/multi-juicer/api/admin/teams/foobar/restart", nil)
@@ -71,7 +96,7 @@ func TestAdminRestartInstanceHandler(t *testing.T) {
 
 		server := http.NewServeMux()
 
-		clientset := fake.NewClientset(createPodForTeam("foobar"), createPodForTeam("other-team"))
+		clientset := fake.NewClientset(createDeploymentForTeam("foobar"), createPodForTeam("foobar"), createDeploymentForTeam("other-team"), createPodForTeam("other-team"))
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		AddRoutes(server, bundle)
 
@@ -82,15 +107,51 @@ func TestAdminRestartInstanceHandler(t *testing.T) {
 
 		actions := clientset.Actions()
 
-		assert.Len(t, actions, 2)
+		// list + delete of the pod, followed by list + create of the event log chunk
+		assert.Len(t, actions, 4)
 
 		assert.Equal(t, "list", actions[0].GetVerb())
 		assert.Equal(t, schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}, actions[0].GetResource())
 		assert.Equal(t, "delete", actions[1].GetVerb())
 		assert.Equal(t, schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}, actions[1].GetResource())
 
+		events := bundle.EventLog.GetEvents()
+		assert.Len(t, events, 1)
+		assert.Equal(t, b.EventTypeInstanceRestarted, events[0].Type)
+		assert.Equal(t, "foobar", events[0].Team)
+		assert.Equal(t, "admin", events[0].Actor)
+
 		pods, err := clientset.CoreV1().Pods("test-namespace").List(context.Background(), metav1.ListOptions{})
 		assert.Nil(t, err)
 		assert.Len(t, pods.Items, 1)
 	})
+
+	t.Run("restarts instances claimed from the warm pool", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/multi-juicer/api/admin/teams/foobar/restart", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		deployment := createDeploymentForTeam("foobar")
+		deployment.Name = "juiceshop-pool-abc"
+		deployment.Spec.Selector.MatchLabels = map[string]string{
+			"app.kubernetes.io/name":                 "juice-shop",
+			"app.kubernetes.io/part-of":              "multi-juicer",
+			"multi-juicer.owasp-juice.shop/instance": "juiceshop-pool-abc",
+		}
+		pod := createPodForTeam("foobar")
+		pod.Labels = deployment.Spec.Selector.MatchLabels
+		clientset := fake.NewClientset(deployment, pod, createDeploymentForTeam("other-team"), createPodForTeam("other-team"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		_, err := clientset.CoreV1().Pods("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err))
+		_, err = clientset.CoreV1().Pods("test-namespace").Get(context.Background(), "juiceshop-other-team", metav1.GetOptions{})
+		assert.Nil(t, err)
+	})
 }
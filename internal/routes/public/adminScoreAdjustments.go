@@ -0,0 +1,126 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	scoreAdjustmentMaxRetries = 3
+	// maxScoreAdjustmentReasonLength is the number of characters allowed for the reason of a score adjustment, it's shown in the public activity feed
+	maxScoreAdjustmentReasonLength = 128
+)
+
+type AdminScoreAdjustmentRequest struct {
+	// Points to add to the score of the team, negative for penalties
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+}
+
+// handleAdminAddScoreAdjustment awards points to or takes points from a team, e.g. a bonus for a write-up or a penalty for attacking the infrastructure
+func handleAdminAddScoreAdjustment(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+			if !isValidTeamName(team) {
+				http.Error(responseWriter, "invalid team name", http.StatusBadRequest)
+				return
+			}
+
+			var adjustmentReq AdminScoreAdjustmentRequest
+			if err := json.NewDecoder(req.Body).Decode(&adjustmentReq); err != nil {
+				http.Error(responseWriter, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			reason := strings.TrimSpace(adjustmentReq.Reason)
+			if adjustmentReq.Points == 0 {
+				http.Error(responseWriter, "points must not be 0", http.StatusBadRequest)
+				return
+			}
+			if reason == "" {
+				http.Error(responseWriter, "reason is required", http.StatusBadRequest)
+				return
+			}
+			if len([]rune(reason)) > maxScoreAdjustmentReasonLength {
+				http.Error(responseWriter, fmt.Sprintf("reason too long (max %d characters)", maxScoreAdjustmentReasonLength), http.StatusBadRequest)
+				return
+			}
+
+			adjustment, err := addScoreAdjustmentForTeam(req.Context(), bundle, team, adjustmentReq.Points, reason)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					http.Error(responseWriter, "team not found", http.StatusNotFound)
+					return
+				}
+				bundle.Log.Error("Failed to adjust score", "team", team, "error", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			bundle.Log.Info("Admin adjusted score of team", "team", team, "points", adjustment.Points, "reason", adjustment.Reason)
+
+			if err := bundle.EventLog.Append(req.Context(), b.Event{
+				Type:    b.EventTypeScoreAdjusted,
+				Team:    team,
+				Actor:   "admin",
+				Details: fmt.Sprintf("%+d points: %s", adjustment.Points, adjustment.Reason),
+			}); err != nil {
+				bundle.Log.Error("Failed to record score adjustment in event log", "team", team, "error", err)
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(adjustment)
+		},
+	)
+}
+
+// addScoreAdjustmentForTeam appends the adjustment to the adjustments annotation of the team's deployment.
+// Uses optimistic concurrency like unlockHintForTeam so that concurrent adjustments from multiple replicas don't overwrite each other.
+func addScoreAdjustmentForTeam(ctx context.Context, bundle *b.Bundle, team string, points int, reason string) (b.ScoreAdjustment, error) {
+	for attempt := range scoreAdjustmentMaxRetries {
+		deployment, err := getDeployment(ctx, bundle, team)
+		if err != nil {
+			return b.ScoreAdjustment{}, err
+		}
+
+		adjustments, err := scoring.ParseScoreAdjustments(deployment)
+		if err != nil {
+			return b.ScoreAdjustment{}, err
+		}
+
+		adjustment := b.ScoreAdjustment{
+			Points:    points,
+			Reason:    reason,
+			CreatedAt: time.Now().UTC(),
+		}
+		encodedAdjustments, err := json.Marshal(append(adjustments, adjustment))
+		if err != nil {
+			return b.ScoreAdjustment{}, fmt.Errorf("failed to encode adjustments annotation: %w", err)
+		}
+
+		updatedAnnotations := make(map[string]string, len(deployment.Annotations)+1)
+		maps.Copy(updatedAnnotations, deployment.Annotations)
+		updatedAnnotations["multi-juicer.owasp-juice.shop/adjustments"] = string(encodedAdjustments)
+		deployment.Annotations = updatedAnnotations
+
+		_, err = bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+		if err == nil {
+			return adjustment, nil
+		}
+		if !errors.IsConflict(err) {
+			return b.ScoreAdjustment{}, fmt.Errorf("failed to update deployment: %w", err)
+		}
+		bundle.Log.Warn("Score adjustment conflict, retrying", "team", team, "attempt", attempt+1, "maxRetries", scoreAdjustmentMaxRetries)
+	}
+	return b.ScoreAdjustment{}, fmt.Errorf("failed to update deployment after %d retries due to conflicts", scoreAdjustmentMaxRetries)
+}
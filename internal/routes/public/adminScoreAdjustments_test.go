@@ -0,0 +1,127 @@
+package public
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdminAddScoreAdjustmentHandler(t *testing.T) {
+	team := "foobar"
+
+	createTeam := func(annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("juiceshop-%s", team),
+				Namespace:   "test-namespace",
+				Annotations: annotations,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+		}
+	}
+
+	adjustScore := func(bundle *b.Bundle, team string, body string, cookie string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/multi-juicer/api/admin/teams/%s/adjustments", team), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", cookie))
+		rr := httptest.NewRecorder()
+		server := http.NewServeMux()
+		AddRoutes(server, bundle)
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("persists the adjustment on the deployment and includes it in the score", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges":  `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`,
+			"multi-juicer.owasp-juice.shop/adjustments": `[{"points":15,"reason":"write-up","createdAt":"2024-11-01T20:00:00Z"}]`,
+		}))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		rr := adjustScore(bundle, team, `{"points":-25,"reason":"  attacked the infrastructure "}`, testutil.SignTestTeamname("admin"))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response b.ScoreAdjustment
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, -25, response.Points)
+		assert.Equal(t, "attacked the infrastructure", response.Reason)
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		require.NoError(t, err)
+		adjustments, err := scoring.ParseScoreAdjustments(deployment)
+		require.NoError(t, err)
+		require.Len(t, adjustments, 2)
+		assert.Equal(t, "write-up", adjustments[0].Reason)
+		assert.Equal(t, -25, adjustments[1].Points)
+
+		events := bundle.EventLog.GetEvents()
+		require.Len(t, events, 1)
+		assert.Equal(t, b.EventTypeScoreAdjusted, events[0].Type)
+		assert.Equal(t, team, events[0].Team)
+		assert.Equal(t, "admin", events[0].Actor)
+		assert.Equal(t, "-25 points: attacked the infrastructure", events[0].Details)
+
+		// wait for the informer cache to pick up the update before calculating the scores from it
+		testutil.WaitForJuiceShopDeployment(bundle, team, func(deployment *appsv1.Deployment) bool {
+			adjustments, err := scoring.ParseScoreAdjustments(deployment)
+			return err == nil && len(adjustments) == 2
+		})
+		scoringService := scoring.NewScoringService(bundle)
+		require.NoError(t, scoringService.CalculateAndCacheScoreBoard(context.Background()))
+		score, ok := scoringService.GetScoreForTeam(team)
+		require.True(t, ok)
+		assert.Equal(t, 30, score.Score, "adjustments should be added to the score")
+	})
+
+	t.Run("rejects adjustments without points or reason", func(t *testing.T) {
+		bundle := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset(createTeam(map[string]string{})))
+
+		for _, body := range []string{
+			`{"points":0,"reason":"nothing"}`,
+			`{"points":10,"reason":"   "}`,
+			fmt.Sprintf(`{"points":10,"reason":"%s"}`, bytes.Repeat([]byte("a"), maxScoreAdjustmentReasonLength+1)),
+			`{"points":"ten"}`,
+		} {
+			rr := adjustScore(bundle, team, body, testutil.SignTestTeamname("admin"))
+			assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+		}
+		assert.Empty(t, bundle.EventLog.GetEvents())
+	})
+
+	t.Run("returns 404 for unknown teams", func(t *testing.T) {
+		bundle := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset())
+
+		rr := adjustScore(bundle, "barfoo", `{"points":10,"reason":"write-up"}`, testutil.SignTestTeamname("admin"))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("requires the admin cookie", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{}))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		rr := adjustScore(bundle, team, `{"points":1000,"reason":"we deserve it"}`, testutil.SignTestTeamname(team))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, deployment.Annotations["multi-juicer.owasp-juice.shop/adjustments"])
+	})
+}
@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/juice-shop/multi-juicer/internal/bundle"
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
 )
 
 type AdminClockRequest struct {
@@ -14,7 +14,7 @@ type AdminClockRequest struct {
 	FreezeScoreboardOnEnd bool `json:"freezeScoreboardOnEnd"`
 }
 
-func handleAdminSetClock(bundle *bundle.Bundle) http.Handler {
+func handleAdminSetClock(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			// Parse request body
@@ -37,6 +37,14 @@ func handleAdminSetClock(bundle *bundle.Bundle) http.Handler {
 				return
 			}
 
+			details := "cleared"
+			if clockReq.EndDate != nil {
+				details = clockReq.EndDate.UTC().Format(time.RFC3339)
+			}
+			if err := bundle.EventLog.Append(req.Context(), b.Event{Type: b.EventTypeClockSet, Actor: "admin", Details: details}); err != nil {
+				bundle.Log.Error("Failed to record clock change in event log", "error", err)
+			}
+
 			// Return success response
 			responseWriter.Header().Set("Content-Type", "application/json")
 			responseWriter.WriteHeader(http.StatusOK)
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"github.com/juice-shop/multi-juicer/internal/bundle"
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
 )
 
 type AdminNotificationRequest struct {
@@ -16,7 +16,7 @@ type AdminNotificationResponse struct {
 	Success bool `json:"success"`
 }
 
-func handleAdminPostNotification(bundle *bundle.Bundle) http.Handler {
+func handleAdminPostNotification(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			// Parse request body
@@ -39,6 +39,10 @@ func handleAdminPostNotification(bundle *bundle.Bundle) http.Handler {
 				return
 			}
 
+			if err := bundle.EventLog.Append(req.Context(), b.Event{Type: b.EventTypeNotificationSet, Actor: "admin", Details: notificationReq.Message}); err != nil {
+				bundle.Log.Error("Failed to record notification change in event log", "error", err)
+			}
+
 			// Return success response
 			responseWriter.Header().Set("Content-Type", "application/json")
 			responseWriter.WriteHeader(http.StatusOK)
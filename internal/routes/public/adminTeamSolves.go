@@ -0,0 +1,297 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/progresswatchdog"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const solveEditMaxRetries = 3
+
+type AdminTeamSolvesResponse struct {
+	Solves []AdminTeamSolve `json:"solves"`
+}
+
+// AdminTeamSolve is a challenge solved by the team together with the evidence reported by its instance.
+// Solves only picked up by the background sync, e.g. because the webhook failed, come without evidence.
+type AdminTeamSolve struct {
+	Key            string `json:"key"`
+	Name           string `json:"name"`
+	SolvedAt       string `json:"solvedAt"`
+	Evidence       string `json:"evidence,omitempty"`
+	IssuerVersion  string `json:"issuerVersion,omitempty"`
+	IssuerHostName string `json:"issuerHostName,omitempty"`
+}
+
+// handleAdminListTeamSolves returns the solved challenges of a team with their evidence for training debriefs, oldest solves first
+func handleAdminListTeamSolves(bundle *b.Bundle) http.Handler {
+	challengesByKeys := getChallengesByKeys(bundle)
+
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+			if !isValidTeamName(team) {
+				http.Error(responseWriter, "invalid team name", http.StatusBadRequest)
+				return
+			}
+
+			deployment, err := bundle.GetJuiceShopDeployment(team)
+			if errors.IsNotFound(err) {
+				http.Error(responseWriter, "", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				bundle.Log.Error("Failed to get deployment", "team", team, "error", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			challenges, err := progresswatchdog.ParseChallengeStatuses(deployment)
+			if err != nil {
+				bundle.Log.Error("Failed to decode challenges annotation", "team", team, "error", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(AdminTeamSolvesResponse{Solves: buildAdminTeamSolves(bundle, challengesByKeys, team, deployment, challenges)})
+		},
+	)
+}
+
+// handleAdminGrantSolve marks a challenge as solved for a team, e.g. when the solutions webhook of its instance got lost.
+// The solve is persisted right away and applied to the running instance of the team via a continue code. If that fails, the background-sync applies it later on.
+func handleAdminGrantSolve(bundle *b.Bundle) http.Handler {
+	challengesByKeys := getChallengesByKeys(bundle)
+
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team, challengeKey, ok := getTeamSolveTarget(challengesByKeys, responseWriter, req)
+			if !ok {
+				return
+			}
+
+			granted := false
+			deployment, challenges, changed, err := editTeamSolves(req.Context(), bundle, team, func(challenges progresswatchdog.ChallengeStatuses, revokedChallenges map[string]bool) (progresswatchdog.ChallengeStatuses, bool) {
+				granted = !slices.ContainsFunc(challenges, func(challenge b.ChallengeStatus) bool {
+					return challenge.Key == challengeKey
+				})
+				if granted {
+					challenges = append(challenges, b.ChallengeStatus{Key: challengeKey, SolvedAt: time.Now().UTC().Format(time.RFC3339)})
+					sort.Stable(challenges)
+				}
+				unrevoked := revokedChallenges[challengeKey]
+				delete(revokedChallenges, challengeKey)
+				return challenges, granted || unrevoked
+			})
+			if !writeTeamSolvesError(bundle, responseWriter, team, err) {
+				return
+			}
+
+			if changed {
+				bundle.Log.Info("Admin granted challenge solve", "team", team, "challenge", challengeKey)
+				recordSolveChange(bundle, req, b.EventTypeSolveGranted, team, challengeKey)
+			}
+			if granted && deployment.Status.ReadyReplicas > 0 {
+				if err := bundle.Application.RestoreProgress(req.Context(), bundle.GetJuiceShopUrlForTeam(team, bundle), challenges); err != nil {
+					bundle.Log.Warn("Failed to apply granted solve to the instance, the background-sync retries it", "team", team, "challenge", challengeKey, "error", err)
+				}
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(AdminTeamSolvesResponse{Solves: buildAdminTeamSolves(bundle, challengesByKeys, team, deployment, challenges)})
+		},
+	)
+}
+
+// handleAdminRevokeSolve marks a challenge as unsolved for a team, e.g. when the team exploited a bug of the event.
+// The instance of the team keeps the challenge solved, as continue codes can't unsolve challenges. The challenge is remembered as revoked instead, so that the background-sync and the solutions webhook don't bring the solve back.
+func handleAdminRevokeSolve(bundle *b.Bundle) http.Handler {
+	challengesByKeys := getChallengesByKeys(bundle)
+
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team, challengeKey, ok := getTeamSolveTarget(challengesByKeys, responseWriter, req)
+			if !ok {
+				return
+			}
+
+			deployment, challenges, changed, err := editTeamSolves(req.Context(), bundle, team, func(challenges progresswatchdog.ChallengeStatuses, revokedChallenges map[string]bool) (progresswatchdog.ChallengeStatuses, bool) {
+				revoked := !revokedChallenges[challengeKey]
+				revokedChallenges[challengeKey] = true
+				solveCount := len(challenges)
+				challenges = slices.DeleteFunc(challenges, func(challenge b.ChallengeStatus) bool {
+					return challenge.Key == challengeKey
+				})
+				return challenges, revoked || len(challenges) < solveCount
+			})
+			if !writeTeamSolvesError(bundle, responseWriter, team, err) {
+				return
+			}
+
+			if changed {
+				bundle.Log.Info("Admin revoked challenge solve", "team", team, "challenge", challengeKey)
+				recordSolveChange(bundle, req, b.EventTypeSolveRevoked, team, challengeKey)
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(AdminTeamSolvesResponse{Solves: buildAdminTeamSolves(bundle, challengesByKeys, team, deployment, challenges)})
+		},
+	)
+}
+
+// getTeamSolveTarget validates the team and challenge of a grant or revoke request.
+// Writes the error response and returns false if the request can't be served.
+func getTeamSolveTarget(challengesByKeys map[string]b.JuiceShopChallenge, responseWriter http.ResponseWriter, req *http.Request) (string, string, bool) {
+	team := req.PathValue("team")
+	if !isValidTeamName(team) {
+		http.Error(responseWriter, "invalid team name", http.StatusBadRequest)
+		return "", "", false
+	}
+	challengeKey := req.PathValue("challengeKey")
+	if _, ok := challengesByKeys[challengeKey]; !ok {
+		http.Error(responseWriter, "challenge not found", http.StatusNotFound)
+		return "", "", false
+	}
+	return team, challengeKey, true
+}
+
+// writeTeamSolvesError writes the error response for a failed edit of the solves of a team. Returns true if there was no error.
+func writeTeamSolvesError(bundle *b.Bundle, responseWriter http.ResponseWriter, team string, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.IsNotFound(err) {
+		http.Error(responseWriter, "team not found", http.StatusNotFound)
+		return false
+	}
+	bundle.Log.Error("Failed to edit solves of team", "team", team, "error", err)
+	http.Error(responseWriter, "", http.StatusInternalServerError)
+	return false
+}
+
+// editTeamSolves applies the edit to the solved and revoked challenges of the team and writes both annotations with a single update.
+// The edit modifies the revoked challenges in place and returns the solved challenges and whether it changed anything. It's called again if the deployment changed in between.
+// Uses optimistic concurrency like addScoreAdjustmentForTeam, so that solutions webhooks and the background-sync writing the challenges in between aren't overwritten.
+func editTeamSolves(ctx context.Context, bundle *b.Bundle, team string, edit func(challenges progresswatchdog.ChallengeStatuses, revokedChallenges map[string]bool) (progresswatchdog.ChallengeStatuses, bool)) (*appsv1.Deployment, progresswatchdog.ChallengeStatuses, bool, error) {
+	for attempt := range solveEditMaxRetries {
+		// read from the API, as the informer cache might lag behind the last change
+		deployment, err := getDeployment(ctx, bundle, team)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		challenges, revokedChallenges, err := parseSolvesToEdit(deployment)
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		challenges, changed := edit(challenges, revokedChallenges)
+		if !changed {
+			return deployment, challenges, false, nil
+		}
+
+		encodedChallenges, err := json.Marshal(challenges)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to encode challenges annotation: %w", err)
+		}
+		updatedAnnotations := make(map[string]string, len(deployment.Annotations)+3)
+		maps.Copy(updatedAnnotations, deployment.Annotations)
+		updatedAnnotations["multi-juicer.owasp-juice.shop/challenges"] = string(encodedChallenges)
+		updatedAnnotations["multi-juicer.owasp-juice.shop/challengesSolved"] = strconv.Itoa(len(challenges))
+		if len(revokedChallenges) > 0 {
+			encodedRevokedChallenges, err := json.Marshal(slices.Sorted(maps.Keys(revokedChallenges)))
+			if err != nil {
+				return nil, nil, false, fmt.Errorf("failed to encode revokedChallenges annotation: %w", err)
+			}
+			updatedAnnotations["multi-juicer.owasp-juice.shop/revokedChallenges"] = string(encodedRevokedChallenges)
+		} else {
+			delete(updatedAnnotations, "multi-juicer.owasp-juice.shop/revokedChallenges")
+		}
+		deployment.Annotations = updatedAnnotations
+
+		updated, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+		if err == nil {
+			return updated, challenges, true, nil
+		}
+		if !errors.IsConflict(err) {
+			return nil, nil, false, fmt.Errorf("failed to update deployment: %w", err)
+		}
+		bundle.Log.Warn("Solve edit conflict, retrying", "team", team, "attempt", attempt+1, "maxRetries", solveEditMaxRetries)
+	}
+	return nil, nil, false, fmt.Errorf("failed to update deployment after %d retries due to conflicts", solveEditMaxRetries)
+}
+
+func parseSolvesToEdit(deployment *appsv1.Deployment) (progresswatchdog.ChallengeStatuses, map[string]bool, error) {
+	challenges, err := progresswatchdog.ParseChallengeStatuses(deployment)
+	if err != nil {
+		return nil, nil, err
+	}
+	revokedChallenges, err := progresswatchdog.ParseRevokedChallenges(deployment)
+	if err != nil {
+		return nil, nil, err
+	}
+	return challenges, revokedChallenges, nil
+}
+
+// recordSolveChange records the grant or revoke in the event log. Admins share the admin account, so the actor is always "admin"
+func recordSolveChange(bundle *b.Bundle, req *http.Request, eventType b.EventType, team string, challengeKey string) {
+	if err := bundle.EventLog.Append(req.Context(), b.Event{Type: eventType, Team: team, Actor: "admin", ChallengeKey: challengeKey}); err != nil {
+		bundle.Log.Error("Failed to record solve change in event log", "team", team, "challenge", challengeKey, "type", eventType, "error", err)
+	}
+}
+
+// buildAdminTeamSolves joins the solved challenges of a team with their evidence, oldest solves first
+func buildAdminTeamSolves(bundle *b.Bundle, challengesByKeys map[string]b.JuiceShopChallenge, team string, deployment *appsv1.Deployment, challenges []b.ChallengeStatus) []AdminTeamSolve {
+	evidenceByKeys := map[string]b.SolveEvidence{}
+	evidence, err := parseSolveEvidence(deployment)
+	if err != nil {
+		bundle.Log.Warn("Failed to decode solve evidence", "team", team, "error", err)
+	}
+	for _, solve := range evidence {
+		evidenceByKeys[solve.Key] = solve
+	}
+
+	solves := make([]AdminTeamSolve, 0, len(challenges))
+	for _, challenge := range challenges {
+		solves = append(solves, AdminTeamSolve{
+			Key:            challenge.Key,
+			Name:           challengesByKeys[challenge.Key].Name,
+			SolvedAt:       challenge.SolvedAt,
+			Evidence:       evidenceByKeys[challenge.Key].Evidence,
+			IssuerVersion:  evidenceByKeys[challenge.Key].IssuerVersion,
+			IssuerHostName: evidenceByKeys[challenge.Key].IssuerHostName,
+		})
+	}
+	sort.SliceStable(solves, func(i, j int) bool {
+		return solves[i].SolvedAt < solves[j].SolvedAt
+	})
+	return solves
+}
+
+// parseSolveEvidence decodes the evidence of the solves of a team from the solves annotation of its deployment
+func parseSolveEvidence(deployment *appsv1.Deployment) ([]b.SolveEvidence, error) {
+	solvesAnnotation := deployment.Annotations["multi-juicer.owasp-juice.shop/solves"]
+	if solvesAnnotation == "" {
+		return nil, nil
+	}
+	var solves []b.SolveEvidence
+	if err := json.Unmarshal([]byte(solvesAnnotation), &solves); err != nil {
+		return nil, fmt.Errorf("failed to decode solves annotation: %w", err)
+	}
+	return solves, nil
+}
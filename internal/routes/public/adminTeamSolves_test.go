@@ -0,0 +1,249 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/progresswatchdog"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestAdminListTeamSolvesHandler(t *testing.T) {
+	newServer := func() *http.ServeMux {
+		clientset := fake.NewClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "juiceshop-foobar",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					"multi-juicer.owasp-juice.shop/challenges": `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z"},{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:10:00Z"}]`,
+					"multi-juicer.owasp-juice.shop/solves":     `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z","evidence":"/#/score-board","issuerVersion":"19.0.0","issuerHostName":"juiceshop-foobar-abc"}]`,
+				},
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      "foobar",
+				},
+			},
+		})
+		server := http.NewServeMux()
+		AddRoutes(server, testutil.NewTestBundleWithCustomFakeClient(clientset))
+		return server
+	}
+
+	getSolves := func(team string, cookieTeam string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/multi-juicer/api/admin/teams/%s/solves", team), nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(cookieTeam)))
+		rr := httptest.NewRecorder()
+		newServer().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("requires admin login", func(t *testing.T) {
+		rr := getSolves("foobar", "foobar")
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("lists the solves of the team with their evidence", func(t *testing.T) {
+		rr := getSolves("foobar", "admin")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response AdminTeamSolvesResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, []AdminTeamSolve{
+			{Key: "nullByteChallenge", Name: "Poison Null Byte", SolvedAt: "2024-11-01T19:10:00Z"},
+			{Key: "scoreBoardChallenge", Name: "Score Board", SolvedAt: "2024-11-01T19:55:48Z", Evidence: "/#/score-board", IssuerVersion: "19.0.0", IssuerHostName: "juiceshop-foobar-abc"},
+		}, response.Solves)
+	})
+
+	t.Run("returns 404 for unknown teams", func(t *testing.T) {
+		rr := getSolves("barfoo", "admin")
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestAdminGrantAndRevokeSolveHandlers(t *testing.T) {
+	createTeam := func(annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "juiceshop-foobar",
+				Namespace:   "test-namespace",
+				Annotations: annotations,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      "foobar",
+				},
+			},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+		}
+	}
+
+	// newInstance stands in for the JuiceShop of the team and records the continue codes applied to it
+	newInstance := func(t *testing.T, bundle *b.Bundle) *[]string {
+		appliedContinueCodes := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/api/challenges":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"status":"success","data":[{"id":1,"key":"scoreBoardChallenge","solved":true},{"id":2,"key":"nullByteChallenge","solved":false}]}`))
+			case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/rest/continue-code/apply/"):
+				appliedContinueCodes = append(appliedContinueCodes, strings.TrimPrefix(r.URL.Path, "/rest/continue-code/apply/"))
+				w.WriteHeader(http.StatusOK)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+		bundle.GetJuiceShopUrlForTeam = func(team string, bundle *b.Bundle) string {
+			return server.URL
+		}
+		return &appliedContinueCodes
+	}
+
+	editSolve := func(bundle *b.Bundle, method string, team string, challengeKey string, cookieTeam string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, fmt.Sprintf("/multi-juicer/api/admin/teams/%s/solves/%s", team, challengeKey), nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(cookieTeam)))
+		rr := httptest.NewRecorder()
+		server := http.NewServeMux()
+		AddRoutes(server, bundle)
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	getAnnotations := func(t *testing.T, clientset *fake.Clientset) map[string]string {
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		require.NoError(t, err)
+		return deployment.Annotations
+	}
+
+	t.Run("granting a solve persists it, applies it to the instance and records the admin in the event log", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges":        `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z"}]`,
+			"multi-juicer.owasp-juice.shop/revokedChallenges": `["nullByteChallenge"]`,
+		}))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		appliedContinueCodes := newInstance(t, bundle)
+
+		rr := editSolve(bundle, "PUT", "foobar", "nullByteChallenge", "admin")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response AdminTeamSolvesResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		require.Len(t, response.Solves, 2)
+		assert.Equal(t, "nullByteChallenge", response.Solves[1].Key)
+
+		annotations := getAnnotations(t, clientset)
+		challenges, err := progresswatchdog.ParseChallengeStatuses(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}})
+		require.NoError(t, err)
+		require.Len(t, challenges, 2)
+		assert.Equal(t, "2", annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+		assert.NotContains(t, annotations, "multi-juicer.owasp-juice.shop/revokedChallenges")
+		assert.Len(t, *appliedContinueCodes, 1)
+
+		events := bundle.EventLog.GetEvents()
+		require.Len(t, events, 1)
+		assert.Equal(t, b.EventTypeSolveGranted, events[0].Type)
+		assert.Equal(t, "foobar", events[0].Team)
+		assert.Equal(t, "nullByteChallenge", events[0].ChallengeKey)
+		assert.Equal(t, "admin", events[0].Actor)
+	})
+
+	t.Run("granting an already solved challenge changes nothing", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges": `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z"}]`,
+		}))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		appliedContinueCodes := newInstance(t, bundle)
+
+		rr := editSolve(bundle, "PUT", "foobar", "scoreBoardChallenge", "admin")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z"}]`, getAnnotations(t, clientset)["multi-juicer.owasp-juice.shop/challenges"])
+		assert.Empty(t, *appliedContinueCodes)
+		assert.Empty(t, bundle.EventLog.GetEvents())
+	})
+
+	t.Run("revoking a solve removes it and remembers the challenge as revoked", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges": `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z"},{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:10:00Z"}]`,
+		}))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		rr := editSolve(bundle, "DELETE", "foobar", "nullByteChallenge", "admin")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response AdminTeamSolvesResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, []AdminTeamSolve{{Key: "scoreBoardChallenge", Name: "Score Board", SolvedAt: "2024-11-01T19:55:48Z"}}, response.Solves)
+
+		annotations := getAnnotations(t, clientset)
+		assert.Equal(t, `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z"}]`, annotations["multi-juicer.owasp-juice.shop/challenges"])
+		assert.Equal(t, "1", annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+		assert.Equal(t, `["nullByteChallenge"]`, annotations["multi-juicer.owasp-juice.shop/revokedChallenges"])
+
+		events := bundle.EventLog.GetEvents()
+		require.Len(t, events, 1)
+		assert.Equal(t, b.EventTypeSolveRevoked, events[0].Type)
+		assert.Equal(t, "nullByteChallenge", events[0].ChallengeKey)
+		assert.Equal(t, "admin", events[0].Actor)
+	})
+
+	t.Run("keeps solves recorded by the solutions webhook while the revoke is in progress", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges": `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:10:00Z"}]`,
+		}))
+		// the solutions webhook records a solve between the read and the update of the revoke, the update conflicts with it
+		recorded := false
+		clientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if recorded {
+				return false, nil, nil
+			}
+			recorded = true
+			deployment := createTeam(map[string]string{
+				"multi-juicer.owasp-juice.shop/challenges": `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z"},{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:10:00Z"}]`,
+			})
+			require.NoError(t, clientset.Tracker().Update(appsv1.SchemeGroupVersion.WithResource("deployments"), deployment, "test-namespace"))
+			return true, nil, errors.NewConflict(appsv1.Resource("deployments"), "juiceshop-foobar", fmt.Errorf("the object has been modified"))
+		})
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		rr := editSolve(bundle, "DELETE", "foobar", "nullByteChallenge", "admin")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		annotations := getAnnotations(t, clientset)
+		assert.Equal(t, `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z"}]`, annotations["multi-juicer.owasp-juice.shop/challenges"])
+		assert.Equal(t, `["nullByteChallenge"]`, annotations["multi-juicer.owasp-juice.shop/revokedChallenges"])
+	})
+
+	t.Run("returns 404 for unknown teams and challenges", func(t *testing.T) {
+		bundle := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset(createTeam(map[string]string{})))
+
+		assert.Equal(t, http.StatusNotFound, editSolve(bundle, "PUT", "barfoo", "scoreBoardChallenge", "admin").Code)
+		assert.Equal(t, http.StatusNotFound, editSolve(bundle, "DELETE", "foobar", "unknownChallenge", "admin").Code)
+	})
+
+	t.Run("requires admin login", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{}))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		assert.Equal(t, http.StatusUnauthorized, editSolve(bundle, "PUT", "foobar", "nullByteChallenge", "foobar").Code)
+		assert.Equal(t, http.StatusUnauthorized, editSolve(bundle, "DELETE", "foobar", "scoreBoardChallenge", "foobar").Code)
+		assert.NotContains(t, getAnnotations(t, clientset), "multi-juicer.owasp-juice.shop/challenges")
+	})
+}
@@ -29,6 +29,7 @@ type ChallengeDetailResponse struct {
 	Category    string          `json:"category"`
 	Description string          `json:"description"`
 	Difficulty  int             `json:"difficulty"`
+	Points      int             `json:"points"`
 	Solves      ChallengeSolves `json:"solves"`
 }
 
@@ -52,6 +53,7 @@ func handleChallengeDetail(bundle *b.Bundle) http.Handler {
 
 		// 2. Iterate through all teams and their solved challenges to find who solved this one.
 		solves := make(ChallengeSolves, 0)
+		solvedBy := make(map[string]bool)
 		allTeamScores := bundle.ScoringService.GetScores()
 
 		for teamName, teamScore := range allTeamScores {
@@ -61,11 +63,27 @@ func handleChallengeDetail(bundle *b.Bundle) http.Handler {
 						Team:     teamName,
 						SolvedAt: solvedChallenge.SolvedAt,
 					})
+					solvedBy[teamName] = true
 					break // Move to the next team
 				}
 			}
 		}
 
+		// Teams whose instances were deleted are only known to the event log
+		for _, event := range bundle.EventLog.GetEvents() {
+			if _, isLiveTeam := allTeamScores[event.Team]; isLiveTeam {
+				// the solve might have been revoked since
+				continue
+			}
+			if event.Type == b.EventTypeChallengeSolved && event.ChallengeKey == challengeKey && !solvedBy[event.Team] {
+				solves = append(solves, ChallengeSolve{
+					Team:     event.Team,
+					SolvedAt: event.Timestamp,
+				})
+				solvedBy[event.Team] = true
+			}
+		}
+
 		// 3. Sort the solves by time to show "First Solve" first.
 		sort.Sort(solves)
 
@@ -76,6 +94,7 @@ func handleChallengeDetail(bundle *b.Bundle) http.Handler {
 			Category:    targetChallenge.Category,
 			Description: targetChallenge.Description,
 			Difficulty:  targetChallenge.Difficulty,
+			Points:      bundle.ScoringService.GetChallengePoints(targetChallenge.Key),
 			Solves:      solves,
 		}
 
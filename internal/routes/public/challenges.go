@@ -2,32 +2,137 @@ package public
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/teamcookie"
 )
 
 // ChallengeListItem represents a challenge in the list response.
 type ChallengeListItem struct {
-	Key         string  `json:"key"`
-	Name        string  `json:"name"`
-	Category    string  `json:"category"`
-	Description string  `json:"description"`
-	Difficulty  int     `json:"difficulty"`
-	SolveCount  int     `json:"solveCount"`
-	FirstSolver *string `json:"firstSolver"`
+	Key         string   `json:"key"`
+	Name        string   `json:"name"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+	Difficulty  int      `json:"difficulty"`
+	Points      int      `json:"points"`
+	SolveCount  int      `json:"solveCount"`
+	FirstSolver *string  `json:"firstSolver"`
+	// Solved and SolvedAt refer to the logged-in team. Always false / nil for requests without a team cookie.
+	Solved   bool       `json:"solved"`
+	SolvedAt *time.Time `json:"solvedAt,omitempty"`
 }
 
 // ChallengesListResponse is the response payload for the challenges list endpoint.
 type ChallengesListResponse struct {
+	// Team is the logged-in team the solved state refers to. Empty for requests without a team cookie.
+	Team       string              `json:"team,omitempty"`
 	Challenges []ChallengeListItem `json:"challenges"`
 }
 
+// challengeFilter restricts the challenge list. Empty fields don't filter. Multiple values of the same field match if any of them matches.
+type challengeFilter struct {
+	categories   []string
+	difficulties []int
+	tags         []string
+	// solved filters by the solved state of the logged-in team, nil doesn't filter
+	solved *bool
+}
+
+// parseChallengeFilter reads the category, difficulty, tag and solved query parameters.
+// Each parameter can be repeated or contain comma separated values, e.g. ?category=XSS,Injection&difficulty=1&difficulty=2
+func parseChallengeFilter(req *http.Request) (challengeFilter, error) {
+	query := req.URL.Query()
+	filter := challengeFilter{
+		categories: splitQueryValues(query["category"]),
+		tags:       splitQueryValues(query["tag"]),
+	}
+	for _, value := range splitQueryValues(query["difficulty"]) {
+		difficulty, err := strconv.Atoi(value)
+		if err != nil {
+			return challengeFilter{}, fmt.Errorf("invalid difficulty '%s'", value)
+		}
+		filter.difficulties = append(filter.difficulties, difficulty)
+	}
+	if value := query.Get("solved"); value != "" {
+		solved, err := strconv.ParseBool(value)
+		if err != nil {
+			return challengeFilter{}, fmt.Errorf("invalid solved value '%s'", value)
+		}
+		filter.solved = &solved
+	}
+	return filter, nil
+}
+
+func splitQueryValues(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		for part := range strings.SplitSeq(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+func (f challengeFilter) matches(challenge b.JuiceShopChallenge, solved bool) bool {
+	if len(f.categories) > 0 && !slices.ContainsFunc(f.categories, func(category string) bool {
+		return strings.EqualFold(category, challenge.Category)
+	}) {
+		return false
+	}
+	if len(f.difficulties) > 0 && !slices.Contains(f.difficulties, challenge.Difficulty) {
+		return false
+	}
+	if len(f.tags) > 0 && !slices.ContainsFunc(f.tags, func(tag string) bool {
+		return slices.ContainsFunc(challenge.Tags, func(challengeTag string) bool {
+			return strings.EqualFold(tag, challengeTag)
+		})
+	}) {
+		return false
+	}
+	if f.solved != nil && *f.solved != solved {
+		return false
+	}
+	return true
+}
+
 func handleChallenges(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseChallengeFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The solved state is only available for logged-in teams. Requests without a valid cookie still get the global catalog.
+		team, err := teamcookie.GetTeamFromRequest(bundle, r)
+		if err != nil || team == "admin" {
+			team = ""
+		}
+		if filter.solved != nil && team == "" {
+			http.Error(w, "filtering by solved state requires a team", http.StatusBadRequest)
+			return
+		}
+
 		// Get all team scores to calculate solve counts
 		allTeamScores := bundle.ScoringService.GetScores()
 
+		// Solve times of the logged-in team, keyed by challenge key
+		teamSolves := make(map[string]time.Time)
+		if teamScore, ok := allTeamScores[team]; ok && team != "" {
+			for _, solvedChallenge := range teamScore.Challenges {
+				teamSolves[solvedChallenge.Key] = solvedChallenge.SolvedAt
+			}
+		}
+
 		// Create a map to count solves per challenge
 		solveCounts := make(map[string]int)
 
@@ -38,7 +143,11 @@ func handleChallenges(bundle *b.Bundle) http.Handler {
 		}
 		firstSolvers := make(map[string]solveInfo)
 
+		// Disqualified teams are left out, same as in the scoring of the challenges
 		for _, teamScore := range allTeamScores {
+			if teamScore.CheatDecision == b.CheatDecisionDisqualified {
+				continue
+			}
 			for _, solvedChallenge := range teamScore.Challenges {
 				solveCounts[solvedChallenge.Key]++
 
@@ -55,23 +164,41 @@ func handleChallenges(bundle *b.Bundle) http.Handler {
 		// Build the response with all challenges
 		challenges := make([]ChallengeListItem, 0, len(bundle.JuiceShopChallenges))
 		for _, challenge := range bundle.JuiceShopChallenges {
+			solvedAt, solved := teamSolves[challenge.Key]
+			if !filter.matches(challenge, solved) {
+				continue
+			}
+
 			var firstSolver *string
 			if info, found := firstSolvers[challenge.Key]; found {
 				firstSolver = &info.team
 			}
 
-			challenges = append(challenges, ChallengeListItem{
+			tags := challenge.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+
+			item := ChallengeListItem{
 				Key:         challenge.Key,
 				Name:        challenge.Name,
 				Category:    challenge.Category,
+				Tags:        tags,
 				Description: challenge.Description,
 				Difficulty:  challenge.Difficulty,
+				Points:      bundle.ScoringService.GetChallengePoints(challenge.Key),
 				SolveCount:  solveCounts[challenge.Key],
 				FirstSolver: firstSolver,
-			})
+				Solved:      solved,
+			}
+			if solved {
+				item.SolvedAt = &solvedAt
+			}
+			challenges = append(challenges, item)
 		}
 
 		response := ChallengesListResponse{
+			Team:       team,
 			Challenges: challenges,
 		}
 
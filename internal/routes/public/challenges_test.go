@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/scoring"
 	"github.com/juice-shop/multi-juicer/internal/testutil"
 	"github.com/stretchr/testify/assert"
@@ -102,6 +103,8 @@ func TestChallengesHandler(t *testing.T) {
 		assert.NotEmpty(t, challenge1.Name)
 		assert.NotEmpty(t, challenge1.Key)
 		assert.Greater(t, challenge1.Difficulty, 0)
+		assert.Equal(t, 10, challenge1.Points, "static scoring awards difficulty times ten")
+		assert.Equal(t, 40, challenge2.Points, "static scoring awards difficulty times ten")
 	})
 
 	t.Run("should return all challenges with zero solve counts when no teams have solved anything", func(t *testing.T) {
@@ -175,6 +178,35 @@ func TestChallengesHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("should report the effective points with point overrides and category multipliers", func(t *testing.T) {
+		bundle := testutil.NewTestBundleWithCustomFakeClient(fake.NewClientset())
+		bundle.Config.ScoringConfig.PointOverrides = map[string]int{"scoreBoardChallenge": 5}
+		bundle.Config.ScoringConfig.CategoryMultipliers = map[string]float64{"Improper Input Validation": 2}
+		scoringService := scoring.NewScoringService(bundle)
+		err := scoringService.CalculateAndCacheScoreBoard(context.Background())
+		require.NoError(t, err, "Setup: failed to calculate initial scoreboard")
+
+		server := http.NewServeMux()
+		bundle.ScoringService = scoringService
+		AddRoutes(server, bundle)
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/challenges", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response ChallengesListResponse
+		err = json.Unmarshal(rr.Body.Bytes(), &response)
+		require.NoError(t, err)
+
+		points := map[string]int{}
+		for _, challenge := range response.Challenges {
+			points[challenge.Key] = challenge.Points
+		}
+		assert.Equal(t, map[string]int{"scoreBoardChallenge": 5, "nullByteChallenge": 80}, points)
+	})
+
 	t.Run("should correctly identify first solver based on earliest timestamp", func(t *testing.T) {
 		const challengeKey = "scoreBoardChallenge"
 
@@ -226,4 +258,126 @@ func TestChallengesHandler(t *testing.T) {
 		require.NotNil(t, challenge.FirstSolver, "Challenge should have a first solver")
 		assert.Equal(t, "team-charlie", *challenge.FirstSolver, "team-charlie should be first solver (earliest timestamp)")
 	})
+
+	t.Run("should leave disqualified teams out of the solve counts and first solvers", func(t *testing.T) {
+		const challengeKey = "scoreBoardChallenge"
+
+		solveTimeCheater := time.Now().Add(-30 * time.Minute)
+		solveTimeHonest := time.Now().Add(-10 * time.Minute)
+
+		cheater := createTeamWithSolvedChallenges("team-cheater", fmt.Sprintf(`[{"key":"%s","solvedAt":"%s"}]`, challengeKey, solveTimeCheater.Format(time.RFC3339)))
+		cheater.Annotations["multi-juicer.owasp-juice.shop/cheatScores"] = fmt.Sprintf(`[{"totalCheatScore":0.9,"timestamp":"%s"}]`, solveTimeCheater.Format(time.RFC3339))
+
+		clientset := fake.NewClientset(
+			cheater,
+			createTeamWithSolvedChallenges("team-honest", fmt.Sprintf(`[{"key":"%s","solvedAt":"%s"}]`, challengeKey, solveTimeHonest.Format(time.RFC3339))),
+		)
+
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.ScoringConfig.CheatScore = b.CheatScoreConfig{SuspiciousThreshold: 0.5, DisqualifyThreshold: 0.8}
+		scoringService := scoring.NewScoringService(bundle)
+		err := scoringService.CalculateAndCacheScoreBoard(context.Background())
+		require.NoError(t, err, "Setup: failed to calculate initial scoreboard")
+
+		server := http.NewServeMux()
+		bundle.ScoringService = scoringService
+		AddRoutes(server, bundle)
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/challenges", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response ChallengesListResponse
+		err = json.Unmarshal(rr.Body.Bytes(), &response)
+		require.NoError(t, err)
+
+		var challenge *ChallengeListItem
+		for i := range response.Challenges {
+			if response.Challenges[i].Key == challengeKey {
+				challenge = &response.Challenges[i]
+				break
+			}
+		}
+
+		require.NotNil(t, challenge, "Challenge should be in the response")
+		assert.Equal(t, 1, challenge.SolveCount, "the solve of the disqualified team shouldn't be counted")
+		require.NotNil(t, challenge.FirstSolver, "Challenge should have a first solver")
+		assert.Equal(t, "team-honest", *challenge.FirstSolver, "the disqualified team shouldn't be the first solver")
+	})
+
+	t.Run("should include the solved state of the logged-in team and support filters", func(t *testing.T) {
+		solveTime := time.Date(2024, 11, 1, 19, 55, 48, 0, time.UTC)
+		clientset := fake.NewClientset(
+			createTeamWithSolvedChallenges("team-alpha", fmt.Sprintf(`[{"key":"scoreBoardChallenge","solvedAt":"%s"}]`, solveTime.Format(time.RFC3339))),
+			createTeamWithSolvedChallenges("team-bravo", `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:00:00Z"}]`),
+		)
+
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.JuiceShopChallenges[0].Tags = []string{"Tutorial", "Good for Demos"}
+		bundle.JuiceShopChallenges[1].Tags = []string{"Contraption"}
+		scoringService := scoring.NewScoringService(bundle)
+		require.NoError(t, scoringService.CalculateAndCacheScoreBoard(context.Background()))
+
+		server := http.NewServeMux()
+		bundle.ScoringService = scoringService
+		AddRoutes(server, bundle)
+
+		getChallenges := func(query string, team string) (*httptest.ResponseRecorder, ChallengesListResponse) {
+			req, _ := http.NewRequest("GET", "/multi-juicer/api/challenges"+query, nil)
+			if team != "" {
+				req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
+			}
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+			var response ChallengesListResponse
+			if rr.Code == http.StatusOK {
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			}
+			return rr, response
+		}
+		keys := func(response ChallengesListResponse) []string {
+			keys := []string{}
+			for _, challenge := range response.Challenges {
+				keys = append(keys, challenge.Key)
+			}
+			return keys
+		}
+
+		rr, response := getChallenges("", "team-alpha")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "team-alpha", response.Team)
+		require.Len(t, response.Challenges, 2)
+		assert.True(t, response.Challenges[0].Solved)
+		require.NotNil(t, response.Challenges[0].SolvedAt)
+		assert.True(t, solveTime.Equal(*response.Challenges[0].SolvedAt))
+		assert.Equal(t, []string{"Tutorial", "Good for Demos"}, response.Challenges[0].Tags)
+		assert.False(t, response.Challenges[1].Solved, "solves of other teams must not be reported as solved")
+		assert.Nil(t, response.Challenges[1].SolvedAt)
+
+		_, response = getChallenges("?solved=false", "team-alpha")
+		assert.Equal(t, []string{"nullByteChallenge"}, keys(response))
+
+		_, response = getChallenges("?category=improper%20input%20validation", "")
+		assert.Equal(t, []string{"nullByteChallenge"}, keys(response))
+
+		_, response = getChallenges("?difficulty=1,4", "")
+		assert.Equal(t, []string{"scoreBoardChallenge", "nullByteChallenge"}, keys(response))
+
+		_, response = getChallenges("?difficulty=1&difficulty=2", "")
+		assert.Equal(t, []string{"scoreBoardChallenge"}, keys(response))
+
+		_, response = getChallenges("?tag=contraption", "")
+		assert.Equal(t, []string{"nullByteChallenge"}, keys(response))
+
+		_, response = getChallenges("?tag=Tutorial&category=Miscellaneous&solved=true", "team-alpha")
+		assert.Equal(t, []string{"scoreBoardChallenge"}, keys(response))
+
+		rr, _ = getChallenges("?difficulty=hard", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		rr, _ = getChallenges("?solved=false", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "filtering by solved state requires a team cookie")
+	})
 }
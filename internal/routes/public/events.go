@@ -0,0 +1,150 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/longpoll"
+	"github.com/juice-shop/multi-juicer/internal/teamcookie"
+)
+
+// sseTopicTeamStatus is the topic name used by clients. It is mapped to the team specific broker topic of the requested team.
+const sseTopicTeamStatus = "team-status"
+
+// sseKeepAliveInterval is the interval in which comments are sent to keep idle connections from being closed by proxies
+const sseKeepAliveInterval = 25 * time.Second
+
+// handleEvents streams changes of the score-board, activity feed, notifications and team status as server-sent events.
+// Clients pick the topics via the "topics" query parameter, e.g. ?topics=score-board,notifications. Defaults to score-board, activity-feed and notifications.
+// The "team-status" topic streams the status of the team given in the "team" query parameter, or the logged-in team if it's omitted.
+// Every topic is sent once right after connecting and again whenever it changes. The data of each event has the same format as the response of the matching long polling endpoint.
+func handleEvents(bundle *b.Bundle) http.Handler {
+	challengesByKeys := getChallengesByKeys(bundle)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedTopics := []string{longpoll.TopicScoreBoard, longpoll.TopicActivityFeed, longpoll.TopicNotifications}
+		if topicsParam := r.URL.Query().Get("topics"); topicsParam != "" {
+			requestedTopics = strings.Split(topicsParam, ",")
+		}
+
+		// maps the broker topic to the name of the event sent to the client
+		eventNames := map[string]string{}
+		team := ""
+		for _, topic := range requestedTopics {
+			switch topic {
+			case longpoll.TopicScoreBoard, longpoll.TopicActivityFeed, longpoll.TopicNotifications:
+				eventNames[topic] = topic
+			case sseTopicTeamStatus:
+				team = r.URL.Query().Get("team")
+				if team == "" {
+					var err error
+					team, err = teamcookie.GetTeamFromRequest(bundle, r)
+					if err != nil || team == "admin" {
+						http.Error(w, "team-status requires a team", http.StatusBadRequest)
+						return
+					}
+				} else if !isValidTeamName(team) {
+					http.Error(w, "invalid team name", http.StatusBadRequest)
+					return
+				}
+				eventNames[longpoll.TopicTeamStatus(team)] = sseTopicTeamStatus
+			default:
+				http.Error(w, fmt.Sprintf("unknown topic '%s'", topic), http.StatusBadRequest)
+				return
+			}
+		}
+
+		topics := make([]string, 0, len(eventNames))
+		for topic := range eventNames {
+			topics = append(topics, topic)
+		}
+		subscription := bundle.Broker.Subscribe(topics...)
+		defer subscription.Close()
+
+		responseController := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// disable response buffering in nginx based ingress controllers
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		sendTopics := func(topics []string) error {
+			for _, topic := range topics {
+				data, ok, err := fetchEventData(r.Context(), bundle, challengesByKeys, topic, team)
+				if err != nil {
+					bundle.Log.Error("Failed to fetch data for server-sent event", "topic", topic, "error", err)
+					continue
+				}
+				if !ok {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventNames[topic], data); err != nil {
+					return err
+				}
+			}
+			return responseController.Flush()
+		}
+
+		if err := sendTopics(topics); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(sseKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-subscription.Changes():
+				if err := sendTopics(subscription.Drain()); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				if err := responseController.Flush(); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+// fetchEventData returns the current state of the topic encoded as json. Returns false if there is nothing to send, e.g. because the team doesn't exist (anymore).
+func fetchEventData(ctx context.Context, bundle *b.Bundle, challengesByKeys map[string]b.JuiceShopChallenge, topic string, team string) ([]byte, bool, error) {
+	var payload any
+	switch topic {
+	case longpoll.TopicScoreBoard:
+		totalTeams, _ := bundle.ScoringService.GetTopScoresWithTimestamp()
+		payload = buildScoreBoardResponse(totalTeams)
+	case longpoll.TopicActivityFeed:
+		totalTeams, _ := bundle.ScoringService.GetTopScoresWithTimestamp()
+		activityFeed, err := fetchActivityFeed(ctx, bundle, totalTeams)
+		if err != nil {
+			return nil, false, err
+		}
+		payload = activityFeed
+	case longpoll.TopicNotifications:
+		payload = buildNotificationResponse(bundle.NotificationService.GetNotificationWithTimestamp())
+	default:
+		teamScore, ok := bundle.ScoringService.GetScoreForTeam(team)
+		if !ok {
+			return nil, false, nil
+		}
+		payload = buildTeamStatus(bundle, challengesByKeys, team, teamScore)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
@@ -0,0 +1,157 @@
+package public
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type serverSentEvent struct {
+	Name string
+	Data string
+}
+
+// readServerSentEvents parses the event stream and sends every event to the returned channel until the stream is closed
+func readServerSentEvents(t *testing.T, response *http.Response) <-chan serverSentEvent {
+	events := make(chan serverSentEvent)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(response.Body)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		event := serverSentEvent{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.Name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.Data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event.Name != "":
+				events <- event
+				event = serverSentEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+func nextServerSentEvent(t *testing.T, events <-chan serverSentEvent) serverSentEvent {
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "event stream closed unexpectedly")
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for server-sent event")
+		return serverSentEvent{}
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	createTeam := func(team string, challenges string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					"multi-juicer.owasp-juice.shop/challenges": challenges,
+				},
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+			Status: appsv1.DeploymentStatus{
+				ReadyReplicas: 1,
+			},
+		}
+	}
+
+	t.Run("sends the current state and pushes score changes", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam("foobar", `[]`))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		scoringService := scoring.NewScoringService(bundle)
+		require.NoError(t, scoringService.CalculateAndCacheScoreBoard(context.Background()))
+		bundle.ScoringService = scoringService
+		go scoringService.StartingScoringWorker(t.Context())
+
+		server := http.NewServeMux()
+		AddRoutes(server, bundle)
+		httpServer := httptest.NewServer(server)
+		defer httpServer.Close()
+
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", httpServer.URL+"/multi-juicer/api/events?topics=score-board,team-status&team=foobar", nil)
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+		events := readServerSentEvents(t, response)
+		initialEvents := map[string]string{}
+		for range 2 {
+			event := nextServerSentEvent(t, events)
+			initialEvents[event.Name] = event.Data
+		}
+		var scoreBoard ScoreBoardResponse
+		require.NoError(t, json.Unmarshal([]byte(initialEvents["score-board"]), &scoreBoard))
+		assert.Equal(t, 0, scoreBoard.TopTeams[0].Score)
+		var teamStatus TeamStatus
+		require.NoError(t, json.Unmarshal([]byte(initialEvents["team-status"]), &teamStatus))
+		assert.Equal(t, "foobar", teamStatus.Name)
+
+		// give the scoring watcher time to start before triggering an update
+		time.Sleep(100 * time.Millisecond)
+		_, err = clientset.AppsV1().Deployments("test-namespace").Update(context.Background(), createTeam("foobar", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`), metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		updatedEvents := map[string]string{}
+		for range 2 {
+			event := nextServerSentEvent(t, events)
+			updatedEvents[event.Name] = event.Data
+		}
+		require.NoError(t, json.Unmarshal([]byte(updatedEvents["score-board"]), &scoreBoard))
+		assert.Equal(t, 10, scoreBoard.TopTeams[0].Score)
+		require.NoError(t, json.Unmarshal([]byte(updatedEvents["team-status"]), &teamStatus))
+		assert.Equal(t, 10, teamStatus.Score)
+		require.Len(t, teamStatus.SolvedChallenges, 1)
+	})
+
+	t.Run("rejects unknown topics", func(t *testing.T) {
+		bundle := testutil.NewTestBundle()
+		server := http.NewServeMux()
+		AddRoutes(server, bundle)
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/events?topics=score-board,secrets", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("requires a team for the team-status topic", func(t *testing.T) {
+		bundle := testutil.NewTestBundle()
+		server := http.NewServeMux()
+		AddRoutes(server, bundle)
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/events?topics=team-status", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,152 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/progresswatchdog"
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	"github.com/juice-shop/multi-juicer/internal/teamcookie"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const hintUnlockMaxRetries = 3
+
+// errChallengeAlreadySolved is returned for hint unlocks of challenges the team has solved already, it would only cost the team points
+var errChallengeAlreadySolved = stderrors.New("challenge already solved")
+
+type UnlockHintResponse struct {
+	ChallengeKey string `json:"challengeKey"`
+	Hint         string `json:"hint"`
+	HintUrl      string `json:"hintUrl"`
+	Cost         int    `json:"cost"`
+	// AlreadyUnlocked is true if the team had already paid for this hint earlier. No additional points were subtracted.
+	AlreadyUnlocked bool `json:"alreadyUnlocked"`
+}
+
+func handleUnlockHint(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team, err := teamcookie.GetTeamFromRequest(bundle, req)
+			if err != nil {
+				http.Error(responseWriter, "", http.StatusUnauthorized)
+				return
+			}
+			if team == "admin" {
+				http.Error(responseWriter, "admins can't unlock hints", http.StatusBadRequest)
+				return
+			}
+
+			challengeKey := req.PathValue("challengeKey")
+			var challenge *b.JuiceShopChallenge
+			for i := range bundle.JuiceShopChallenges {
+				if bundle.JuiceShopChallenges[i].Key == challengeKey {
+					challenge = &bundle.JuiceShopChallenges[i]
+					break
+				}
+			}
+			if challenge == nil {
+				http.Error(responseWriter, "challenge not found", http.StatusNotFound)
+				return
+			}
+			if challenge.Hint == "" && challenge.HintUrl == "" {
+				http.Error(responseWriter, "challenge has no hint", http.StatusNotFound)
+				return
+			}
+
+			unlock, alreadyUnlocked, err := unlockHintForTeam(req.Context(), bundle, team, challenge.Key)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					http.Error(responseWriter, "team not found", http.StatusNotFound)
+					return
+				}
+				if stderrors.Is(err, errChallengeAlreadySolved) {
+					http.Error(responseWriter, "challenge already solved", http.StatusConflict)
+					return
+				}
+				bundle.Log.Error("Failed to unlock hint", "team", team, "challenge", challenge.Key, "error", err)
+				http.Error(responseWriter, "failed to unlock hint", http.StatusInternalServerError)
+				return
+			}
+
+			if !alreadyUnlocked {
+				bundle.Log.Info("Team unlocked hint", "team", team, "challenge", challenge.Key, "cost", unlock.Cost)
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			json.NewEncoder(responseWriter).Encode(UnlockHintResponse{
+				ChallengeKey:    challenge.Key,
+				Hint:            challenge.Hint,
+				HintUrl:         challenge.HintUrl,
+				Cost:            unlock.Cost,
+				AlreadyUnlocked: alreadyUnlocked,
+			})
+		},
+	)
+}
+
+// unlockHintForTeam records the hint unlock in the hints annotation of the team's deployment.
+// Uses optimistic concurrency (read resourceVersion, retry on conflict) so that concurrent unlocks from multiple replicas don't overwrite each other.
+// Unlocking the same hint twice is idempotent and doesn't charge the team again.
+func unlockHintForTeam(ctx context.Context, bundle *b.Bundle, team string, challengeKey string) (b.HintUnlock, bool, error) {
+	for attempt := range hintUnlockMaxRetries {
+		deployment, err := getDeployment(ctx, bundle, team)
+		if err != nil {
+			return b.HintUnlock{}, false, err
+		}
+
+		hints, err := scoring.ParseHintUnlocks(deployment)
+		if err != nil {
+			return b.HintUnlock{}, false, err
+		}
+
+		for _, hint := range hints {
+			if hint.Key == challengeKey {
+				return hint, true, nil
+			}
+		}
+
+		challenges, err := progresswatchdog.ParseChallengeStatuses(deployment)
+		if err != nil {
+			return b.HintUnlock{}, false, err
+		}
+		for _, challenge := range challenges {
+			if challenge.Key == challengeKey {
+				return b.HintUnlock{}, false, errChallengeAlreadySolved
+			}
+		}
+
+		unlock := b.HintUnlock{
+			Key:        challengeKey,
+			UnlockedAt: time.Now().UTC(),
+			Cost:       bundle.Config.ScoringConfig.HintCost,
+		}
+		encodedHints, err := json.Marshal(append(hints, unlock))
+		if err != nil {
+			return b.HintUnlock{}, false, fmt.Errorf("failed to encode hints annotation: %w", err)
+		}
+
+		updatedAnnotations := make(map[string]string, len(deployment.Annotations)+1)
+		maps.Copy(updatedAnnotations, deployment.Annotations)
+		updatedAnnotations["multi-juicer.owasp-juice.shop/hints"] = string(encodedHints)
+		deployment.Annotations = updatedAnnotations
+
+		_, err = bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+		if err == nil {
+			return unlock, false, nil
+		}
+		if !errors.IsConflict(err) {
+			return b.HintUnlock{}, false, fmt.Errorf("failed to update deployment: %w", err)
+		}
+		bundle.Log.Warn("Hint unlock conflict, retrying", "team", team, "attempt", attempt+1, "maxRetries", hintUnlockMaxRetries)
+	}
+	return b.HintUnlock{}, false, fmt.Errorf("failed to update deployment after %d retries due to conflicts", hintUnlockMaxRetries)
+}
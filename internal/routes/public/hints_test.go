@@ -0,0 +1,149 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUnlockHintHandler(t *testing.T) {
+	team := "foobar"
+
+	createTeam := func(annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("juiceshop-%s", team),
+				Namespace:   "test-namespace",
+				Annotations: annotations,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+		}
+	}
+
+	newBundle := func(clientset *fake.Clientset) *b.Bundle {
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.ScoringConfig.HintCost = 5
+		bundle.JuiceShopChallenges[1].Hint = "Null bytes can end strings early."
+		bundle.JuiceShopChallenges[1].HintUrl = "https://pwning.owasp-juice.shop/"
+		return bundle
+	}
+
+	unlockHint := func(bundle *b.Bundle, challengeKey string, cookie string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/multi-juicer/api/teams/hints/%s", challengeKey), nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", fmt.Sprintf("team=%s", cookie))
+		}
+		rr := httptest.NewRecorder()
+		server := http.NewServeMux()
+		AddRoutes(server, bundle)
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("reveals the hint and persists the unlock with its cost on the deployment", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges": `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`,
+		}))
+		bundle := newBundle(clientset)
+
+		rr := unlockHint(bundle, "nullByteChallenge", testutil.SignTestTeamname(team))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response UnlockHintResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, UnlockHintResponse{
+			ChallengeKey:    "nullByteChallenge",
+			Hint:            "Null bytes can end strings early.",
+			HintUrl:         "https://pwning.owasp-juice.shop/",
+			Cost:            5,
+			AlreadyUnlocked: false,
+		}, response)
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		require.NoError(t, err)
+		hints, err := scoring.ParseHintUnlocks(deployment)
+		require.NoError(t, err)
+		require.Len(t, hints, 1)
+		assert.Equal(t, "nullByteChallenge", hints[0].Key)
+		assert.Equal(t, 5, hints[0].Cost)
+
+		// wait for the informer cache to pick up the update before calculating the scores from it
+		testutil.WaitForJuiceShopDeployment(bundle, team, func(deployment *appsv1.Deployment) bool {
+			return deployment.Annotations["multi-juicer.owasp-juice.shop/hints"] != ""
+		})
+		scoringService := scoring.NewScoringService(bundle)
+		require.NoError(t, scoringService.CalculateAndCacheScoreBoard(context.Background()))
+		score, ok := scoringService.GetScoreForTeam(team)
+		require.True(t, ok)
+		assert.Equal(t, 5, score.Score, "hint cost should be subtracted from the score")
+	})
+
+	t.Run("unlocking the same hint again doesn't charge the team twice", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/hints": `[{"key":"nullByteChallenge","unlockedAt":"2024-11-01T19:55:48.211Z","cost":3}]`,
+		}))
+		bundle := newBundle(clientset)
+
+		rr := unlockHint(bundle, "nullByteChallenge", testutil.SignTestTeamname(team))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response UnlockHintResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.True(t, response.AlreadyUnlocked)
+		assert.Equal(t, 3, response.Cost)
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		require.NoError(t, err)
+		hints, err := scoring.ParseHintUnlocks(deployment)
+		require.NoError(t, err)
+		assert.Len(t, hints, 1)
+	})
+
+	t.Run("rejects unlocking the hint of a challenge the team has solved already", func(t *testing.T) {
+		clientset := fake.NewClientset(createTeam(map[string]string{
+			"multi-juicer.owasp-juice.shop/challenges": `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`,
+		}))
+		bundle := newBundle(clientset)
+
+		rr := unlockHint(bundle, "nullByteChallenge", testutil.SignTestTeamname(team))
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.NotContains(t, deployment.Annotations, "multi-juicer.owasp-juice.shop/hints")
+	})
+
+	t.Run("returns 404 for challenges without hints", func(t *testing.T) {
+		bundle := newBundle(fake.NewClientset(createTeam(map[string]string{})))
+
+		rr := unlockHint(bundle, "scoreBoardChallenge", testutil.SignTestTeamname(team))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		rr = unlockHint(bundle, "unknownChallenge", testutil.SignTestTeamname(team))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("requires a team cookie", func(t *testing.T) {
+		bundle := newBundle(fake.NewClientset(createTeam(map[string]string{})))
+
+		rr := unlockHint(bundle, "nullByteChallenge", "")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -3,21 +3,19 @@ package public
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
-	"slices"
-	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/labels"
 
-	"github.com/juice-shop/multi-juicer/internal/bundle"
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/instances"
 	"github.com/juice-shop/multi-juicer/internal/signutil"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -43,7 +41,7 @@ func init() {
 	prometheus.MustRegister(failedLoginCounter)
 }
 
-func handleTeamJoin(bundle *bundle.Bundle) http.Handler {
+func handleTeamJoin(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		team := r.PathValue("team")
 
@@ -73,7 +71,7 @@ func handleTeamJoin(bundle *bundle.Bundle) http.Handler {
 	})
 }
 
-func handleAdminLogin(bundle *bundle.Bundle, w http.ResponseWriter, r *http.Request) {
+func handleAdminLogin(bundle *b.Bundle, w http.ResponseWriter, r *http.Request) {
 	if r.Body == nil {
 		writeUnauthorizedResponse(w)
 		return
@@ -108,10 +106,10 @@ func handleAdminLogin(bundle *bundle.Bundle, w http.ResponseWriter, r *http.Requ
 	loginCounter.WithLabelValues("login", "admin").Inc()
 }
 
-func getDeployment(context context.Context, bundle *bundle.Bundle, team string) (*appsv1.Deployment, error) {
+func getDeployment(context context.Context, bundle *b.Bundle, team string) (*appsv1.Deployment, error) {
 	return bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(
 		context,
-		fmt.Sprintf("juiceshop-%s", team),
+		bundle.JuiceShopDeploymentName(team),
 		metav1.GetOptions{},
 	)
 }
@@ -124,17 +122,15 @@ func isValidTeamName(s string) bool {
 	return matched && len(s) <= 16
 }
 
-func isMaxInstanceLimitReached(context context.Context, bundle *bundle.Bundle) (bool, error) {
-	deployments, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).List(context, metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-	})
+func isMaxInstanceLimitReached(context context.Context, bundle *b.Bundle) (bool, error) {
+	deployments, err := bundle.JuiceShopLister.List(labels.Everything())
 	if err != nil {
 		return false, fmt.Errorf("failed to list deployments: %w", err)
 	}
-	return len(deployments.Items)+1 >= bundle.Config.MaxInstances, nil
+	return len(deployments)+1 >= bundle.Config.MaxInstances, nil
 }
 
-func createANewTeam(context context.Context, bundle *bundle.Bundle, team string, w http.ResponseWriter) {
+func createANewTeam(context context.Context, bundle *b.Bundle, team string, w http.ResponseWriter) {
 	if !isValidTeamName(team) {
 		http.Error(w, "invalid team name", http.StatusBadRequest)
 		return
@@ -147,26 +143,13 @@ func createANewTeam(context context.Context, bundle *bundle.Bundle, team string,
 		return
 	}
 
-	deployment, err := createDeploymentForTeam(context, bundle, team, passcodeHash)
-	if err != nil {
-		bundle.Log.Error("Failed to create deployment", "team", team, "error", err)
-		http.Error(w, "failed to create deployment", http.StatusInternalServerError)
-		return
-	}
-
-	if bundle.Config.JuiceShopConfig.LLM.Enabled {
-		err = createLLMTokenSecretForTeam(context, bundle, team, deployment)
-		if err != nil {
-			bundle.Log.Error("Failed to create LLM token secret", "team", team, "error", err)
-			http.Error(w, "failed to create LLM token secret", http.StatusInternalServerError)
-			return
-		}
+	_, err = instances.Claim(context, bundle, team, passcodeHash)
+	if stderrors.Is(err, instances.ErrWarmPoolEmpty) {
+		_, err = instances.Provision(context, bundle, team, passcodeHash)
 	}
-
-	err = createServiceForTeam(context, bundle, team, deployment)
 	if err != nil {
-		bundle.Log.Error("Failed to create service", "team", team, "error", err)
-		http.Error(w, "failed to create service", http.StatusInternalServerError)
+		bundle.Log.Error("Failed to create instance", "team", team, "error", err)
+		http.Error(w, "failed to create instance", http.StatusInternalServerError)
 		return
 	}
 
@@ -176,11 +159,15 @@ func createANewTeam(context context.Context, bundle *bundle.Bundle, team string,
 		return
 	}
 
+	if err := bundle.EventLog.Append(context, b.Event{Type: b.EventTypeTeamCreated, Team: team}); err != nil {
+		bundle.Log.Error("Failed to record team creation in event log", "team", team, "error", err)
+	}
+
 	sendSuccessResponse(w, "Created Instance", passcode)
 	loginCounter.WithLabelValues("registration", "user").Inc()
 }
 
-func generatePasscode(bundle *bundle.Bundle) (string, string, error) {
+func generatePasscode(bundle *b.Bundle) (string, string, error) {
 	passcode := bundle.GeneratePasscode()
 	hashBytes, err := bcrypt.GenerateFromPassword([]byte(passcode), bundle.BcryptRounds)
 	if err != nil {
@@ -189,7 +176,7 @@ func generatePasscode(bundle *bundle.Bundle) (string, string, error) {
 	return passcode, string(hashBytes), nil
 }
 
-func setSignedTeamCookie(bundle *bundle.Bundle, team string, w http.ResponseWriter) error {
+func setSignedTeamCookie(bundle *b.Bundle, team string, w http.ResponseWriter) error {
 	cookieValue, err := signutil.Sign(team, bundle.Config.CookieConfig.SigningKey)
 	if err != nil {
 		return err
@@ -220,7 +207,7 @@ type joinRequestBody struct {
 	Passcode string `json:"passcode"`
 }
 
-func joinExistingTeam(bundle *bundle.Bundle, team string, deployment *appsv1.Deployment, w http.ResponseWriter, r *http.Request) {
+func joinExistingTeam(bundle *b.Bundle, team string, deployment *appsv1.Deployment, w http.ResponseWriter, r *http.Request) {
 	passCodeHashToMatch := deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"]
 	if passCodeHashToMatch == "" {
 		http.Error(w, "failed to get passcode", http.StatusInternalServerError)
@@ -273,275 +260,3 @@ func writeUnauthorizedResponse(responseWriter http.ResponseWriter) {
 	responseWriter.Header().Set("Content-Type", "application/json")
 	responseWriter.Write(errorResponseBody) // nosemgrep: go.lang.security.audit.xss.no-direct-write-to-responsewriter.no-direct-write-to-responsewriter
 }
-
-func getDeploymentOwnerReferences(deployment *appsv1.Deployment) []metav1.OwnerReference {
-	truePointer := true
-	return []metav1.OwnerReference{
-		{
-			APIVersion:         "apps/v1",
-			Kind:               "Deployment",
-			Name:               deployment.Name,
-			UID:                deployment.UID,
-			Controller:         &truePointer,
-			BlockOwnerDeletion: &truePointer,
-		},
-	}
-}
-
-// uid of the multi-juicer kubernetes deployment resource. used to "attach" created juice shop deployments to it so that they get deleted when multi-juicer gets deleted
-var deploymentUid types.UID
-
-func getOwnerReferences(context context.Context, bundle *bundle.Bundle) ([]metav1.OwnerReference, error) {
-	if deploymentUid == "" {
-		multiJuicerDeployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(
-			context,
-			"multi-juicer",
-			metav1.GetOptions{},
-		)
-		deploymentUid = multiJuicerDeployment.ObjectMeta.UID
-		if err != nil {
-			return nil, fmt.Errorf("failed to get multi-juicer deployment to attach correct owner reference to start juice shop: %w", err)
-		}
-	}
-
-	truePointer := true
-	ownerReferences := []metav1.OwnerReference{
-		{
-			APIVersion:         "apps/v1",
-			Kind:               "Deployment",
-			Name:               "multi-juicer",
-			UID:                deploymentUid,
-			Controller:         &truePointer,
-			BlockOwnerDeletion: &truePointer,
-		},
-	}
-	return ownerReferences, nil
-}
-
-func createDeploymentForTeam(context context.Context, bundle *bundle.Bundle, team string, passcodeHash string) (*appsv1.Deployment, error) {
-	ownerReferences, err := getOwnerReferences(context, bundle)
-	if err != nil {
-		return nil, err
-	}
-
-	podLabels := map[string]string{}
-	if bundle.Config.JuiceShopConfig.JuiceShopPodConfig.Labels != nil {
-		podLabels = bundle.Config.JuiceShopConfig.JuiceShopPodConfig.Labels
-	}
-	podLabels["team"] = team
-	podLabels["app.kubernetes.io/version"] = bundle.Config.JuiceShopConfig.Tag
-	podLabels["app.kubernetes.io/name"] = "juice-shop"
-	podLabels["app.kubernetes.io/part-of"] = "multi-juicer"
-
-	podAnnotations := map[string]string{}
-	if bundle.Config.JuiceShopConfig.JuiceShopPodConfig.Annotations != nil {
-		podAnnotations = bundle.Config.JuiceShopConfig.JuiceShopPodConfig.Annotations
-	}
-
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("juiceshop-%s", team),
-			Labels: map[string]string{
-				"team":                        team,
-				"app.kubernetes.io/version":   bundle.Config.JuiceShopConfig.Tag,
-				"app.kubernetes.io/component": "vulnerable-app",
-				"app.kubernetes.io/name":      "juice-shop",
-				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
-				"app.kubernetes.io/part-of":   "multi-juicer",
-			},
-			Annotations: map[string]string{
-				"multi-juicer.owasp-juice.shop/lastRequest":         fmt.Sprintf("%d", time.Now().UnixMilli()),
-				"multi-juicer.owasp-juice.shop/lastRequestReadable": time.Now().String(),
-				"multi-juicer.owasp-juice.shop/passcode":            passcodeHash,
-				"multi-juicer.owasp-juice.shop/challengesSolved":    "0",
-				"multi-juicer.owasp-juice.shop/challenges":          "[]",
-			},
-			OwnerReferences: ownerReferences,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"team":                      team,
-					"app.kubernetes.io/name":    "juice-shop",
-					"app.kubernetes.io/part-of": "multi-juicer",
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels:      podLabels,
-					Annotations: podAnnotations,
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:            "juice-shop",
-							Image:           fmt.Sprintf("%s:%s", bundle.Config.JuiceShopConfig.Image, bundle.Config.JuiceShopConfig.Tag),
-							SecurityContext: &bundle.Config.JuiceShopConfig.ContainerSecurityContext,
-							Resources:       bundle.Config.JuiceShopConfig.Resources,
-							Ports: []corev1.ContainerPort{
-								{
-									ContainerPort: 3000,
-								},
-							},
-							StartupProbe: &corev1.Probe{
-								ProbeHandler: corev1.ProbeHandler{
-									HTTPGet: &corev1.HTTPGetAction{
-										Path: "/rest/admin/application-version",
-										Port: intstr.FromInt(3000),
-									},
-								},
-								PeriodSeconds:    2,
-								FailureThreshold: 150,
-							},
-							ReadinessProbe: &corev1.Probe{
-								ProbeHandler: corev1.ProbeHandler{
-									HTTPGet: &corev1.HTTPGetAction{
-										Path: "/rest/admin/application-version",
-										Port: intstr.FromInt(3000),
-									},
-								},
-								PeriodSeconds:    5,
-								FailureThreshold: 3,
-							},
-							LivenessProbe: &corev1.Probe{
-								ProbeHandler: corev1.ProbeHandler{
-									HTTPGet: &corev1.HTTPGetAction{
-										Path: "/rest/admin/application-version",
-										Port: intstr.FromInt(3000),
-									},
-								},
-								InitialDelaySeconds: 30,
-								PeriodSeconds:       15,
-							},
-							Env:     buildJuiceShopEnv(bundle, team),
-							EnvFrom: bundle.Config.JuiceShopConfig.EnvFrom,
-							VolumeMounts: append(
-								bundle.Config.JuiceShopConfig.VolumeMounts,
-								corev1.VolumeMount{
-									Name:      "juice-shop-config",
-									MountPath: "/juice-shop/config/multi-juicer.yaml",
-									ReadOnly:  true,
-									SubPath:   "multi-juicer.yaml",
-								},
-							),
-						},
-					},
-					Volumes: append(
-						bundle.Config.JuiceShopConfig.Volumes,
-						corev1.Volume{
-							Name: "juice-shop-config",
-							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{
-										Name: "juice-shop-config",
-									},
-								},
-							},
-						},
-					),
-					ImagePullSecrets: bundle.Config.JuiceShopConfig.ImagePullSecrets,
-					Tolerations:      bundle.Config.JuiceShopConfig.Tolerations,
-					Affinity:         &bundle.Config.JuiceShopConfig.Affinity,
-					RuntimeClassName: bundle.Config.JuiceShopConfig.RuntimeClassName,
-					SecurityContext:  &bundle.Config.JuiceShopConfig.PodSecurityContext,
-				},
-			},
-		},
-	}
-
-	created, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Create(context, deployment, metav1.CreateOptions{})
-	return created, err
-}
-
-func createServiceForTeam(context context.Context, bundle *bundle.Bundle, team string, ownerDeployment *appsv1.Deployment) error {
-	service := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("juiceshop-%s", team),
-			Labels: map[string]string{
-				"team":                        team,
-				"app.kubernetes.io/version":   bundle.Config.JuiceShopConfig.Tag,
-				"app.kubernetes.io/name":      "juice-shop",
-				"app.kubernetes.io/component": "vulnerable-app",
-				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
-				"app.kubernetes.io/part-of":   "multi-juicer",
-			},
-			OwnerReferences: getDeploymentOwnerReferences(ownerDeployment),
-		},
-		Spec: corev1.ServiceSpec{
-			Selector: map[string]string{
-				"team":                   team,
-				"app.kubernetes.io/name": "juice-shop",
-			},
-			Ports: []corev1.ServicePort{
-				{
-					Port: 3000,
-				},
-			},
-		},
-	}
-
-	_, err := bundle.ClientSet.CoreV1().Services(bundle.RuntimeEnvironment.Namespace).Create(context, service, metav1.CreateOptions{})
-	return err
-}
-
-func buildJuiceShopEnv(bundle *bundle.Bundle, team string) []corev1.EnvVar {
-	envVars := append(
-		slices.Clone(bundle.Config.JuiceShopConfig.Env),
-		corev1.EnvVar{
-			Name:  "NODE_ENV",
-			Value: bundle.Config.JuiceShopConfig.NodeEnv,
-		},
-		corev1.EnvVar{
-			Name:  "CTF_KEY",
-			Value: bundle.Config.JuiceShopConfig.CtfKey,
-		},
-		corev1.EnvVar{
-			Name:  "SOLUTIONS_WEBHOOK",
-			Value: fmt.Sprintf("http://multijuicer-private.%s.svc.cluster.local/team/%s/webhook", bundle.RuntimeEnvironment.Namespace, team),
-		},
-	)
-
-	if bundle.Config.JuiceShopConfig.LLM.Enabled {
-		envVars = append(envVars, corev1.EnvVar{
-			Name: "LLM_API_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: fmt.Sprintf("juiceshop-%s", team),
-					},
-					Key: "token",
-				},
-			},
-		})
-	}
-
-	return envVars
-}
-
-func createLLMTokenSecretForTeam(ctx context.Context, bundle *bundle.Bundle, team string, ownerDeployment *appsv1.Deployment) error {
-	token, err := signutil.Sign(team, bundle.Config.CookieConfig.SigningKey)
-	if err != nil {
-		return fmt.Errorf("failed to sign LLM token: %w", err)
-	}
-
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("juiceshop-%s", team),
-			Labels: map[string]string{
-				"team":                        team,
-				"app.kubernetes.io/component": "llm-token",
-				"app.kubernetes.io/part-of":   "multi-juicer",
-			},
-			OwnerReferences: getDeploymentOwnerReferences(ownerDeployment),
-		},
-		Data: map[string][]byte{
-			"token": []byte(token),
-		},
-	}
-
-	_, err = bundle.ClientSet.CoreV1().Secrets(bundle.RuntimeEnvironment.Namespace).Create(ctx, secret, metav1.CreateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to create LLM token secret: %w", err)
-	}
-	return nil
-}
@@ -29,38 +29,12 @@ func handleNotifications(b *bundle.Bundle) http.Handler {
 					// Timeout, no updates
 					return nil, time.Time{}, false, nil
 				}
-				if notification == nil {
-					return &NotificationResponse{
-						Message:   "",
-						Enabled:   false,
-						UpdatedAt: lastUpdateTime,
-					}, lastUpdateTime, true, nil
-				}
-				return &NotificationResponse{
-					Message:               notification.Message,
-					Enabled:               notification.Enabled,
-					UpdatedAt:             lastUpdateTime,
-					EndDate:               notification.EndDate,
-					FreezeScoreboardOnEnd: notification.FreezeScoreboardOnEnd,
-				}, lastUpdateTime, true, nil
+				return buildNotificationResponse(notification, lastUpdateTime), lastUpdateTime, true, nil
 			}
 
 			// Initial fetch: return current notification immediately
 			notification, lastUpdateTime := b.NotificationService.GetNotificationWithTimestamp()
-			if notification == nil {
-				return &NotificationResponse{
-					Message:   "",
-					Enabled:   false,
-					UpdatedAt: lastUpdateTime,
-				}, lastUpdateTime, true, nil
-			}
-			return &NotificationResponse{
-				Message:               notification.Message,
-				Enabled:               notification.Enabled,
-				UpdatedAt:             lastUpdateTime,
-				EndDate:               notification.EndDate,
-				FreezeScoreboardOnEnd: notification.FreezeScoreboardOnEnd,
-			}, lastUpdateTime, true, nil
+			return buildNotificationResponse(notification, lastUpdateTime), lastUpdateTime, true, nil
 		}
 
 		response, lastUpdateTime, statusCode, err := longpoll.HandleLongPoll(r, fetchFunc)
@@ -89,3 +63,20 @@ func handleNotifications(b *bundle.Bundle) http.Handler {
 		w.Write(responseBytes) // nosemgrep: go.lang.security.audit.xss.no-direct-write-to-responsewriter.no-direct-write-to-responsewriter
 	})
 }
+
+func buildNotificationResponse(notification *bundle.Notification, lastUpdateTime time.Time) *NotificationResponse {
+	if notification == nil {
+		return &NotificationResponse{
+			Message:   "",
+			Enabled:   false,
+			UpdatedAt: lastUpdateTime,
+		}
+	}
+	return &NotificationResponse{
+		Message:               notification.Message,
+		Enabled:               notification.Enabled,
+		UpdatedAt:             lastUpdateTime,
+		EndDate:               notification.EndDate,
+		FreezeScoreboardOnEnd: notification.FreezeScoreboardOnEnd,
+	}
+}
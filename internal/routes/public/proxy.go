@@ -11,7 +11,8 @@ import (
 	"sync"
 	"time"
 
-	"github.com/juice-shop/multi-juicer/internal/bundle"
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/instances"
 	"github.com/juice-shop/multi-juicer/internal/teamcookie"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -37,7 +38,7 @@ func newReverseProxy(target string) *httputil.ReverseProxy {
 }
 
 // HandleProxy determines the JuiceShop instance of the Team based on the team cookie and proxies the request to the corresponding JuiceShop instance.
-func handleProxy(bundle *bundle.Bundle) http.Handler {
+func handleProxy(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			team, err := teamcookie.GetTeamFromRequest(bundle, req)
@@ -59,6 +60,10 @@ func handleProxy(bundle *bundle.Bundle) http.Handler {
 					bundle.Log.Info("Instance for team is missing. Redirecting to multi-juicer landing page.", "team", team)
 					http.Redirect(responseWriter, req, fmt.Sprintf("/multi-juicer/?msg=instance-not-found&team=%s", team), http.StatusFound)
 					return
+				case instanceHibernated:
+					wakeUpInstance(req.Context(), bundle, team)
+					http.Redirect(responseWriter, req, fmt.Sprintf("/multi-juicer/?msg=instance-waking-up&team=%s", team), http.StatusFound)
+					return
 				default:
 					bundle.Log.Info("Instance for team is down. Redirecting to multi-juicer landing page.", "team", team)
 					http.Redirect(responseWriter, req, fmt.Sprintf("/multi-juicer/?msg=instance-restarting&team=%s", team), http.StatusFound)
@@ -86,10 +91,12 @@ const (
 	instanceUp      instanceStatus = "up"
 	instanceDown    instanceStatus = "down"
 	instanceMissing instanceStatus = "missing"
+	// instanceHibernated instances are scaled down to zero replicas, see cleaner.RunCleanup
+	instanceHibernated instanceStatus = "hibernated"
 )
 
-func isInstanceUp(context context.Context, bundle *bundle.Bundle, team string) instanceStatus {
-	deployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(context, fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+func isInstanceUp(context context.Context, bundle *b.Bundle, team string) instanceStatus {
+	deployment, err := bundle.GetJuiceShopDeployment(team)
 
 	switch {
 	case errors.IsNotFound(err):
@@ -97,6 +104,17 @@ func isInstanceUp(context context.Context, bundle *bundle.Bundle, team string) i
 	case err != nil:
 		bundle.Log.Error("Failed to lookup if an instance is up in the kubernetes api. Assuming it's missing.", "error", err)
 		return instanceMissing
+	case deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0:
+		// only hibernated instances get woken up, instances paused by admins or scaled down by hand stay down
+		instance, err := instances.Get(context, bundle, team)
+		if err != nil {
+			bundle.Log.Warn("Failed to lookup if a scaled down instance is hibernated. Assuming it's down.", "team", team, "error", err)
+			return instanceDown
+		}
+		if instance.Spec.Hibernated {
+			return instanceHibernated
+		}
+		return instanceDown
 	case deployment.Status.ReadyReplicas < 1:
 		return instanceDown
 	default:
@@ -109,6 +127,28 @@ func isInstanceUp(context context.Context, bundle *bundle.Bundle, team string) i
 	}
 }
 
+// wakeUpInstance lifts the hibernation of the instance, the instance controller then scales the deployment back up.
+// The last request timestamp is updated right away, otherwise the cleanup would hibernate the instance again before it's ready.
+func wakeUpInstance(context context.Context, bundle *b.Bundle, team string) {
+	wokenUp, err := instances.SetHibernated(context, bundle, team, false)
+	if err != nil {
+		bundle.Log.Error("Failed to wake up hibernated instance", "team", team, "error", err)
+		return
+	}
+	if !wokenUp {
+		// already woken up by a previous request
+		return
+	}
+	bundle.Log.Info("Waking up hibernated instance", "team", team)
+
+	if err := updateLastRequestTimestamp(context, bundle, team); err != nil {
+		bundle.Log.Warn("Failed to update last request timestamp of the woken up instance", "team", team, "error", err)
+	}
+	if err := bundle.EventLog.Append(context, b.Event{Type: b.EventTypeInstanceWokenUp, Team: team}); err != nil {
+		bundle.Log.Error("Failed to record instance wake up in event log", "team", team, "error", err)
+	}
+}
+
 type UpdateProgressDeploymentDiff struct {
 	Metadata UpdateProgressDeploymentMetadata `json:"metadata"`
 }
@@ -124,7 +164,7 @@ type UpdateProgressDeploymentDiffAnnotations struct {
 	LastRequestReadable string `json:"multi-juicer.owasp-juice.shop/lastRequestReadable"`
 }
 
-func updateLastRequestTimestamp(context context.Context, bundle *bundle.Bundle, team string) error {
+func updateLastRequestTimestamp(context context.Context, bundle *b.Bundle, team string) error {
 	bundle.Log.Debug("Updating last request timestamp", "team", team)
 
 	diff := UpdateProgressDeploymentDiff{
@@ -141,7 +181,7 @@ func updateLastRequestTimestamp(context context.Context, bundle *bundle.Bundle,
 		return fmt.Errorf("could not encode json, to update lastRequest timestamp on deployment")
 	}
 
-	_, err = bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Patch(context, fmt.Sprintf("juiceshop-%s", team), types.MergePatchType, jsonBytes, metav1.PatchOptions{})
+	_, err = bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Patch(context, bundle.JuiceShopDeploymentName(team), types.MergePatchType, jsonBytes, metav1.PatchOptions{})
 
 	if err != nil {
 		return fmt.Errorf("failed to last request timestamp for deployment. %w", err)
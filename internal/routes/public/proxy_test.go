@@ -8,9 +8,11 @@ import (
 	"testing"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/instances"
 	"github.com/juice-shop/multi-juicer/internal/signutil"
 	"github.com/juice-shop/multi-juicer/internal/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
@@ -223,4 +225,86 @@ func TestProxyHandler(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf("/multi-juicer/?msg=instance-not-found&team=%s", teamFoo), rr.Header().Get("Location"))
 		assert.Empty(t, rr.Body.String())
 	})
+
+	t.Run("wakes up hibernated instances and redirects to /multi-juicer?msg=instance-waking-up", func(t *testing.T) {
+		defer clearInstanceUpCache()
+		req, _ := http.NewRequest("GET", "/hello-world", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(teamFoo)))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "multi-juicer", Namespace: "test-namespace", UID: "34c0bb8a-240b-4f2a-84ae-2eb2258298f9"},
+		})
+		bu := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		ctx := context.Background()
+
+		deployment, err := instances.Provision(ctx, bu, teamFoo, "passcode-hash")
+		require.NoError(t, err)
+		_, err = instances.SetHibernated(ctx, bu, teamFoo, true)
+		require.NoError(t, err)
+		replicas := int32(0)
+		deployment.Spec.Replicas = &replicas
+		_, err = clientset.AppsV1().Deployments("test-namespace").Update(ctx, deployment, metav1.UpdateOptions{})
+		require.NoError(t, err)
+		testutil.WaitForJuiceShopDeployment(bu, teamFoo, func(deployment *appsv1.Deployment) bool {
+			return *deployment.Spec.Replicas == 0
+		})
+		AddRoutes(server, bu)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, fmt.Sprintf("/multi-juicer/?msg=instance-waking-up&team=%s", teamFoo), rr.Header().Get("Location"))
+
+		instance, err := instances.Get(ctx, bu, teamFoo)
+		require.NoError(t, err)
+		assert.False(t, instance.Spec.Hibernated)
+
+		events := bu.EventLog.GetEvents()
+		require.Len(t, events, 1)
+		assert.Equal(t, bundle.EventTypeInstanceWokenUp, events[0].Type)
+		assert.Equal(t, teamFoo, events[0].Team)
+	})
+
+	t.Run("doesn't wake up instances paused by admins", func(t *testing.T) {
+		defer clearInstanceUpCache()
+		req, _ := http.NewRequest("GET", "/hello-world", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(teamFoo)))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "multi-juicer", Namespace: "test-namespace", UID: "34c0bb8a-240b-4f2a-84ae-2eb2258298f9"},
+		})
+		bu := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		ctx := context.Background()
+
+		deployment, err := instances.Provision(ctx, bu, teamFoo, "passcode-hash")
+		require.NoError(t, err)
+		instance, err := instances.Get(ctx, bu, teamFoo)
+		require.NoError(t, err)
+		instance.Spec.Paused = true
+		_, err = instances.Update(ctx, bu, instance)
+		require.NoError(t, err)
+		replicas := int32(0)
+		deployment.Spec.Replicas = &replicas
+		_, err = clientset.AppsV1().Deployments("test-namespace").Update(ctx, deployment, metav1.UpdateOptions{})
+		require.NoError(t, err)
+		testutil.WaitForJuiceShopDeployment(bu, teamFoo, func(deployment *appsv1.Deployment) bool {
+			return *deployment.Spec.Replicas == 0
+		})
+		AddRoutes(server, bu)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, fmt.Sprintf("/multi-juicer/?msg=instance-restarting&team=%s", teamFoo), rr.Header().Get("Location"))
+		instance, err = instances.Get(ctx, bu, teamFoo)
+		require.NoError(t, err)
+		assert.True(t, instance.Spec.Paused)
+		assert.Empty(t, bu.EventLog.GetEvents())
+	})
 }
@@ -2,10 +2,9 @@ package public
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
-	"github.com/juice-shop/multi-juicer/internal/bundle"
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
 	"github.com/juice-shop/multi-juicer/internal/teamcookie"
 	"golang.org/x/crypto/bcrypt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -17,7 +16,7 @@ type ResetPasscodeResponse struct {
 	Passcode string `json:"passcode"`
 }
 
-func handleResetPasscode(bundle *bundle.Bundle) http.Handler {
+func handleResetPasscode(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 
@@ -29,7 +28,7 @@ func handleResetPasscode(bundle *bundle.Bundle) http.Handler {
 
 			newPasscode := bundle.GeneratePasscode()
 
-			deployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(req.Context(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+			deployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(req.Context(), bundle.JuiceShopDeploymentName(team), metav1.GetOptions{})
 			if err != nil {
 				http.NotFound(responseWriter, req)
 				return
@@ -64,6 +63,10 @@ func handleResetPasscode(bundle *bundle.Bundle) http.Handler {
 				metav1.PatchOptions{},
 			)
 
+			if err := bundle.EventLog.Append(req.Context(), b.Event{Type: b.EventTypePasscodeReset, Team: team}); err != nil {
+				bundle.Log.Error("Failed to record passcode reset in event log", "team", team, "error", err)
+			}
+
 			responseBody := ResetPasscodeResponse{
 				Message:  "Passcode reset successfully",
 				Passcode: newPasscode,
@@ -22,23 +22,33 @@ func AddRoutes(
 	router.Handle("/", metrics.TrackRequestMetrics(metrics.RequestTypeProxy, handleProxy(bundle)))
 	router.Handle("GET /multi-juicer", api(redirectLoggedInTeamsToStatus(bundle, handleStaticFiles(bundle))))
 	router.Handle("GET /multi-juicer/", api(handleStaticFiles(bundle)))
-	router.Handle("POST /multi-juicer/api/teams/{team}/join", jsonAPI(handleTeamJoin(bundle)))
+	router.Handle("POST /multi-juicer/api/teams/{team}/{action}", routeTeamAction(jsonAPI(handleTeamJoin(bundle)), api(handleUnlockHint(bundle))))
 	router.Handle("POST /multi-juicer/api/teams/logout", api(handleLogout(bundle)))
 	router.Handle("POST /multi-juicer/api/teams/reset-passcode", api(handleResetPasscode(bundle)))
 	router.Handle("GET /multi-juicer/api/score-board/top", api(handleScoreBoard(bundle)))
+	router.Handle("GET /multi-juicer/api/score-board/history", api(handleScoreBoardHistory(bundle)))
 	router.Handle("GET /multi-juicer/api/challenges", api(handleChallenges(bundle)))
 	router.Handle("GET /multi-juicer/api/challenges/{challengeKey}", api(handleChallengeDetail(bundle)))
 	router.Handle("GET /multi-juicer/api/teams/status", api(handleTeamStatus(bundle)))
 	router.Handle("GET /multi-juicer/api/teams/{team}/status", api(handleTeamStatus(bundle)))
 	router.Handle("GET /multi-juicer/api/activity-feed", api(handleActivityFeed(bundle)))
 	router.Handle("GET /multi-juicer/api/notifications", api(handleNotifications(bundle)))
+	router.Handle("GET /multi-juicer/api/events", api(handleEvents(bundle)))
 
 	router.Handle("GET /multi-juicer/api/admin/all", api(requireAdmin(bundle, handleAdminListInstances(bundle))))
+	router.Handle("GET /multi-juicer/api/admin/events", api(requireAdmin(bundle, handleAdminListEvents(bundle))))
+	router.Handle("GET /multi-juicer/api/admin/collusion", api(requireAdmin(bundle, handleAdminCollusionReport(bundle))))
 	router.Handle("DELETE /multi-juicer/api/admin/teams/{team}/delete", api(requireAdmin(bundle, handleAdminDeleteInstance(bundle))))
 	router.Handle("POST /multi-juicer/api/admin/teams/{team}/restart", api(requireAdmin(bundle, handleAdminRestartInstance(bundle))))
 	router.Handle("POST /multi-juicer/api/admin/notifications", jsonAPI(requireAdmin(bundle, handleAdminPostNotification(bundle))))
 	router.Handle("POST /multi-juicer/api/admin/clock", jsonAPI(requireAdmin(bundle, handleAdminSetClock(bundle))))
 	router.Handle("POST /multi-juicer/api/admin/teams/{team}/reset-passcode", api(requireAdmin(bundle, handleAdminResetPasscode(bundle))))
+	router.Handle("POST /multi-juicer/api/admin/teams/{team}/adjustments", jsonAPI(requireAdmin(bundle, handleAdminAddScoreAdjustment(bundle))))
+	router.Handle("GET /multi-juicer/api/admin/teams/{team}/solves", api(requireAdmin(bundle, handleAdminListTeamSolves(bundle))))
+	router.Handle("PUT /multi-juicer/api/admin/teams/{team}/solves/{challengeKey}", api(requireAdmin(bundle, handleAdminGrantSolve(bundle))))
+	router.Handle("DELETE /multi-juicer/api/admin/teams/{team}/solves/{challengeKey}", api(requireAdmin(bundle, handleAdminRevokeSolve(bundle))))
+	router.Handle("GET /multi-juicer/api/admin/teams/{team}/overrides", api(requireAdmin(bundle, handleAdminGetInstanceOverrides(bundle))))
+	router.Handle("PUT /multi-juicer/api/admin/teams/{team}/overrides", jsonAPI(requireAdmin(bundle, handleAdminSetInstanceOverrides(bundle))))
 
 	router.HandleFunc("GET /multi-juicer/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -49,3 +59,19 @@ func AddRoutes(
 		w.Write([]byte("OK"))
 	})
 }
+
+// routeTeamAction serves the team joins (POST /multi-juicer/api/teams/{team}/join) and the hint unlocks (POST /multi-juicer/api/teams/hints/{challengeKey}).
+// Both match /multi-juicer/api/teams/hints/join, so the http.ServeMux refuses to register them side by side. That path joins the team "hints", no challenge has the key "join".
+func routeTeamAction(join http.Handler, unlockHint http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.PathValue("action") == "join":
+			join.ServeHTTP(responseWriter, req)
+		case req.PathValue("team") == "hints":
+			req.SetPathValue("challengeKey", req.PathValue("action"))
+			unlockHint.ServeHTTP(responseWriter, req)
+		default:
+			http.NotFound(responseWriter, req)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/longpoll"
+)
+
+const (
+	defaultScoreHistoryTeams = 10
+	maxScoreHistoryTeams     = 50
+)
+
+type ScoreHistoryResponse struct {
+	TotalTeams int                 `json:"totalTeams"`
+	Teams      []*TeamScoreHistory `json:"teams"`
+}
+
+type TeamScoreHistory struct {
+	Name     string `json:"name"`
+	Score    int    `json:"score"`
+	Position int    `json:"position"`
+	// History contains one point per scoring change (solve or hint unlock), oldest first. The last point equals the current score.
+	History []ScoreHistoryPoint `json:"history"`
+}
+
+type ScoreHistoryPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Score     int       `json:"score"`
+}
+
+func handleScoreBoardHistory(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			top := defaultScoreHistoryTeams
+			if topParam := req.URL.Query().Get("top"); topParam != "" {
+				parsed, err := strconv.Atoi(topParam)
+				if err != nil || parsed < 1 || parsed > maxScoreHistoryTeams {
+					http.Error(responseWriter, "top must be a number between 1 and 50", http.StatusBadRequest)
+					return
+				}
+				top = parsed
+			}
+
+			// Define the fetch function for long polling
+			fetchFunc := func(ctx context.Context, waitAfter *time.Time) ([]*b.TeamScore, time.Time, bool, error) {
+				if waitAfter != nil {
+					totalTeams, lastUpdateTime := bundle.ScoringService.WaitForUpdatesNewerThanWithTimestamp(ctx, *waitAfter)
+					if totalTeams == nil {
+						return nil, time.Time{}, false, nil
+					}
+					return totalTeams, lastUpdateTime, true, nil
+				}
+				totalTeams, lastUpdateTime := bundle.ScoringService.GetTopScoresWithTimestamp()
+				return totalTeams, lastUpdateTime, true, nil
+			}
+
+			totalTeams, lastUpdateTime, statusCode, err := longpoll.HandleLongPoll(req, fetchFunc)
+			if err != nil {
+				bundle.Log.Error("Long poll error", "error", err)
+				http.Error(responseWriter, "Invalid time format", statusCode)
+				return
+			}
+			if statusCode == http.StatusNoContent {
+				responseWriter.WriteHeader(http.StatusNoContent)
+				responseWriter.Write([]byte{}) // nosemgrep: go.lang.security.audit.xss.no-direct-write-to-responsewriter.no-direct-write-to-responsewriter
+				return
+			}
+
+			topTeams := totalTeams[:min(len(totalTeams), top)]
+			histories := make([]*TeamScoreHistory, len(topTeams))
+			for i, topTeam := range topTeams {
+				histories[i] = &TeamScoreHistory{
+					Name:     topTeam.Name,
+					Score:    topTeam.Score,
+					Position: topTeam.Position,
+					History:  buildScoreHistory(bundle, topTeam),
+				}
+			}
+
+			response := ScoreHistoryResponse{
+				TotalTeams: len(totalTeams),
+				Teams:      histories,
+			}
+
+			responseBytes, marshalErr := json.Marshal(response)
+			if marshalErr != nil {
+				bundle.Log.Error("Failed to marshal response", "error", marshalErr)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.Header().Set("Last-Modified", lastUpdateTime.UTC().Format(time.RFC1123))
+			responseWriter.Header().Set("X-Last-Update", lastUpdateTime.UTC().Format(time.RFC3339Nano))
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write(responseBytes) // nosemgrep: go.lang.security.audit.xss.no-direct-write-to-responsewriter.no-direct-write-to-responsewriter
+		},
+	)
+}
+
+// buildScoreHistory replays the solves, hint unlocks and score adjustments of a team in chronological order.
+// Challenges are valued at their current points, so with dynamic scoring the whole curve shifts as challenges decay, just like the current score does.
+func buildScoreHistory(bundle *b.Bundle, teamScore *b.TeamScore) []ScoreHistoryPoint {
+	changes := make([]ScoreHistoryPoint, 0, len(teamScore.Challenges)+len(teamScore.Hints)+len(teamScore.Adjustments))
+	for _, challenge := range teamScore.Challenges {
+		changes = append(changes, ScoreHistoryPoint{
+			Timestamp: challenge.SolvedAt,
+			Score:     bundle.ScoringService.GetChallengePoints(challenge.Key) + challenge.Bonus,
+		})
+	}
+	for _, hint := range teamScore.Hints {
+		changes = append(changes, ScoreHistoryPoint{
+			Timestamp: hint.UnlockedAt,
+			Score:     -hint.Cost,
+		})
+	}
+	for _, adjustment := range teamScore.Adjustments {
+		changes = append(changes, ScoreHistoryPoint{
+			Timestamp: adjustment.CreatedAt,
+			Score:     adjustment.Points,
+		})
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Timestamp.Before(changes[j].Timestamp)
+	})
+
+	history := make([]ScoreHistoryPoint, 0, len(changes))
+	score := 0
+	for _, change := range changes {
+		score += change.Score
+		// merge changes happening at the same time into a single point
+		if len(history) > 0 && history[len(history)-1].Timestamp.Equal(change.Timestamp) {
+			history[len(history)-1].Score = score
+			continue
+		}
+		history = append(history, ScoreHistoryPoint{Timestamp: change.Timestamp, Score: score})
+	}
+	return history
+}
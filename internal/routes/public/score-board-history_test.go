@@ -0,0 +1,126 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/scoring"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestScoreBoardHistoryHandler(t *testing.T) {
+	createTeam := func(team string, annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("juiceshop-%s", team),
+				Namespace:   "test-namespace",
+				Annotations: annotations,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+			Status: appsv1.DeploymentStatus{
+				ReadyReplicas: 1,
+			},
+		}
+	}
+
+	newServer := func(clientset *fake.Clientset) *http.ServeMux {
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		bundle.ScoringService = scoringService
+		server := http.NewServeMux()
+		AddRoutes(server, bundle)
+		return server
+	}
+
+	t.Run("returns the cumulative score of each team over time", func(t *testing.T) {
+		server := newServer(fake.NewClientset(
+			createTeam("foobar", map[string]string{
+				"multi-juicer.owasp-juice.shop/challenges": `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T20:00:00Z"},{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:00:00Z"}]`,
+				"multi-juicer.owasp-juice.shop/hints":      `[{"key":"nullByteChallenge","unlockedAt":"2024-11-01T19:30:00Z","cost":5}]`,
+			}),
+			createTeam("barfoo", map[string]string{
+				"multi-juicer.owasp-juice.shop/challenges": `[]`,
+			}),
+		))
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/score-board/history", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response ScoreHistoryResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+
+		assert.Equal(t, 2, response.TotalTeams)
+		require.Len(t, response.Teams, 2)
+		assert.Equal(t, "foobar", response.Teams[0].Name)
+		assert.Equal(t, 45, response.Teams[0].Score)
+		assert.Equal(t, []ScoreHistoryPoint{
+			{Timestamp: time.Date(2024, 11, 1, 19, 0, 0, 0, time.UTC), Score: 10},
+			{Timestamp: time.Date(2024, 11, 1, 19, 30, 0, 0, time.UTC), Score: 5},
+			{Timestamp: time.Date(2024, 11, 1, 20, 0, 0, 0, time.UTC), Score: 45},
+		}, response.Teams[0].History)
+
+		assert.Equal(t, "barfoo", response.Teams[1].Name)
+		assert.Empty(t, response.Teams[1].History)
+	})
+
+	t.Run("limits the response to the requested number of teams", func(t *testing.T) {
+		server := newServer(fake.NewClientset(
+			createTeam("foobar", map[string]string{
+				"multi-juicer.owasp-juice.shop/challenges": `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:00:00Z"}]`,
+			}),
+			createTeam("barfoo", map[string]string{}),
+		))
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/score-board/history?top=1", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response ScoreHistoryResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, 2, response.TotalTeams)
+		require.Len(t, response.Teams, 1)
+		assert.Equal(t, "foobar", response.Teams[0].Name)
+	})
+
+	t.Run("rejects invalid top values", func(t *testing.T) {
+		server := newServer(fake.NewClientset())
+
+		for _, top := range []string{"0", "51", "ten"} {
+			req, _ := http.NewRequest("GET", "/multi-juicer/api/score-board/history?top="+top, nil)
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, "top=%s", top)
+		}
+	})
+
+	t.Run("long-polling times out when no updates occur", func(t *testing.T) {
+		server := newServer(fake.NewClientset(createTeam("foobar", map[string]string{})))
+
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/score-board/history?wait-for-update-after=2099-01-01T00:00:00Z", nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+	})
+}
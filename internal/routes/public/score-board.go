@@ -50,30 +50,7 @@ func handleScoreBoard(bundle *b.Bundle) http.Handler {
 				return
 			}
 
-			var topTeams []*b.TeamScore
-			// limit score-board to calculate score for the top 24 teams only
-			if len(totalTeams) > 24 {
-				topTeams = totalTeams[:24]
-			} else {
-				topTeams = totalTeams
-			}
-
-			convertedTopScores := make([]*TeamScore, len(topTeams))
-			for i, topTeam := range topTeams {
-				convertedTopScores[i] = &TeamScore{
-					Name:                 topTeam.Name,
-					Score:                topTeam.Score,
-					Position:             topTeam.Position,
-					SolvedChallengeCount: len(topTeam.Challenges),
-				}
-			}
-
-			response := ScoreBoardResponse{
-				TotalTeams: len(totalTeams),
-				TopTeams:   convertedTopScores,
-			}
-
-			responseBytes, marshalErr := json.Marshal(response)
+			responseBytes, marshalErr := json.Marshal(buildScoreBoardResponse(totalTeams))
 			if marshalErr != nil {
 				bundle.Log.Error("Failed to marshal response", "error", marshalErr)
 				http.Error(responseWriter, "", http.StatusInternalServerError)
@@ -88,3 +65,28 @@ func handleScoreBoard(bundle *b.Bundle) http.Handler {
 		},
 	)
 }
+
+func buildScoreBoardResponse(totalTeams []*b.TeamScore) ScoreBoardResponse {
+	var topTeams []*b.TeamScore
+	// limit score-board to calculate score for the top 24 teams only
+	if len(totalTeams) > 24 {
+		topTeams = totalTeams[:24]
+	} else {
+		topTeams = totalTeams
+	}
+
+	convertedTopScores := make([]*TeamScore, len(topTeams))
+	for i, topTeam := range topTeams {
+		convertedTopScores[i] = &TeamScore{
+			Name:                 topTeam.Name,
+			Score:                topTeam.Score,
+			Position:             topTeam.Position,
+			SolvedChallengeCount: len(topTeam.Challenges),
+		}
+	}
+
+	return ScoreBoardResponse{
+		TotalTeams: len(totalTeams),
+		TopTeams:   convertedTopScores,
+	}
+}
@@ -15,7 +15,11 @@ type SolvedChallenge struct {
 	Key        string `json:"key"`
 	Name       string `json:"name"`
 	Difficulty int    `json:"difficulty"`
+	Points     int    `json:"points"`
+	Bonus      int    `json:"bonus"`
 	SolvedAt   string `json:"solvedAt"`
+	// Evidence reported by the instance of the team, only sent to the team itself
+	Evidence string `json:"evidence,omitempty"`
 }
 
 type TeamStatus struct {
@@ -25,6 +29,8 @@ type TeamStatus struct {
 	Position         int               `json:"position"`
 	TotalTeams       int               `json:"totalTeams"`
 	Readiness        bool              `json:"readiness"`
+	// Adjustments of the score by admins, oldest first
+	Adjustments []bundle.ScoreAdjustment `json:"adjustments,omitempty"`
 }
 
 type AdminTeamStatus struct {
@@ -38,10 +44,7 @@ func (e *teamNotFoundError) Error() string {
 }
 
 func handleTeamStatus(b *bundle.Bundle) http.Handler {
-	challengesByKeys := make(map[string]bundle.JuiceShopChallenge)
-	for _, challenge := range b.JuiceShopChallenges {
-		challengesByKeys[challenge.Key] = challenge
-	}
+	challengesByKeys := getChallengesByKeys(b)
 
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
@@ -49,6 +52,7 @@ func handleTeamStatus(b *bundle.Bundle) http.Handler {
 
 			// Determine which team to fetch status for
 			var team string
+			isOwnTeam := false
 
 			if teamParam == "" || teamParam == "me" {
 				// No team parameter or "me" - return current logged-in team's status
@@ -59,6 +63,8 @@ func handleTeamStatus(b *bundle.Bundle) http.Handler {
 					return
 				}
 
+				isOwnTeam = true
+
 				if team == "admin" {
 					responseBytes, err := json.Marshal(AdminTeamStatus{Name: "admin"})
 					if err != nil {
@@ -79,6 +85,8 @@ func handleTeamStatus(b *bundle.Bundle) http.Handler {
 					return
 				}
 				team = teamParam
+				loggedInTeam, err := teamcookie.GetTeamFromRequest(b, req)
+				isOwnTeam = err == nil && loggedInTeam == team
 			}
 
 			// Define the fetch function for long polling
@@ -114,28 +122,11 @@ func handleTeamStatus(b *bundle.Bundle) http.Handler {
 				return
 			}
 
-			teamCount := len(b.ScoringService.GetScores())
-			// Build solved challenges array
-			solvedChallenges := make([]SolvedChallenge, len(teamScore.Challenges))
-			for i, challenge := range teamScore.Challenges {
-				solvedChallenges[i] = SolvedChallenge{
-					Key:        challenge.Key,
-					Name:       challengesByKeys[challenge.Key].Name,
-					Difficulty: challengesByKeys[challenge.Key].Difficulty,
-					SolvedAt:   challenge.SolvedAt.Format(time.RFC3339),
-				}
+			teamStatus := buildTeamStatus(b, challengesByKeys, team, teamScore)
+			if isOwnTeam {
+				addSolveEvidence(b, team, &teamStatus)
 			}
-
-			response := TeamStatus{
-				Name:             team,
-				Score:            teamScore.Score,
-				Position:         teamScore.Position,
-				TotalTeams:       teamCount,
-				SolvedChallenges: solvedChallenges,
-				Readiness:        teamScore.InstanceReadiness,
-			}
-
-			responseBytes, err := json.Marshal(response)
+			responseBytes, err := json.Marshal(teamStatus)
 			if err != nil {
 				b.Log.Error("Failed to marshal response", "error", err)
 				http.Error(responseWriter, "", http.StatusInternalServerError)
@@ -149,3 +140,57 @@ func handleTeamStatus(b *bundle.Bundle) http.Handler {
 		},
 	)
 }
+
+func getChallengesByKeys(b *bundle.Bundle) map[string]bundle.JuiceShopChallenge {
+	challengesByKeys := make(map[string]bundle.JuiceShopChallenge)
+	for _, challenge := range b.JuiceShopChallenges {
+		challengesByKeys[challenge.Key] = challenge
+	}
+	return challengesByKeys
+}
+
+func buildTeamStatus(b *bundle.Bundle, challengesByKeys map[string]bundle.JuiceShopChallenge, team string, teamScore *bundle.TeamScore) TeamStatus {
+	teamCount := len(b.ScoringService.GetScores())
+	// Build solved challenges array
+	solvedChallenges := make([]SolvedChallenge, len(teamScore.Challenges))
+	for i, challenge := range teamScore.Challenges {
+		solvedChallenges[i] = SolvedChallenge{
+			Key:        challenge.Key,
+			Name:       challengesByKeys[challenge.Key].Name,
+			Difficulty: challengesByKeys[challenge.Key].Difficulty,
+			Points:     b.ScoringService.GetChallengePoints(challenge.Key),
+			Bonus:      challenge.Bonus,
+			SolvedAt:   challenge.SolvedAt.Format(time.RFC3339),
+		}
+	}
+
+	return TeamStatus{
+		Name:             team,
+		Score:            teamScore.Score,
+		Position:         teamScore.Position,
+		TotalTeams:       teamCount,
+		SolvedChallenges: solvedChallenges,
+		Readiness:        teamScore.InstanceReadiness,
+		Adjustments:      teamScore.Adjustments,
+	}
+}
+
+// addSolveEvidence adds the evidence of the solves persisted on the deployment of the team to its solved challenges
+func addSolveEvidence(b *bundle.Bundle, team string, teamStatus *TeamStatus) {
+	deployment, err := b.GetJuiceShopDeployment(team)
+	if err != nil {
+		return
+	}
+	solves, err := parseSolveEvidence(deployment)
+	if err != nil {
+		b.Log.Warn("Failed to decode solve evidence", "team", team, "error", err)
+		return
+	}
+	evidenceByKeys := make(map[string]string, len(solves))
+	for _, solve := range solves {
+		evidenceByKeys[solve.Key] = solve.Evidence
+	}
+	for i := range teamStatus.SolvedChallenges {
+		teamStatus.SolvedChallenges[i].Evidence = evidenceByKeys[teamStatus.SolvedChallenges[i].Key]
+	}
+}
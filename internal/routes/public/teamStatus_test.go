@@ -61,7 +61,7 @@ func TestTeamStatusHandler(t *testing.T) {
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"totalTeams":2,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":true}`, rr.Body.String())
+		assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"totalTeams":2,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"points":10,"bonus":0,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":true}`, rr.Body.String())
 	})
 
 	t.Run("returns 404 if team doesn't exist in scores", func(t *testing.T) {
@@ -104,7 +104,7 @@ func TestTeamStatusHandler(t *testing.T) {
 			req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
 			server.ServeHTTP(rr, req)
 			assert.Equal(t, http.StatusOK, rr.Code)
-			assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"totalTeams":1,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":false}`, rr.Body.String())
+			assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"totalTeams":1,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"points":10,"bonus":0,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":false}`, rr.Body.String())
 		}
 
 		// Update the deployment in the fake clientset
@@ -114,6 +114,9 @@ func TestTeamStatusHandler(t *testing.T) {
 
 		// Recalculate the scoreboard to pick up the deployment change
 		// This simulates what the watcher would do, but is deterministic
+		testutil.WaitForJuiceShopDeployment(bundle, team, func(deployment *appsv1.Deployment) bool {
+			return deployment.Status.ReadyReplicas == 1
+		})
 		err = scoringService.CalculateAndCacheScoreBoard(ctx)
 		assert.Nil(t, err)
 
@@ -124,7 +127,7 @@ func TestTeamStatusHandler(t *testing.T) {
 			req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
 			server.ServeHTTP(rr, req)
 			assert.Equal(t, http.StatusOK, rr.Code)
-			assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"totalTeams":1,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":true}`, rr.Body.String())
+			assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"totalTeams":1,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"points":10,"bonus":0,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":true}`, rr.Body.String())
 		}
 	})
 
@@ -179,7 +182,58 @@ func TestTeamStatusHandler(t *testing.T) {
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"totalTeams":2,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":true}`, rr.Body.String())
+		assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"totalTeams":2,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"points":10,"bonus":0,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":true}`, rr.Body.String())
+	})
+
+	t.Run("includes the score adjustments of the team", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/multi-juicer/api/teams/foobar/status", nil)
+		rr := httptest.NewRecorder()
+		server := http.NewServeMux()
+		deployment := createTeam("foobar", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "1")
+		deployment.Annotations["multi-juicer.owasp-juice.shop/adjustments"] = `[{"points":-5,"reason":"attacked the infrastructure","createdAt":"2024-11-01T20:00:00Z"}]`
+		clientset := fake.NewClientset(deployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		bundle.ScoringService = scoringService
+		AddRoutes(server, bundle)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"name":"foobar","score":5,"position":1,"totalTeams":1,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"points":10,"bonus":0,"solvedAt":"2024-11-01T19:55:48Z"}],"readiness":true,"adjustments":[{"points":-5,"reason":"attacked the infrastructure","createdAt":"2024-11-01T20:00:00Z"}]}`, rr.Body.String())
+	})
+
+	t.Run("includes the evidence of the solves only in the status of the own team", func(t *testing.T) {
+		deployment := createTeam("foobar", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "1")
+		deployment.Annotations["multi-juicer.owasp-juice.shop/solves"] = `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48Z","evidence":"/#/score-board"}]`
+		clientset := fake.NewClientset(deployment, createTeam("barfoo", `[]`, "0"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		bundle.ScoringService = scoringService
+		server := http.NewServeMux()
+		AddRoutes(server, bundle)
+
+		for _, request := range []struct {
+			path         string
+			team         string
+			withEvidence bool
+		}{
+			{path: "/multi-juicer/api/teams/status", team: "foobar", withEvidence: true},
+			{path: "/multi-juicer/api/teams/foobar/status", team: "foobar", withEvidence: true},
+			{path: "/multi-juicer/api/teams/foobar/status", team: "barfoo", withEvidence: false},
+			{path: "/multi-juicer/api/teams/foobar/status", team: "admin", withEvidence: false},
+		} {
+			req, _ := http.NewRequest("GET", request.path, nil)
+			req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(request.team)))
+			rr := httptest.NewRecorder()
+
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, request.withEvidence, strings.Contains(rr.Body.String(), `"evidence":"/#/score-board"`), "%s requested by %s", request.path, request.team)
+		}
 	})
 
 	t.Run("returns 404 when requesting a non-existent team", func(t *testing.T) {
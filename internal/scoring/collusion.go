@@ -0,0 +1,187 @@
+package scoring
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+)
+
+const (
+	// closeSolveWindow in which solves of the same challenge by two teams count as close. The closer the solves, the more they add to the similarity of the teams.
+	closeSolveWindow = 60 * time.Second
+	// highDifficulty is the difficulty from which on fast solves are considered implausible
+	highDifficulty = 4
+	// minImplausibleSolves a team needs to be reported for its pace
+	minImplausibleSolves = 3
+)
+
+// minPlausibleSolveDurations is the least time expected between a solve and the previous solve of the team, keyed by the difficulty of the solved challenge
+var minPlausibleSolveDurations = map[int]time.Duration{
+	4: 2 * time.Minute,
+	5: 4 * time.Minute,
+	6: 8 * time.Minute,
+}
+
+// CollusionReport lists team pairs solving the same challenges at nearly the same time and teams solving hard challenges at an implausible pace.
+// It's a hint for admins to look closer, not a proof of cheating.
+type CollusionReport struct {
+	// Pairs of teams with at least one close solve, most similar first
+	Pairs []TeamPairSimilarity `json:"pairs"`
+	// FastTeams with at least minImplausibleSolves implausibly fast solves, most implausible solves first
+	FastTeams []FastTeam `json:"fastTeams"`
+}
+
+type TeamPairSimilarity struct {
+	Teams [2]string `json:"teams"`
+	// Similarity from 0 to 1: the close solves of both teams, weighted by how close and how rare they are, relative to the solves of the team with fewer solves
+	Similarity  float64      `json:"similarity"`
+	CloseSolves []CloseSolve `json:"closeSolves"`
+}
+
+type CloseSolve struct {
+	ChallengeKey string  `json:"challengeKey"`
+	SecondsApart float64 `json:"secondsApart"`
+	// Solvers is the number of teams which solved the challenge
+	Solvers int `json:"solvers"`
+}
+
+type FastTeam struct {
+	Team              string             `json:"team"`
+	ImplausibleSolves []ImplausibleSolve `json:"implausibleSolves"`
+}
+
+type ImplausibleSolve struct {
+	ChallengeKey              string  `json:"challengeKey"`
+	Difficulty                int     `json:"difficulty"`
+	SecondsSincePreviousSolve float64 `json:"secondsSincePreviousSolve"`
+}
+
+// DetectCollusion analyzes the solves of all teams. Only solves of challenges in the challenges map are taken into account.
+func DetectCollusion(teamScores map[string]*bundle.TeamScore, challenges map[string]bundle.JuiceShopChallenge) CollusionReport {
+	return CollusionReport{
+		Pairs:     findSimilarTeamPairs(teamScores, challenges),
+		FastTeams: findFastTeams(teamScores, challenges),
+	}
+}
+
+func findSimilarTeamPairs(teamScores map[string]*bundle.TeamScore, challenges map[string]bundle.JuiceShopChallenge) []TeamPairSimilarity {
+	type solve struct {
+		team     string
+		solvedAt time.Time
+	}
+	solvesByChallenge := map[string][]solve{}
+	solveCounts := map[string]int{}
+	for team, teamScore := range teamScores {
+		for _, challenge := range teamScore.Challenges {
+			if _, ok := challenges[challenge.Key]; !ok {
+				continue
+			}
+			solvesByChallenge[challenge.Key] = append(solvesByChallenge[challenge.Key], solve{team: team, solvedAt: challenge.SolvedAt})
+			solveCounts[team]++
+		}
+	}
+
+	pairs := map[[2]string]*TeamPairSimilarity{}
+	weights := map[[2]string]float64{}
+	for challengeKey, solves := range solvesByChallenge {
+		sort.Slice(solves, func(i, j int) bool {
+			return solves[i].solvedAt.Before(solves[j].solvedAt)
+		})
+		// solved by just the two teams it's worth 1, the more teams solved the challenge the less it tells about the pair
+		rarity := 2 / float64(len(solves))
+		for i := range solves {
+			for j := i + 1; j < len(solves); j++ {
+				apart := solves[j].solvedAt.Sub(solves[i].solvedAt)
+				if apart > closeSolveWindow {
+					break
+				}
+				key := [2]string{solves[i].team, solves[j].team}
+				if key[0] > key[1] {
+					key = [2]string{key[1], key[0]}
+				}
+				pair, ok := pairs[key]
+				if !ok {
+					pair = &TeamPairSimilarity{Teams: key, CloseSolves: []CloseSolve{}}
+					pairs[key] = pair
+				}
+				pair.CloseSolves = append(pair.CloseSolves, CloseSolve{ChallengeKey: challengeKey, SecondsApart: apart.Seconds(), Solvers: len(solves)})
+				closeness := 1 - apart.Seconds()/closeSolveWindow.Seconds()
+				weights[key] += closeness * rarity
+			}
+		}
+	}
+
+	result := make([]TeamPairSimilarity, 0, len(pairs))
+	for key, pair := range pairs {
+		fewestSolves := min(solveCounts[key[0]], solveCounts[key[1]])
+		pair.Similarity = roundSimilarity(min(1, weights[key]/float64(fewestSolves)))
+		sort.Slice(pair.CloseSolves, func(i, j int) bool {
+			return pair.CloseSolves[i].ChallengeKey < pair.CloseSolves[j].ChallengeKey
+		})
+		result = append(result, *pair)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Similarity == result[j].Similarity {
+			return result[i].Teams[0]+"/"+result[i].Teams[1] < result[j].Teams[0]+"/"+result[j].Teams[1]
+		}
+		return result[i].Similarity > result[j].Similarity
+	})
+	return result
+}
+
+func findFastTeams(teamScores map[string]*bundle.TeamScore, challenges map[string]bundle.JuiceShopChallenge) []FastTeam {
+	fastTeams := []FastTeam{}
+	for team, teamScore := range teamScores {
+		solves := make([]bundle.ChallengeProgress, 0, len(teamScore.Challenges))
+		for _, challenge := range teamScore.Challenges {
+			if _, ok := challenges[challenge.Key]; ok {
+				solves = append(solves, challenge)
+			}
+		}
+		sort.Slice(solves, func(i, j int) bool {
+			return solves[i].SolvedAt.Before(solves[j].SolvedAt)
+		})
+
+		implausibleSolves := []ImplausibleSolve{}
+		for i := 1; i < len(solves); i++ {
+			difficulty := challenges[solves[i].Key].Difficulty
+			if difficulty < highDifficulty {
+				continue
+			}
+			sincePreviousSolve := solves[i].SolvedAt.Sub(solves[i-1].SolvedAt)
+			if sincePreviousSolve < minPlausibleSolveDuration(difficulty) {
+				implausibleSolves = append(implausibleSolves, ImplausibleSolve{
+					ChallengeKey:              solves[i].Key,
+					Difficulty:                difficulty,
+					SecondsSincePreviousSolve: sincePreviousSolve.Seconds(),
+				})
+			}
+		}
+		if len(implausibleSolves) >= minImplausibleSolves {
+			fastTeams = append(fastTeams, FastTeam{Team: team, ImplausibleSolves: implausibleSolves})
+		}
+	}
+	sort.Slice(fastTeams, func(i, j int) bool {
+		if len(fastTeams[i].ImplausibleSolves) == len(fastTeams[j].ImplausibleSolves) {
+			return fastTeams[i].Team < fastTeams[j].Team
+		}
+		return len(fastTeams[i].ImplausibleSolves) > len(fastTeams[j].ImplausibleSolves)
+	})
+	return fastTeams
+}
+
+// minPlausibleSolveDuration returns the duration for the highest configured difficulty for challenges beyond it
+func minPlausibleSolveDuration(difficulty int) time.Duration {
+	for ; difficulty >= highDifficulty; difficulty-- {
+		if duration, ok := minPlausibleSolveDurations[difficulty]; ok {
+			return duration
+		}
+	}
+	return 0
+}
+
+func roundSimilarity(similarity float64) float64 {
+	return math.Round(similarity*1000) / 1000
+}
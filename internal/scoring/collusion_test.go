@@ -0,0 +1,115 @@
+package scoring
+
+import (
+	"testing"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectCollusion(t *testing.T) {
+	challenges := map[string]b.JuiceShopChallenge{
+		"scoreBoardChallenge":        {Key: "scoreBoardChallenge", Difficulty: 1},
+		"loginAdminChallenge":        {Key: "loginAdminChallenge", Difficulty: 2},
+		"nullByteChallenge":          {Key: "nullByteChallenge", Difficulty: 4},
+		"unionSqlInjectionChallenge": {Key: "unionSqlInjectionChallenge", Difficulty: 4},
+		"forgedJwtChallenge":         {Key: "forgedJwtChallenge", Difficulty: 5},
+		"rceOccupyChallenge":         {Key: "rceOccupyChallenge", Difficulty: 6},
+	}
+	start := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	solvedAt := func(offset time.Duration) time.Time {
+		return start.Add(offset)
+	}
+	team := func(name string, solves map[string]time.Duration) *b.TeamScore {
+		teamScore := &b.TeamScore{Name: name, Challenges: []b.ChallengeProgress{}}
+		for key, offset := range solves {
+			teamScore.Challenges = append(teamScore.Challenges, b.ChallengeProgress{Key: key, SolvedAt: solvedAt(offset)})
+		}
+		return teamScore
+	}
+
+	t.Run("scores team pairs solving the same rare challenges within seconds highest", func(t *testing.T) {
+		report := DetectCollusion(map[string]*b.TeamScore{
+			"sharer": team("sharer", map[string]time.Duration{
+				"scoreBoardChallenge": 1 * time.Minute,
+				"nullByteChallenge":   40 * time.Minute,
+				"forgedJwtChallenge":  90 * time.Minute,
+			}),
+			"copier": team("copier", map[string]time.Duration{
+				"scoreBoardChallenge": 20 * time.Minute,
+				"nullByteChallenge":   40*time.Minute + 6*time.Second,
+				"forgedJwtChallenge":  90*time.Minute + 12*time.Second,
+			}),
+			"bystander": team("bystander", map[string]time.Duration{
+				"scoreBoardChallenge": 1*time.Minute + 30*time.Second,
+				"loginAdminChallenge": 10 * time.Minute,
+			}),
+		}, challenges)
+
+		assert.Equal(t, []TeamPairSimilarity{
+			{
+				Teams:      [2]string{"copier", "sharer"},
+				Similarity: 0.567,
+				CloseSolves: []CloseSolve{
+					{ChallengeKey: "forgedJwtChallenge", SecondsApart: 12, Solvers: 2},
+					{ChallengeKey: "nullByteChallenge", SecondsApart: 6, Solvers: 2},
+				},
+			},
+			{
+				Teams:      [2]string{"bystander", "sharer"},
+				Similarity: 0.167,
+				CloseSolves: []CloseSolve{
+					{ChallengeKey: "scoreBoardChallenge", SecondsApart: 30, Solvers: 3},
+				},
+			},
+		}, report.Pairs)
+	})
+
+	t.Run("doesn't pair teams without close solves", func(t *testing.T) {
+		report := DetectCollusion(map[string]*b.TeamScore{
+			"foobar": team("foobar", map[string]time.Duration{"scoreBoardChallenge": 1 * time.Minute}),
+			"barfoo": team("barfoo", map[string]time.Duration{"scoreBoardChallenge": 5 * time.Minute}),
+		}, challenges)
+
+		assert.Empty(t, report.Pairs)
+	})
+
+	t.Run("reports teams solving many hard challenges faster than plausible", func(t *testing.T) {
+		report := DetectCollusion(map[string]*b.TeamScore{
+			"speedrunner": team("speedrunner", map[string]time.Duration{
+				"scoreBoardChallenge":        0,
+				"nullByteChallenge":          30 * time.Second,
+				"unionSqlInjectionChallenge": 1 * time.Minute,
+				"forgedJwtChallenge":         2 * time.Minute,
+				"rceOccupyChallenge":         3 * time.Hour,
+			}),
+			"steady": team("steady", map[string]time.Duration{
+				"scoreBoardChallenge":        0,
+				"nullByteChallenge":          10 * time.Minute,
+				"unionSqlInjectionChallenge": 11 * time.Minute,
+				"forgedJwtChallenge":         40 * time.Minute,
+			}),
+		}, challenges)
+
+		assert.Equal(t, []FastTeam{
+			{
+				Team: "speedrunner",
+				ImplausibleSolves: []ImplausibleSolve{
+					{ChallengeKey: "nullByteChallenge", Difficulty: 4, SecondsSincePreviousSolve: 30},
+					{ChallengeKey: "unionSqlInjectionChallenge", Difficulty: 4, SecondsSincePreviousSolve: 30},
+					{ChallengeKey: "forgedJwtChallenge", Difficulty: 5, SecondsSincePreviousSolve: 60},
+				},
+			},
+		}, report.FastTeams)
+	})
+
+	t.Run("ignores challenges unknown to multijuicer", func(t *testing.T) {
+		report := DetectCollusion(map[string]*b.TeamScore{
+			"foobar": team("foobar", map[string]time.Duration{"challengeOfAnotherVersion": 0}),
+			"barfoo": team("barfoo", map[string]time.Duration{"challengeOfAnotherVersion": 0}),
+		}, challenges)
+
+		assert.Equal(t, CollusionReport{Pairs: []TeamPairSimilarity{}, FastTeams: []FastTeam{}}, report)
+	})
+}
@@ -3,19 +3,45 @@ package scoring
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/longpoll"
 	"github.com/juice-shop/multi-juicer/internal/timeutil"
+	"github.com/prometheus/client_golang/prometheus"
 	appsv1 "k8s.io/api/apps/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
 )
 
 var cachedChallengesMap map[string](bundle.JuiceShopChallenge)
 
+var cheatDecisionGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "multijuicer_team_cheat_decision",
+		Help: "Decision for the latest cheat score of the team: 0 if it isn't flagged, 1 if it's suspicious, 2 if it's disqualified.",
+	},
+	[]string{"team"},
+)
+
+func init() {
+	prometheus.MustRegister(cheatDecisionGauge)
+}
+
+func setCheatDecisionGauge(teamScore *bundle.TeamScore) {
+	value := 0.0
+	switch teamScore.CheatDecision {
+	case bundle.CheatDecisionSuspicious:
+		value = 1
+	case bundle.CheatDecisionDisqualified:
+		value = 2
+	}
+	cheatDecisionGauge.WithLabelValues(teamScore.Name).Set(value)
+}
+
 type ScoringService struct {
 	bundle              *bundle.Bundle
 	currentScores       map[string]*bundle.TeamScore
@@ -23,8 +49,16 @@ type ScoringService struct {
 	currentScoresMutex  *sync.Mutex
 
 	lastUpdate time.Time
+	// updated is closed (and replaced with a new channel) whenever the scores change, to wake up all waiting long polling requests at once
+	updated chan struct{}
 
 	challengesMap map[string](bundle.JuiceShopChallenge)
+
+	strategy ScoringStrategy
+	// number of teams which solved each challenge, keyed by challenge key. Used by strategies that depend on the solve count
+	solveCounts map[string]int
+	// position (starting at 0) in which each team solved a challenge, keyed by challenge key and team. Used to award early solve bonuses
+	solveRanks map[string]map[string]int
 }
 
 func NewScoringService(b *bundle.Bundle) *ScoringService {
@@ -45,8 +79,13 @@ func NewScoringServiceWithInitialScores(b *bundle.Bundle, initialScores map[stri
 		currentScoresMutex:  &sync.Mutex{},
 
 		lastUpdate: timeutil.TruncateToMillisecond(time.Now()),
+		updated:    make(chan struct{}),
 
 		challengesMap: cachedChallengesMap,
+
+		strategy:    NewScoringStrategy(b.Config.ScoringConfig),
+		solveCounts: countSolves(initialScores),
+		solveRanks:  rankSolves(initialScores),
 	}
 }
 
@@ -75,38 +114,41 @@ func (s *ScoringService) GetTopScoresWithTimestamp() ([]*bundle.TeamScore, time.
 	return s.currentScoresSorted, s.lastUpdate
 }
 
+// GetChallengePoints returns the points a solve of the challenge is currently worth. Returns 0 for unknown challenges.
+func (s *ScoringService) GetChallengePoints(challengeKey string) int {
+	challenge, ok := s.challengesMap[challengeKey]
+	if !ok {
+		return 0
+	}
+	s.currentScoresMutex.Lock()
+	defer s.currentScoresMutex.Unlock()
+	return s.strategy.ChallengePoints(challenge, s.solveCounts[challengeKey])
+}
+
 func (s *ScoringService) WaitForUpdatesNewerThan(ctx context.Context, lastSeenUpdate time.Time) []*bundle.TeamScore {
 	scores, _ := s.WaitForUpdatesNewerThanWithTimestamp(ctx, lastSeenUpdate)
 	return scores
 }
 
 func (s *ScoringService) WaitForUpdatesNewerThanWithTimestamp(ctx context.Context, lastSeenUpdate time.Time) ([]*bundle.TeamScore, time.Time) {
-	s.currentScoresMutex.Lock()
-	if s.lastUpdate.After(lastSeenUpdate) {
-		// the last update was after the last seen update, so we can return the current scores without waiting
-		scores := s.currentScoresSorted
-		lastUpdate := s.lastUpdate
-		s.currentScoresMutex.Unlock()
-		return scores, lastUpdate
-	}
-	s.currentScoresMutex.Unlock()
-
 	timeout := time.NewTimer(s.bundle.LongPollDefaultWaitTimeout)
-	ticker := time.NewTicker(50 * time.Millisecond)
 	defer timeout.Stop()
-	defer ticker.Stop()
 
 	for {
-		select {
-		case <-ticker.C:
-			s.currentScoresMutex.Lock()
-			if s.lastUpdate.After(lastSeenUpdate) {
-				scores := s.currentScoresSorted
-				lastUpdate := s.lastUpdate
-				s.currentScoresMutex.Unlock()
-				return scores, lastUpdate
-			}
+		s.currentScoresMutex.Lock()
+		if s.lastUpdate.After(lastSeenUpdate) {
+			// the last update was after the last seen update, so we can return the current scores without waiting
+			scores := s.currentScoresSorted
+			lastUpdate := s.lastUpdate
 			s.currentScoresMutex.Unlock()
+			return scores, lastUpdate
+		}
+		updated := s.updated
+		s.currentScoresMutex.Unlock()
+
+		select {
+		case <-updated:
+			// scores changed, check again
 		case <-timeout.C:
 			// Timeout was reached
 			return nil, time.Time{}
@@ -118,33 +160,24 @@ func (s *ScoringService) WaitForUpdatesNewerThanWithTimestamp(ctx context.Contex
 }
 
 func (s *ScoringService) WaitForTeamUpdatesNewerThan(ctx context.Context, team string, lastSeenUpdate time.Time) *bundle.TeamScore {
-	s.currentScoresMutex.Lock()
-	if score, ok := s.currentScores[team]; ok {
-		if score.LastUpdate.After(lastSeenUpdate) {
-			// the last update was after the last seen update, so we can return the current scores without waiting
-			s.currentScoresMutex.Unlock()
-			return score
-		}
-	}
-	s.currentScoresMutex.Unlock()
-
 	timeout := time.NewTimer(s.bundle.LongPollDefaultWaitTimeout)
-	ticker := time.NewTicker(50 * time.Millisecond)
 	defer timeout.Stop()
-	defer ticker.Stop()
 
 	for {
-		select {
-		case <-ticker.C:
-			s.currentScoresMutex.Lock()
-			if score, ok := s.currentScores[team]; ok {
-				if score.LastUpdate.After(lastSeenUpdate) {
-					// the last update was after the last seen update, so we can return the current scores without waiting
-					s.currentScoresMutex.Unlock()
-					return score
-				}
+		s.currentScoresMutex.Lock()
+		if score, ok := s.currentScores[team]; ok {
+			if score.LastUpdate.After(lastSeenUpdate) {
+				// the last update was after the last seen update, so we can return the current scores without waiting
+				s.currentScoresMutex.Unlock()
+				return score
 			}
-			s.currentScoresMutex.Unlock()
+		}
+		updated := s.updated
+		s.currentScoresMutex.Unlock()
+
+		select {
+		case <-updated:
+			// scores changed, check again
 		case <-timeout.C:
 			// Timeout was reached
 			return nil
@@ -155,102 +188,246 @@ func (s *ScoringService) WaitForTeamUpdatesNewerThan(ctx context.Context, team s
 	}
 }
 
+// StartingScoringWorker keeps the scores up to date by handling changes to the JuiceShop deployments in the shared informer cache. Blocks until ctx is canceled.
 func (s *ScoringService) StartingScoringWorker(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			s.bundle.Log.Info("MultiJuicer context canceled. Exiting the scoring watcher.")
-			return
-		default:
-			s.startScoringWatcher(ctx)
-		}
-	}
-}
-
-func (s *ScoringService) startScoringWatcher(ctx context.Context) {
-	watcher, err := s.bundle.ClientSet.AppsV1().Deployments(s.bundle.RuntimeEnvironment.Namespace).Watch(ctx, metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
+	registration, err := s.bundle.JuiceShopInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			s.handleDeploymentChange(obj.(*appsv1.Deployment))
+		},
+		UpdateFunc: func(_, obj any) {
+			s.handleDeploymentChange(obj.(*appsv1.Deployment))
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if deployment, ok := obj.(*appsv1.Deployment); ok {
+				s.handleDeploymentDeletion(deployment)
+			}
+		},
 	})
-
 	if err != nil {
-		s.bundle.Log.Error("Failed to start the watcher for JuiceShop deployments", "error", err)
+		s.bundle.Log.Error("Failed to register the scoring event handler for JuiceShop deployments", "error", err)
 		panic(err)
 	}
-	defer watcher.Stop()
 
-	for {
-		select {
-		case event, ok := <-watcher.ResultChan():
-			if !ok {
-				s.bundle.Log.Warn("Watcher for JuiceShop deployments has been closed. Restarting the watcher.")
-				return
-			}
-			switch event.Type {
-			case watch.Added, watch.Modified:
-				deployment := event.Object.(*appsv1.Deployment)
-				score := calculateScore(s.bundle, deployment, cachedChallengesMap)
-
-				if currentTeamScore, ok := s.currentScores[score.Name]; ok {
-					if currentTeamScore.EqualsIgnoringLastUpdate(score) {
-						// No need to update, if the score hasn't changed
-						continue
-					}
-				}
-
-				s.currentScoresMutex.Lock()
-				s.currentScores[score.Name] = score
-				s.currentScoresSorted = sortTeamsByScoreAndCalculatePositions(s.currentScores)
-				s.lastUpdate = timeutil.TruncateToMillisecond(time.Now())
-				s.currentScoresMutex.Unlock()
-			case watch.Deleted:
-				deployment := event.Object.(*appsv1.Deployment)
-				team := deployment.Labels["team"]
-				s.currentScoresMutex.Lock()
-				delete(s.currentScores, team)
-				s.currentScoresSorted = sortTeamsByScoreAndCalculatePositions(s.currentScores)
-				s.lastUpdate = timeutil.TruncateToMillisecond(time.Now())
-				s.currentScoresMutex.Unlock()
-			default:
-			}
-		case <-ctx.Done():
-			s.bundle.Log.Info("MultiJuicer context canceled. Exiting the scoring watcher.")
+	<-ctx.Done()
+	s.bundle.Log.Info("MultiJuicer context canceled. Exiting the scoring watcher.")
+	if err := s.bundle.JuiceShopInformer.RemoveEventHandler(registration); err != nil {
+		s.bundle.Log.Warn("Failed to remove the scoring event handler", "error", err)
+	}
+}
+
+func (s *ScoringService) handleDeploymentChange(deployment *appsv1.Deployment) {
+	score := calculateScore(s.bundle, deployment, cachedChallengesMap)
+	setCheatDecisionGauge(score)
+
+	s.currentScoresMutex.Lock()
+	score.Challenges, score.Score = s.scoreTeam(score)
+	if currentTeamScore, ok := s.currentScores[score.Name]; ok {
+		if currentTeamScore.EqualsIgnoringLastUpdate(score) {
+			// No need to update, if the score hasn't changed
+			s.currentScoresMutex.Unlock()
 			return
 		}
 	}
+
+	s.currentScores[score.Name] = score
+	s.lastUpdate = timeutil.TruncateToMillisecond(time.Now())
+	changedTeams := s.recalculateScores(s.lastUpdate)
+	s.currentScoresMutex.Unlock()
+	s.publishChanges(append(changedTeams, score.Name))
+}
+
+func (s *ScoringService) handleDeploymentDeletion(deployment *appsv1.Deployment) {
+	team := deployment.Labels["team"]
+	cheatDecisionGauge.DeleteLabelValues(team)
+	s.currentScoresMutex.Lock()
+	delete(s.currentScores, team)
+	s.lastUpdate = timeutil.TruncateToMillisecond(time.Now())
+	changedTeams := s.recalculateScores(s.lastUpdate)
+	s.currentScoresMutex.Unlock()
+	s.publishChanges(append(changedTeams, team))
 }
 
 func (s *ScoringService) CalculateAndCacheScoreBoard(context context.Context) error {
 	// Get all JuiceShop instances
-	juiceShops, err := getDeployments(context, s.bundle)
+	juiceShops, err := getDeployments(s.bundle)
 	if err != nil {
 		return err
 	}
 
 	// Calculate the new scores
 	s.currentScoresMutex.Lock()
-	for _, juiceShop := range juiceShops.Items {
-		score := calculateScore(s.bundle, &juiceShop, s.challengesMap)
+	for _, juiceShop := range juiceShops {
+		score := calculateScore(s.bundle, juiceShop, s.challengesMap)
+		setCheatDecisionGauge(score)
 		s.currentScores[score.Name] = score
 	}
-	s.currentScoresSorted = sortTeamsByScoreAndCalculatePositions(s.currentScores)
+	s.recalculateScores(timeutil.TruncateToMillisecond(time.Now()))
 	s.currentScoresMutex.Unlock()
 
 	return nil
 }
 
-func getDeployments(context context.Context, bundle *bundle.Bundle) (*appsv1.DeploymentList, error) {
-	deployments, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).List(context, metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-	})
-	if err != nil {
-		return nil, err
+// recalculateScores updates the solve counts and solve order and re-evaluates the score of every team with the configured scoring strategy, as a single solve can change the value and bonus of a challenge for all teams which solved it.
+// Teams whose score changed get their LastUpdate bumped to updateTime so that long polling clients get notified. Returns the names of these teams.
+// Must be called while holding the currentScoresMutex.
+func (s *ScoringService) recalculateScores(updateTime time.Time) []string {
+	s.solveCounts = countSolves(s.currentScores)
+	s.solveRanks = rankSolves(s.currentScores)
+
+	changedTeams := []string{}
+	for team, teamScore := range s.currentScores {
+		challenges, score := s.scoreTeam(teamScore)
+		if score == teamScore.Score && bonusesEqual(challenges, teamScore.Challenges) {
+			continue
+		}
+		// copy the score, so that handlers still holding the previous one don't observe a partial update
+		updatedTeamScore := *teamScore
+		updatedTeamScore.Score = score
+		updatedTeamScore.Challenges = challenges
+		if updateTime.After(updatedTeamScore.LastUpdate) {
+			updatedTeamScore.LastUpdate = updateTime
+		}
+		s.currentScores[team] = &updatedTeamScore
+		changedTeams = append(changedTeams, team)
+	}
+
+	s.currentScoresSorted = sortTeamsByScoreAndCalculatePositions(s.currentScores)
+	s.notifyWaiters()
+	return changedTeams
+}
+
+// notifyWaiters wakes up all requests waiting for score updates. Must be called while holding the currentScoresMutex.
+func (s *ScoringService) notifyWaiters() {
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// publishChanges notifies server-sent event subscribers about a score-board update. Must be called without holding the currentScoresMutex.
+func (s *ScoringService) publishChanges(changedTeams []string) {
+	s.bundle.Broker.Publish(longpoll.TopicScoreBoard)
+	s.bundle.Broker.Publish(longpoll.TopicActivityFeed)
+	for _, team := range changedTeams {
+		s.bundle.Broker.Publish(longpoll.TopicTeamStatus(team))
+	}
+}
+
+// scoreTeam calculates the score of a team: the points for all solved challenges, minus the cost of unlocked hints, plus the adjustments of admins.
+// Returns a copy of the challenges with the Bonus of each solve filled in. Must be called while holding the currentScoresMutex.
+func (s *ScoringService) scoreTeam(teamScore *bundle.TeamScore) ([]bundle.ChallengeProgress, int) {
+	challenges, score := s.scoreChallenges(teamScore.Name, teamScore.Challenges)
+	for _, hint := range teamScore.Hints {
+		score -= hint.Cost
+	}
+	for _, adjustment := range teamScore.Adjustments {
+		score += adjustment.Points
+	}
+	return challenges, score
+}
+
+// scoreChallenges calculates the score for the given solved challenges of a team, including the early solve bonus.
+// Returns a copy of the challenges with the Bonus of each solve filled in. Must be called while holding the currentScoresMutex.
+func (s *ScoringService) scoreChallenges(team string, challenges []bundle.ChallengeProgress) ([]bundle.ChallengeProgress, int) {
+	bonusPercentages := s.bundle.Config.ScoringConfig.EarlySolveBonusPercentages
+
+	score := 0
+	scoredChallenges := make([]bundle.ChallengeProgress, len(challenges))
+	for i, challengeSolved := range challenges {
+		scoredChallenges[i] = challengeSolved
+		scoredChallenges[i].Bonus = 0
+
+		challenge, ok := s.challengesMap[challengeSolved.Key]
+		if !ok {
+			continue
+		}
+		points := s.strategy.ChallengePoints(challenge, s.solveCounts[challengeSolved.Key])
+		if rank, ok := s.solveRanks[challengeSolved.Key][team]; ok && rank < len(bonusPercentages) {
+			scoredChallenges[i].Bonus = points * bonusPercentages[rank] / 100
+		}
+		score += points + scoredChallenges[i].Bonus
+	}
+	return scoredChallenges, score
+}
+
+// countSolves counts the teams which solved each challenge. Solves of disqualified teams don't lower the value of challenges under the dynamic scoring strategy.
+func countSolves(teamScores map[string]*bundle.TeamScore) map[string]int {
+	solveCounts := make(map[string]int)
+	for _, teamScore := range teamScores {
+		if teamScore.CheatDecision == bundle.CheatDecisionDisqualified {
+			continue
+		}
+		for _, challenge := range teamScore.Challenges {
+			solveCounts[challenge.Key]++
+		}
+	}
+	return solveCounts
+}
+
+// rankSolves determines the order in which teams solved each challenge. Simultaneous solves are ordered by team name for consistency.
+// Disqualified teams don't take early solve bonuses away from the other teams.
+func rankSolves(teamScores map[string]*bundle.TeamScore) map[string]map[string]int {
+	type solve struct {
+		team     string
+		solvedAt time.Time
+	}
+	solvesByChallenge := make(map[string][]solve)
+	for team, teamScore := range teamScores {
+		if teamScore.CheatDecision == bundle.CheatDecisionDisqualified {
+			continue
+		}
+		for _, challenge := range teamScore.Challenges {
+			solvesByChallenge[challenge.Key] = append(solvesByChallenge[challenge.Key], solve{team: team, solvedAt: challenge.SolvedAt})
+		}
 	}
-	return deployments, nil
+
+	solveRanks := make(map[string]map[string]int, len(solvesByChallenge))
+	for challengeKey, solves := range solvesByChallenge {
+		sort.Slice(solves, func(i, j int) bool {
+			if solves[i].solvedAt.Equal(solves[j].solvedAt) {
+				return solves[i].team < solves[j].team
+			}
+			return solves[i].solvedAt.Before(solves[j].solvedAt)
+		})
+		ranks := make(map[string]int, len(solves))
+		for rank, solve := range solves {
+			ranks[solve.team] = rank
+		}
+		solveRanks[challengeKey] = ranks
+	}
+	return solveRanks
+}
+
+func bonusesEqual(a, b []bundle.ChallengeProgress) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Bonus != b[i].Bonus {
+			return false
+		}
+	}
+	return true
 }
 
+func getDeployments(bundle *bundle.Bundle) ([]*appsv1.Deployment, error) {
+	return bundle.JuiceShopLister.List(labels.Everything())
+}
+
+// calculateScore parses the solved challenges persisted on the team deployment. The Score itself is left at 0 and gets filled in by the ScoringService, as it depends on the configured scoring strategy and the progress of all other teams.
 func calculateScore(b *bundle.Bundle, teamDeployment *appsv1.Deployment, challengesMap map[string](bundle.JuiceShopChallenge)) *bundle.TeamScore {
 	solvedChallengesString := teamDeployment.Annotations["multi-juicer.owasp-juice.shop/challenges"]
 	team := teamDeployment.Labels["team"]
+	hints, err := ParseHintUnlocks(teamDeployment)
+	if err != nil {
+		b.Log.Warn("JuiceShop deployment has an invalid hints annotation. Assuming no unlocked hints.", "team", team)
+	}
+	adjustments, err := ParseScoreAdjustments(teamDeployment)
+	if err != nil {
+		b.Log.Warn("JuiceShop deployment has an invalid adjustments annotation. Assuming no score adjustments.", "team", team)
+	}
+	cheatDecision := b.Config.ScoringConfig.CheatScore.Decide(parseLatestCheatScore(b, teamDeployment))
 	if solvedChallengesString == "" {
 		return &bundle.TeamScore{
 			Name:              team,
@@ -258,11 +435,14 @@ func calculateScore(b *bundle.Bundle, teamDeployment *appsv1.Deployment, challen
 			Challenges:        []bundle.ChallengeProgress{},
 			InstanceReadiness: teamDeployment.Status.ReadyReplicas > 0,
 			LastUpdate:        timeutil.TruncateToMillisecond(time.Now()),
+			Hints:             hints,
+			Adjustments:       adjustments,
+			CheatDecision:     cheatDecision,
 		}
 	}
 
 	solvedChallenges := []bundle.ChallengeProgress{}
-	err := json.Unmarshal([]byte(solvedChallengesString), &solvedChallenges)
+	err = json.Unmarshal([]byte(solvedChallengesString), &solvedChallenges)
 
 	if err != nil {
 		b.Log.Warn("JuiceShop deployment has an invalid challenges annotation. Assuming 0 solved challenges.", "team", team)
@@ -272,28 +452,85 @@ func calculateScore(b *bundle.Bundle, teamDeployment *appsv1.Deployment, challen
 			Challenges:        []bundle.ChallengeProgress{},
 			InstanceReadiness: teamDeployment.Status.ReadyReplicas > 0,
 			LastUpdate:        timeutil.TruncateToMillisecond(time.Now()),
+			Hints:             hints,
+			Adjustments:       adjustments,
+			CheatDecision:     cheatDecision,
 		}
 	}
 
-	score := 0
 	solvedChallengeNames := []bundle.ChallengeProgress{}
 	for _, challengeSolved := range solvedChallenges {
-		challenge, ok := challengesMap[challengeSolved.Key]
-		if !ok {
+		if b.ExcludedChallengeKeys[challengeSolved.Key] {
+			// excluded from the event via the challengeFilter config, doesn't score
+			continue
+		}
+		if _, ok := challengesMap[challengeSolved.Key]; !ok {
+			if version := teamDeployment.Labels["app.kubernetes.io/version"]; version != "" && version != b.Config.JuiceShopConfig.Tag {
+				// expected for teams running another JuiceShop version via their instance overrides, only the challenges known to MultiJuicer score
+				b.Log.Debug("JuiceShop deployment has a solved challenge of another JuiceShop version. Skipping it.", "team", team, "challenge", challengeSolved.Key, "version", version)
+				continue
+			}
 			b.Log.Warn("JuiceShop deployment has a solved challenge not in the challenges map. The JuiceShop version might be incompatible.", "team", team, "challenge", challengeSolved.Key)
 			continue
 		}
-		score += challenge.Difficulty * 10
 		solvedChallengeNames = append(solvedChallengeNames, challengeSolved)
 	}
 
 	return &bundle.TeamScore{
 		Name:              team,
-		Score:             score,
+		Score:             0,
 		Challenges:        solvedChallengeNames,
 		InstanceReadiness: teamDeployment.Status.ReadyReplicas > 0,
 		LastUpdate:        timeutil.TruncateToMillisecond(time.Now()),
+		Hints:             hints,
+		Adjustments:       adjustments,
+		CheatDecision:     cheatDecision,
+	}
+}
+
+// ParseHintUnlocks decodes the hints a team has unlocked from the hints annotation of its deployment
+func ParseHintUnlocks(teamDeployment *appsv1.Deployment) ([]bundle.HintUnlock, error) {
+	hintsString := teamDeployment.Annotations["multi-juicer.owasp-juice.shop/hints"]
+	if hintsString == "" {
+		return nil, nil
+	}
+	var hints []bundle.HintUnlock
+	if err := json.Unmarshal([]byte(hintsString), &hints); err != nil {
+		return nil, fmt.Errorf("failed to decode hints annotation: %w", err)
+	}
+	return hints, nil
+}
+
+// ParseScoreAdjustments decodes the score adjustments of a team from the adjustments annotation of its deployment
+func ParseScoreAdjustments(teamDeployment *appsv1.Deployment) ([]bundle.ScoreAdjustment, error) {
+	adjustmentsString := teamDeployment.Annotations["multi-juicer.owasp-juice.shop/adjustments"]
+	if adjustmentsString == "" {
+		return nil, nil
+	}
+	var adjustments []bundle.ScoreAdjustment
+	if err := json.Unmarshal([]byte(adjustmentsString), &adjustments); err != nil {
+		return nil, fmt.Errorf("failed to decode adjustments annotation: %w", err)
 	}
+	return adjustments, nil
+}
+
+// parseLatestCheatScore returns the newest total cheat score the JuiceShop of the team reported, 0 if it didn't report one yet
+func parseLatestCheatScore(b *bundle.Bundle, teamDeployment *appsv1.Deployment) float64 {
+	cheatScoresString := teamDeployment.Annotations["multi-juicer.owasp-juice.shop/cheatScores"]
+	if cheatScoresString == "" {
+		return 0
+	}
+	var cheatScores []struct {
+		TotalCheatScore float64 `json:"totalCheatScore"`
+	}
+	if err := json.Unmarshal([]byte(cheatScoresString), &cheatScores); err != nil {
+		b.Log.Warn("JuiceShop deployment has an invalid cheatScores annotation. Assuming a cheat score of 0.", "team", teamDeployment.Labels["team"])
+		return 0
+	}
+	if len(cheatScores) == 0 {
+		return 0
+	}
+	return cheatScores[len(cheatScores)-1].TotalCheatScore
 }
 
 func getLatestChallengeSolve(challenges []bundle.ChallengeProgress) time.Time {
@@ -306,13 +543,15 @@ func getLatestChallengeSolve(challenges []bundle.ChallengeProgress) time.Time {
 	return maxTime
 }
 
+// sortTeamsByScoreAndCalculatePositions returns the teams ranked by score. Disqualified teams are left out and get position 0.
 func sortTeamsByScoreAndCalculatePositions(teamScores map[string]*bundle.TeamScore) []*bundle.TeamScore {
-	sortedTeamScores := make([]*bundle.TeamScore, len(teamScores))
-
-	i := 0
+	sortedTeamScores := make([]*bundle.TeamScore, 0, len(teamScores))
 	for _, teamScore := range teamScores {
-		sortedTeamScores[i] = teamScore
-		i++
+		if teamScore.CheatDecision == bundle.CheatDecisionDisqualified {
+			teamScore.Position = 0
+			continue
+		}
+		sortedTeamScores = append(sortedTeamScores, teamScore)
 	}
 
 	sort.Slice(sortedTeamScores, func(i, j int) bool {
@@ -0,0 +1,113 @@
+//go:build unix
+
+package scoring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/testutil"
+	"github.com/juice-shop/multi-juicer/internal/timeutil"
+)
+
+const benchmarkWaiters = 2000
+
+// cpuSeconds returns the user and system CPU time used by the process so far
+func cpuSeconds() float64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		panic(err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()).Seconds()
+}
+
+func newBenchmarkScoringService() *ScoringService {
+	b := testutil.NewTestBundle()
+	b.LongPollDefaultWaitTimeout = time.Hour
+	scores := map[string]*bundle.TeamScore{}
+	for i := range benchmarkWaiters {
+		team := fmt.Sprintf("team-%d", i)
+		scores[team] = &bundle.TeamScore{Name: team, Challenges: []bundle.ChallengeProgress{}, LastUpdate: time.Now()}
+	}
+	return NewScoringServiceWithInitialScores(b, scores)
+}
+
+// startWaiters starts benchmarkWaiters long polling requests, half of them waiting for the score-board, half for their team. Blocks until all of them are waiting.
+func startWaiters(ctx context.Context, s *ScoringService, lastSeenUpdate time.Time) *sync.WaitGroup {
+	var started, done sync.WaitGroup
+	for i := range benchmarkWaiters {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			started.Done()
+			if i%2 == 0 {
+				s.WaitForUpdatesNewerThanWithTimestamp(ctx, lastSeenUpdate)
+			} else {
+				s.WaitForTeamUpdatesNewerThan(ctx, fmt.Sprintf("team-%d", i), lastSeenUpdate)
+			}
+		}()
+	}
+	started.Wait()
+	// give the goroutines time to actually block in the select
+	time.Sleep(50 * time.Millisecond)
+	return &done
+}
+
+// BenchmarkIdleWaiters measures the CPU time used while 2,000 long polling requests wait during a quiet period without any score changes.
+func BenchmarkIdleWaiters(b *testing.B) {
+	s := newBenchmarkScoringService()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startWaiters(ctx, s, time.Now().Add(time.Hour))
+
+	const idlePeriod = 100 * time.Millisecond
+	cpuBefore := cpuSeconds()
+	iterations := 0
+	for b.Loop() {
+		time.Sleep(idlePeriod)
+		iterations++
+	}
+	cpuUsed := cpuSeconds() - cpuBefore
+	b.ReportMetric(cpuUsed*1000/float64(iterations), "cpu-ms/100ms-idle")
+
+	cancel()
+	done.Wait()
+}
+
+// BenchmarkWakeWaiters measures how long it takes to wake up 2,000 waiting long polling requests after the scores changed.
+func BenchmarkWakeWaiters(b *testing.B) {
+	s := newBenchmarkScoringService()
+
+	cpuUsed := 0.0
+	for b.Loop() {
+		b.StopTimer()
+		s.currentScoresMutex.Lock()
+		lastSeenUpdate := s.lastUpdate
+		s.currentScoresMutex.Unlock()
+		done := startWaiters(context.Background(), s, lastSeenUpdate)
+		cpuBefore := cpuSeconds()
+		b.StartTimer()
+
+		s.currentScoresMutex.Lock()
+		updateTime := timeutil.TruncateToMillisecond(time.Now()).Add(time.Millisecond)
+		for team, score := range s.currentScores {
+			updatedScore := *score
+			updatedScore.LastUpdate = updateTime
+			s.currentScores[team] = &updatedScore
+		}
+		s.lastUpdate = updateTime
+		s.recalculateScores(updateTime)
+		s.currentScoresMutex.Unlock()
+		done.Wait()
+
+		b.StopTimer()
+		cpuUsed += cpuSeconds() - cpuBefore
+		b.StartTimer()
+	}
+	b.ReportMetric(cpuUsed*1000/float64(b.N), "cpu-ms/wake")
+}
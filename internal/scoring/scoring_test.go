@@ -12,9 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes/fake"
-	testcore "k8s.io/client-go/testing"
 )
 
 func withoutTimestamps(challenges []*b.TeamScore) []*b.TeamScore {
@@ -199,6 +197,142 @@ func TestScoreingService(t *testing.T) {
 		}, withoutTimestamps(scores))
 	})
 
+	t.Run("challenges excluded by the challenge filter don't score", func(t *testing.T) {
+		clientset := fake.NewClientset(
+			createTeam("foobar", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"},{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "2"),
+		)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.JuiceShopChallenges, bundle.ExcludedChallengeKeys = b.FilterChallenges(bundle.JuiceShopChallenges, b.ChallengeFilter{MaxDifficulty: 3})
+
+		scoringService := NewScoringService(bundle)
+		err := scoringService.CalculateAndCacheScoreBoard(context.Background())
+		assert.Nil(t, err)
+
+		assert.Equal(t, []*b.TeamScore{
+			{
+				Name:     "foobar",
+				Score:    10,
+				Position: 1,
+				Challenges: []b.ChallengeProgress{
+					{
+						Key:      "scoreBoardChallenge",
+						SolvedAt: novemberFirst,
+					},
+				},
+				InstanceReadiness: true,
+			},
+		}, withoutTimestamps(scoringService.GetTopScores()))
+	})
+
+	t.Run("dynamic scoring lowers the score of all solvers when another team solves the same challenge", func(t *testing.T) {
+		clientset := fake.NewClientset(
+			createTeam("foobar", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "1"),
+		)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.ScoringConfig = b.ScoringConfig{
+			Strategy: b.ScoringStrategyDynamic,
+			Dynamic:  b.DynamicScoringConfig{Max: 500, Min: 100, Decay: 10},
+		}
+		scoringService := NewScoringService(bundle)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		err := scoringService.CalculateAndCacheScoreBoard(ctx)
+		assert.Nil(t, err)
+
+		score, ok := scoringService.GetScoreForTeam("foobar")
+		assert.True(t, ok)
+		assert.Equal(t, 500, score.Score)
+		assert.Equal(t, 500, scoringService.GetChallengePoints("scoreBoardChallenge"))
+		assert.Equal(t, 500, scoringService.GetChallengePoints("nullByteChallenge"))
+
+		go scoringService.StartingScoringWorker(ctx)
+		_, err = clientset.AppsV1().Deployments("test-namespace").Create(ctx, createTeam("barfoo", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:56:48.211Z"}]`, "1"), metav1.CreateOptions{})
+		assert.Nil(t, err)
+
+		assert.Eventually(t, func() bool {
+			foobar, _ := scoringService.GetScoreForTeam("foobar")
+			barfoo, ok := scoringService.GetScoreForTeam("barfoo")
+			return ok && foobar.Score == 496 && barfoo.Score == 496
+		}, 1*time.Second, 10*time.Millisecond)
+		assert.Equal(t, 496, scoringService.GetChallengePoints("scoreBoardChallenge"))
+	})
+
+	t.Run("awards early solve bonuses to the first solvers of a challenge", func(t *testing.T) {
+		clientset := fake.NewClientset(
+			createTeam("first", `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "1"),
+			createTeam("second", `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:56:48.211Z"}]`, "1"),
+			createTeam("third", `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:57:48.211Z"}]`, "1"),
+			createTeam("fourth", `[{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:58:48.211Z"}]`, "1"),
+		)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.ScoringConfig.EarlySolveBonusPercentages = []int{50, 25, 10}
+
+		scoringService := NewScoringService(bundle)
+		err := scoringService.CalculateAndCacheScoreBoard(context.Background())
+		assert.Nil(t, err)
+
+		type teamResult struct {
+			Name     string
+			Score    int
+			Bonus    int
+			Position int
+		}
+		results := []teamResult{}
+		for _, score := range scoringService.GetTopScores() {
+			results = append(results, teamResult{Name: score.Name, Score: score.Score, Bonus: score.Challenges[0].Bonus, Position: score.Position})
+		}
+
+		assert.Equal(t, []teamResult{
+			{Name: "first", Score: 60, Bonus: 20, Position: 1},
+			{Name: "second", Score: 50, Bonus: 10, Position: 2},
+			{Name: "third", Score: 44, Bonus: 4, Position: 3},
+			{Name: "fourth", Score: 40, Bonus: 0, Position: 4},
+		}, results)
+	})
+
+	t.Run("flags teams by their latest cheat score and hides disqualified teams from the top scores", func(t *testing.T) {
+		withCheatScores := func(deployment *appsv1.Deployment, cheatScores string) *appsv1.Deployment {
+			deployment.Annotations["multi-juicer.owasp-juice.shop/cheatScores"] = cheatScores
+			return deployment
+		}
+		clientset := fake.NewClientset(
+			withCheatScores(createTeam("cheater", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"},{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "2"), `[{"totalCheatScore":0.1,"timestamp":"2024-11-01T19:50:00Z"},{"totalCheatScore":0.9,"timestamp":"2024-11-01T19:55:48Z"}]`),
+			withCheatScores(createTeam("lucky", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:56:00.000Z"}]`, "1"), `[{"totalCheatScore":0.6,"timestamp":"2024-11-01T19:56:00Z"}]`),
+			createTeam("honest", `[]`, "0"),
+		)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.ScoringConfig.EarlySolveBonusPercentages = []int{50}
+		bundle.Config.ScoringConfig.CheatScore = b.CheatScoreConfig{SuspiciousThreshold: 0.5, DisqualifyThreshold: 0.8}
+
+		scoringService := NewScoringService(bundle)
+		err := scoringService.CalculateAndCacheScoreBoard(context.Background())
+		assert.Nil(t, err)
+
+		type teamResult struct {
+			Name          string
+			Score         int
+			Position      int
+			CheatDecision string
+		}
+		results := []teamResult{}
+		for _, score := range scoringService.GetTopScores() {
+			results = append(results, teamResult{Name: score.Name, Score: score.Score, Position: score.Position, CheatDecision: score.CheatDecision})
+		}
+		assert.Equal(t, []teamResult{
+			// the first blood of the disqualified team doesn't count
+			{Name: "lucky", Score: 15, Position: 1, CheatDecision: b.CheatDecisionSuspicious},
+			{Name: "honest", Score: 0, Position: 2},
+		}, results)
+
+		cheater, ok := scoringService.GetScoreForTeam("cheater")
+		assert.True(t, ok)
+		assert.Equal(t, b.CheatDecisionDisqualified, cheater.CheatDecision)
+		assert.Equal(t, 0, cheater.Position)
+		assert.Len(t, cheater.Challenges, 2)
+	})
+
 	t.Run("properly sets readiness", func(t *testing.T) {
 		clientset := fake.NewClientset(
 			createTeamWithInstanceReadiness("foobar", `[]`, "0", false),
@@ -241,9 +375,8 @@ func TestScoreingService(t *testing.T) {
 		assert.True(t, ok)
 		assert.Equal(t, 10, score.Score)
 
-		watcher := watch.NewFake()
-		clientset.PrependWatchReactor("deployments", testcore.DefaultWatchReactor(watcher, nil))
-		watcher.Modify(createTeam("foobar", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"},{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "2"))
+		_, err = clientset.AppsV1().Deployments("test-namespace").Update(ctx, createTeam("foobar", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"},{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "2"), metav1.UpdateOptions{})
+		assert.Nil(t, err)
 
 		assert.Eventually(t, func() bool {
 			score, ok := scoringService.GetScoreForTeam("foobar")
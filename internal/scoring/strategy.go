@@ -0,0 +1,102 @@
+package scoring
+
+import (
+	"math"
+	"strings"
+
+	"github.com/juice-shop/multi-juicer/internal/bundle"
+)
+
+// ScoringStrategy decides how many points a solved challenge is worth.
+// Every team that solved a challenge receives the same amount of points for it, so strategies which depend on the solveCount (e.g. dynamic scoring) retroactively change the score of earlier solvers as well.
+type ScoringStrategy interface {
+	ChallengePoints(challenge bundle.JuiceShopChallenge, solveCount int) int
+}
+
+// NewScoringStrategy returns the strategy selected in the scoring config. Unknown strategies fall back to the static strategy.
+// Configured point overrides and category multipliers are applied on top of the selected strategy.
+func NewScoringStrategy(config bundle.ScoringConfig) ScoringStrategy {
+	var strategy ScoringStrategy
+	switch config.Strategy {
+	case bundle.ScoringStrategyDynamic:
+		strategy = &DynamicScoringStrategy{Max: config.Dynamic.Max, Min: config.Dynamic.Min, Decay: config.Dynamic.Decay}
+	case bundle.ScoringStrategyFlat:
+		strategy = &FlatScoringStrategy{Points: config.Flat.Points}
+	default:
+		strategy = &StaticScoringStrategy{}
+	}
+
+	if len(config.PointOverrides) == 0 && len(config.CategoryMultipliers) == 0 {
+		return strategy
+	}
+	categoryMultipliers := make(map[string]float64, len(config.CategoryMultipliers))
+	for category, multiplier := range config.CategoryMultipliers {
+		categoryMultipliers[strings.ToLower(category)] = multiplier
+	}
+	return &WeightedScoringStrategy{
+		Strategy:            strategy,
+		PointOverrides:      config.PointOverrides,
+		CategoryMultipliers: categoryMultipliers,
+	}
+}
+
+// WeightedScoringStrategy adjusts the points of another strategy.
+// Challenges with a point override are always worth the overridden points, the points of all other challenges are multiplied by the multiplier of their category.
+type WeightedScoringStrategy struct {
+	Strategy       ScoringStrategy
+	PointOverrides map[string]int
+	// CategoryMultipliers is keyed by the lower case category name
+	CategoryMultipliers map[string]float64
+}
+
+func (s *WeightedScoringStrategy) ChallengePoints(challenge bundle.JuiceShopChallenge, solveCount int) int {
+	if points, ok := s.PointOverrides[challenge.Key]; ok {
+		return points
+	}
+	points := s.Strategy.ChallengePoints(challenge, solveCount)
+	if multiplier, ok := s.CategoryMultipliers[strings.ToLower(challenge.Category)]; ok {
+		return int(math.Round(float64(points) * multiplier))
+	}
+	return points
+}
+
+// StaticScoringStrategy awards difficulty × 10 points per challenge
+type StaticScoringStrategy struct{}
+
+func (s *StaticScoringStrategy) ChallengePoints(challenge bundle.JuiceShopChallenge, solveCount int) int {
+	return challenge.Difficulty * 10
+}
+
+// FlatScoringStrategy awards the same amount of points for every challenge
+type FlatScoringStrategy struct {
+	Points int
+}
+
+func (s *FlatScoringStrategy) ChallengePoints(challenge bundle.JuiceShopChallenge, solveCount int) int {
+	return s.Points
+}
+
+// DynamicScoringStrategy implements the CTFd style decaying challenge value.
+// The first solve is worth Max points, the value then decreases quadratically with every additional solve until it reaches Min after Decay solves.
+type DynamicScoringStrategy struct {
+	Max   int
+	Min   int
+	Decay int
+}
+
+func (s *DynamicScoringStrategy) ChallengePoints(challenge bundle.JuiceShopChallenge, solveCount int) int {
+	// the first solve doesn't decay the value
+	if solveCount > 0 {
+		solveCount--
+	}
+	if s.Decay <= 0 {
+		return s.Min
+	}
+
+	value := (float64(s.Min-s.Max)/float64(s.Decay*s.Decay))*float64(solveCount*solveCount) + float64(s.Max)
+	points := int(math.Ceil(value))
+	if points < s.Min {
+		return s.Min
+	}
+	return points
+}
@@ -0,0 +1,57 @@
+package scoring
+
+import (
+	"testing"
+
+	b "github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoringStrategies(t *testing.T) {
+	challenge := b.JuiceShopChallenge{Key: "nullByteChallenge", Difficulty: 4}
+
+	t.Run("static strategy awards difficulty times ten regardless of solve count", func(t *testing.T) {
+		strategy := NewScoringStrategy(b.ScoringConfig{Strategy: b.ScoringStrategyStatic})
+		assert.Equal(t, 40, strategy.ChallengePoints(challenge, 0))
+		assert.Equal(t, 40, strategy.ChallengePoints(challenge, 100))
+	})
+
+	t.Run("empty strategy falls back to static", func(t *testing.T) {
+		strategy := NewScoringStrategy(b.ScoringConfig{})
+		assert.Equal(t, 40, strategy.ChallengePoints(challenge, 3))
+	})
+
+	t.Run("flat strategy awards the same points for every challenge", func(t *testing.T) {
+		strategy := NewScoringStrategy(b.ScoringConfig{Strategy: b.ScoringStrategyFlat, Flat: b.FlatScoringConfig{Points: 1}})
+		assert.Equal(t, 1, strategy.ChallengePoints(challenge, 0))
+		assert.Equal(t, 1, strategy.ChallengePoints(b.JuiceShopChallenge{Difficulty: 6}, 42))
+	})
+
+	t.Run("dynamic strategy decays from max to min", func(t *testing.T) {
+		strategy := NewScoringStrategy(b.ScoringConfig{
+			Strategy: b.ScoringStrategyDynamic,
+			Dynamic:  b.DynamicScoringConfig{Max: 500, Min: 100, Decay: 10},
+		})
+
+		assert.Equal(t, 500, strategy.ChallengePoints(challenge, 0))
+		assert.Equal(t, 500, strategy.ChallengePoints(challenge, 1), "first solve should not decay the value")
+		assert.Equal(t, 496, strategy.ChallengePoints(challenge, 2))
+		assert.Equal(t, 400, strategy.ChallengePoints(challenge, 6))
+		assert.Equal(t, 100, strategy.ChallengePoints(challenge, 11))
+		assert.Equal(t, 100, strategy.ChallengePoints(challenge, 500), "value should never drop below min")
+	})
+
+	t.Run("point overrides and category multipliers adjust the points of the strategy", func(t *testing.T) {
+		strategy := NewScoringStrategy(b.ScoringConfig{
+			Strategy:            b.ScoringStrategyStatic,
+			PointOverrides:      map[string]int{"nullByteChallenge": 75},
+			CategoryMultipliers: map[string]float64{"broken access control": 2, "XSS": 1.25},
+		})
+
+		assert.Equal(t, 75, strategy.ChallengePoints(challenge, 0), "overrides replace the strategy value")
+		assert.Equal(t, 75, strategy.ChallengePoints(b.JuiceShopChallenge{Key: "nullByteChallenge", Category: "Broken Access Control", Difficulty: 4}, 0), "overrides are not multiplied")
+		assert.Equal(t, 60, strategy.ChallengePoints(b.JuiceShopChallenge{Key: "adminSectionChallenge", Category: "Broken Access Control", Difficulty: 3}, 0))
+		assert.Equal(t, 13, strategy.ChallengePoints(b.JuiceShopChallenge{Key: "localXssChallenge", Category: "XSS", Difficulty: 1}, 0))
+		assert.Equal(t, 20, strategy.ChallengePoints(b.JuiceShopChallenge{Key: "loginAdminChallenge", Category: "Injection", Difficulty: 2}, 0))
+	})
+}
@@ -1,18 +1,27 @@
 package testutil
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
 	"time"
 
+	"github.com/juice-shop/multi-juicer/internal/application"
 	"github.com/juice-shop/multi-juicer/internal/bundle"
+	"github.com/juice-shop/multi-juicer/internal/eventlog"
+	"github.com/juice-shop/multi-juicer/internal/longpoll"
 	"github.com/juice-shop/multi-juicer/internal/signutil"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 // UIBuildDir returns the absolute path to the repo's ui/build directory,
@@ -31,8 +40,11 @@ func NewTestBundle() *bundle.Bundle {
 var testSigningKey = "test-signing-key"
 
 func NewTestBundleWithCustomFakeClient(clientset kubernetes.Interface) *bundle.Bundle {
-	return &bundle.Bundle{
-		ClientSet:             clientset,
+	testBundle := &bundle.Bundle{
+		ClientSet: clientset,
+		DynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(k8sruntime.NewScheme(), map[schema.GroupVersionResource]string{
+			bundle.JuiceShopInstanceResource: "JuiceShopInstanceList",
+		}),
 		StaticAssetsDirectory: UIBuildDir(),
 		RuntimeEnvironment: bundle.RuntimeEnvironment{
 			Namespace: "test-namespace",
@@ -62,6 +74,7 @@ func NewTestBundleWithCustomFakeClient(clientset kubernetes.Interface) *bundle.B
 		BcryptRounds:               2,
 		Log:                        slog.New(slog.NewTextHandler(os.Stdout, nil)),
 		LongPollDefaultWaitTimeout: 3 * time.Second,
+		Broker:                     longpoll.NewBroker(),
 		Config: &bundle.Config{
 			MaxInstances: 100,
 			JuiceShopConfig: bundle.JuiceShopConfig{
@@ -90,6 +103,34 @@ func NewTestBundleWithCustomFakeClient(clientset kubernetes.Interface) *bundle.B
 			},
 		},
 	}
+	testBundle.Application = application.NewJuiceShop(testBundle)
+	testBundle.EventLog = eventlog.NewEventLog(testBundle)
+
+	// the informer runs for the remainder of the test binary, tests don't share bundles so this only costs a goroutine per bundle
+	testBundle.JuiceShopInformer, testBundle.JuiceShopLister = bundle.NewJuiceShopInformer(clientset, testBundle.RuntimeEnvironment.Namespace)
+	go testBundle.JuiceShopInformer.Run(make(chan struct{}))
+	if !cache.WaitForCacheSync(nil, testBundle.JuiceShopInformer.HasSynced) {
+		panic("failed to sync JuiceShop informer cache")
+	}
+	// reset the list and watch calls of the informer, so that tests only see the actions of the code under test
+	if fakeClientset, ok := clientset.(*fake.Clientset); ok {
+		fakeClientset.ClearActions()
+	}
+	return testBundle
+}
+
+// WaitForJuiceShopDeployment blocks until the informer cache of the bundle contains the deployment of the team in a state matching the condition.
+// Use it after modifying deployments through the clientset, when the code under test reads them from the cache.
+func WaitForJuiceShopDeployment(b *bundle.Bundle, team string, condition func(deployment *appsv1.Deployment) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deployment, err := b.JuiceShopLister.Deployments(b.RuntimeEnvironment.Namespace).Get(fmt.Sprintf("juiceshop-%s", team))
+		if err == nil && condition(deployment) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	panic(fmt.Sprintf("timed out waiting for the deployment of team %s in the informer cache", team))
 }
 
 func SignTestTeamname(team string) string {
@@ -0,0 +1,40 @@
+import { useEffect } from "react";
+import { FormattedMessage } from "react-intl";
+
+import { Card } from "@/components/Card";
+import { Spinner } from "@/components/Spinner";
+
+// the proxy keeps redirecting back here until the instance is ready
+const retryInterval = 5000;
+
+export const InstanceStartingCard = ({
+  reason,
+}: {
+  reason: "waking-up" | "restarting";
+}) => {
+  useEffect(() => {
+    const timeout = setTimeout(() => {
+      window.location.href = "/";
+    }, retryInterval);
+    return () => clearTimeout(timeout);
+  }, []);
+
+  return (
+    <Card className="flex items-center p-4 bg-white shadow-md rounded-md mb-3">
+      <Spinner />
+      <span data-test-id="instance-starting">
+        {reason === "waking-up" ? (
+          <FormattedMessage
+            id="instance_status_waking_up"
+            defaultMessage="Your instance was put to sleep after a while without activity and is waking up. Your progress is kept. You'll be taken back to it once it's ready."
+          />
+        ) : (
+          <FormattedMessage
+            id="instance_status_restarting"
+            defaultMessage="Your instance is starting. You'll be taken to it once it's ready."
+          />
+        )}
+      </span>
+    </Card>
+  );
+};
@@ -4,6 +4,7 @@ import { Link } from "react-router-dom";
 import {
   type ActivityEvent,
   isChallengeSolvedEvent,
+  isScoreAdjustedEvent,
   isTeamCreatedEvent,
   useActivityFeed,
 } from "@/hooks/useActivityFeed";
@@ -22,9 +23,11 @@ const EventItem = ({
 }) => {
   const eventColor = isTeamCreatedEvent(event)
     ? "border-yellow-400"
-    : event.isFirstSolve
-      ? "border-red-500"
-      : "border-orange-500";
+    : isScoreAdjustedEvent(event)
+      ? "border-blue-500"
+      : event.isFirstSolve
+        ? "border-red-500"
+        : "border-orange-500";
 
   return (
     <div className="relative pl-6">
@@ -84,6 +87,32 @@ const EventItem = ({
               ),
             }}
           />
+        ) : isScoreAdjustedEvent(event) ? (
+          <FormattedMessage
+            id="activity.score_adjusted"
+            defaultMessage="{team} got {points} pts: {reason}"
+            values={{
+              team: (
+                <Link
+                  to={`/score-overview/teams/${event.team}`}
+                  className="font-bold hover:underline"
+                >
+                  {event.team}
+                </Link>
+              ),
+              points: (
+                <span
+                  className={classNames(
+                    "font-semibold",
+                    event.points > 0 ? "text-green-500" : "text-red-500"
+                  )}
+                >
+                  {event.points > 0 ? `+${event.points}` : event.points}
+                </span>
+              ),
+              reason: event.reason,
+            }}
+          />
         ) : null}
       </p>
       <p className="text-xs text-gray-500 dark:text-gray-400">
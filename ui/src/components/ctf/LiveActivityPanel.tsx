@@ -4,6 +4,7 @@ import { FormattedMessage, type IntlShape, useIntl } from "react-intl";
 import {
   type ActivityEvent,
   isChallengeSolvedEvent,
+  isScoreAdjustedEvent,
   isTeamCreatedEvent,
 } from "@/hooks/useActivityFeed";
 import type { ChallengeCountryMapping } from "@/lib/challenges/challenge-mapper";
@@ -192,6 +193,30 @@ export function LiveActivityPanel({
                   );
                 }
 
+                if (isScoreAdjustedEvent(activity)) {
+                  return (
+                    <div key={key} className={`py-1.5 ${radiateClass}`}>
+                      <div className="text-[11px] text-ctf-primary mb-0.5 leading-[1.4]">
+                        <FormattedMessage
+                          id="ctf.activity_panel.score_adjusted"
+                          defaultMessage="{team} got {points} pts: {reason}"
+                          values={{
+                            team: activity.team,
+                            points:
+                              activity.points > 0
+                                ? `+${activity.points}`
+                                : activity.points,
+                            reason: activity.reason,
+                          }}
+                        />
+                      </div>
+                      <div className="text-[9px] text-ctf-neutral opacity-70">
+                        {formatTimeAgo(activity.timestamp, intl)}
+                      </div>
+                    </div>
+                  );
+                }
+
                 return null;
               })}
             </div>
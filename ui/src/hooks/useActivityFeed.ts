@@ -21,8 +21,17 @@ export interface ChallengeSolvedEvent extends BaseActivityEvent {
   isFirstSolve: boolean;
 }
 
+export interface ScoreAdjustedEvent extends BaseActivityEvent {
+  eventType: "score_adjusted";
+  points: number;
+  reason: string;
+}
+
 // Discriminated union type for all activity events
-export type ActivityEvent = TeamCreatedEvent | ChallengeSolvedEvent;
+export type ActivityEvent =
+  | TeamCreatedEvent
+  | ChallengeSolvedEvent
+  | ScoreAdjustedEvent;
 
 /**
  * Type guard to check if an event is a TeamCreatedEvent
@@ -42,6 +51,15 @@ export function isChallengeSolvedEvent(
   return event.eventType === "challenge_solved";
 }
 
+/**
+ * Type guard to check if an event is a ScoreAdjustedEvent
+ */
+export function isScoreAdjustedEvent(
+  event: ActivityEvent
+): event is ScoreAdjustedEvent {
+  return event.eventType === "score_adjusted";
+}
+
 /**
  * Fetches the activity feed data from the backend.
  *
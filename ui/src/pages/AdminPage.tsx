@@ -272,6 +272,7 @@ interface Team {
     totalCheatScore: number;
     timestamp: string;
   }[];
+  cheatDecision?: "suspicious" | "disqualified";
 }
 
 interface TeamRaw {
@@ -284,6 +285,7 @@ interface TeamRaw {
     totalCheatScore: number;
     timestamp: string;
   }[];
+  cheatDecision?: "suspicious" | "disqualified";
 }
 
 async function fetchAdminData(signal?: AbortSignal): Promise<Team[]> {
@@ -471,6 +473,21 @@ export default function AdminPage() {
                       {(team.cheatScore * 100).toFixed(1)}%
                     </p>
                   )}
+                  {team.cheatDecision === "disqualified" ? (
+                    <p className="text-sm font-semibold text-red-600 dark:text-red-400">
+                      <FormattedMessage
+                        id="admin_table.cheat_decision.disqualified"
+                        defaultMessage="disqualified 🚫"
+                      />
+                    </p>
+                  ) : team.cheatDecision === "suspicious" ? (
+                    <p className="text-sm font-semibold text-orange-600 dark:text-orange-400">
+                      <FormattedMessage
+                        id="admin_table.cheat_decision.suspicious"
+                        defaultMessage="suspicious ⚠️"
+                      />
+                    </p>
+                  ) : null}
                 </div>
               ) : (
                 <p className="text-sm text-gray-500 dark:text-gray-400">
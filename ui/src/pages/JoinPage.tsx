@@ -3,6 +3,7 @@ import { defineMessages, FormattedMessage, useIntl } from "react-intl";
 import { useLocation, useNavigate } from "react-router-dom";
 
 import { InstanceNotFoundCard } from "@/cards/InstanceNotFoundCard";
+import { InstanceStartingCard } from "@/cards/InstanceStartingCard";
 import { Button } from "@/components/Button";
 import { Card } from "@/components/Card";
 
@@ -97,6 +98,12 @@ export function JoinPage({
   return (
     <div className="max-w-3xl">
       {queryMessage === "instance-not-found" ? <InstanceNotFoundCard /> : null}
+      {queryMessage === "instance-waking-up" ? (
+        <InstanceStartingCard reason="waking-up" />
+      ) : null}
+      {queryMessage === "instance-restarting" ? (
+        <InstanceStartingCard reason="restarting" />
+      ) : null}
 
       <Card className="p-8">
         <h2 className="text-2xl font-medium m-0">